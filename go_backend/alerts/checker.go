@@ -0,0 +1,134 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"small_business/models"
+	"time"
+)
+
+const defaultCheckInterval = time.Minute
+
+// Checker watches stock levels and notifies once per product when it
+// crosses its minimum stock. Stock changes made through the API are
+// reported with Observe, a periodic scan catches everything else.
+type Checker struct {
+	stocks   models.StockStore
+	notifier Notifier
+	interval time.Duration
+	events   chan models.StockLevel
+
+	low map[int64]bool // products already alerted, only used by Run
+}
+
+func NewChecker(stocks models.StockStore, notifier Notifier, interval time.Duration) *Checker {
+	return &Checker{
+		stocks:   stocks,
+		notifier: notifier,
+		interval: interval,
+		events:   make(chan models.StockLevel, 100),
+		low:      map[int64]bool{},
+	}
+}
+
+// NewCheckerFromEnv uses NotifierFromEnv and LOW_STOCK_CHECK_INTERVAL (default 1m)
+func NewCheckerFromEnv(stocks models.StockStore) (*Checker, error) {
+	notifier, err := NotifierFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	interval := defaultCheckInterval
+	if value := os.Getenv("LOW_STOCK_CHECK_INTERVAL"); value != "" {
+		interval, err = time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("LOW_STOCK_CHECK_INTERVAL must be a positive duration like 1m, got %q", value)
+		}
+	}
+
+	return NewChecker(stocks, notifier, interval), nil
+}
+
+// Observe hands the level after a stock change to the checker without blocking the request
+func (c *Checker) Observe(level models.StockLevel) {
+	select {
+	case c.events <- level:
+	default:
+		// queue is full, the next scan picks the change up
+	}
+}
+
+// Run checks until ctx is done
+func (c *Checker) Run(ctx context.Context) {
+	// products that are already low when we start are not alerted again
+	c.scan(ctx, false)
+	c.loop(ctx)
+}
+
+func (c *Checker) loop(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case level := <-c.events:
+			c.check(ctx, level)
+		case <-ticker.C:
+			c.scan(ctx, true)
+		}
+	}
+}
+
+// alert if level just crossed the threshold, forget products that recovered
+func (c *Checker) check(ctx context.Context, level models.StockLevel) {
+	if !level.IsLow() {
+		delete(c.low, level.ProductID)
+		return
+	}
+	if c.low[level.ProductID] {
+		return
+	}
+
+	// the alert carries the supplier contact details
+	items, err := c.stocks.ListLowStock(ctx)
+	if err != nil {
+		log.Printf("Error checking low stock: %v", err)
+		return
+	}
+	for _, item := range items {
+		if item.ProductID == level.ProductID {
+			c.low[item.ProductID] = true
+			c.notify(ctx, item)
+			return
+		}
+	}
+}
+
+// compare every low product against what was already alerted
+func (c *Checker) scan(ctx context.Context, notify bool) {
+	items, err := c.stocks.ListLowStock(ctx)
+	if err != nil {
+		log.Printf("Error checking low stock: %v", err)
+		return
+	}
+
+	low := make(map[int64]bool, len(items))
+	for _, item := range items {
+		low[item.ProductID] = true
+		if notify && !c.low[item.ProductID] {
+			c.notify(ctx, item)
+		}
+	}
+	c.low = low
+}
+
+func (c *Checker) notify(ctx context.Context, item models.LowStockItem) {
+	alert := Alert{Event: EventLowStock, Item: item, At: time.Now().UTC()}
+	if err := c.notifier.Notify(ctx, alert); err != nil {
+		log.Printf("Error sending low stock alert for product %d: %v", item.ProductID, err)
+	}
+}
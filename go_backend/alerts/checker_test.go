@@ -0,0 +1,111 @@
+package alerts
+
+import (
+	"context"
+	"small_business/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collects alerts instead of sending them
+type recordingNotifier struct {
+	alerts chan Alert
+}
+
+func (r recordingNotifier) Notify(ctx context.Context, alert Alert) error {
+	r.alerts <- alert
+	return nil
+}
+
+func setupStore(t *testing.T, stock int) (*models.MemoryStore, int64) {
+	ctx := context.Background()
+	store := models.NewMemoryStore()
+
+	supplier := models.Supplier{Name: "testsupplier", ContactEmail: "supplier@example.com"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := models.Product{Name: "testproduct", SupplierID: supplier.ID, Stock: stock, MinimumStock: 2}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	return store, product.ID
+}
+
+func TestCheckerAlertsOncePerCrossing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store, productID := setupStore(t, 5)
+	notifier := recordingNotifier{alerts: make(chan Alert, 10)}
+	checker := NewChecker(store, notifier, time.Hour)
+	checker.scan(ctx, false)
+
+	change := models.StockChange{Reason: models.ReasonSale}
+	adjust := func(delta int) {
+		level, err := store.AdjustStock(ctx, productID, delta, change)
+		assert.NoError(t, err)
+		checker.check(ctx, level)
+	}
+
+	adjust(-2) // 3, still above the minimum
+	assert.Len(t, notifier.alerts, 0)
+
+	adjust(-1) // 2, at the minimum counts as low
+	assert.Len(t, notifier.alerts, 1)
+	alert := <-notifier.alerts
+	assert.Equal(t, EventLowStock, alert.Event)
+	assert.Equal(t, "supplier@example.com", alert.Item.SupplierEmail)
+
+	adjust(-1) // still low, no second alert
+	assert.Len(t, notifier.alerts, 0)
+
+	adjust(5) // restocked, then low again
+	adjust(-5)
+	assert.Len(t, notifier.alerts, 1)
+}
+
+func TestCheckerScan(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store, productID := setupStore(t, 1)
+	notifier := recordingNotifier{alerts: make(chan Alert, 10)}
+	checker := NewChecker(store, notifier, time.Hour)
+
+	// low at startup is not news
+	checker.scan(ctx, false)
+	assert.Len(t, notifier.alerts, 0)
+
+	// recovered and dropped again behind the checker's back
+	_, err := store.SetStock(ctx, productID, 10, models.StockChange{Reason: models.ReasonReceipt})
+	assert.NoError(t, err)
+	checker.scan(ctx, true)
+	_, err = store.SetStock(ctx, productID, 0, models.StockChange{Reason: models.ReasonDamage})
+	assert.NoError(t, err)
+	checker.scan(ctx, true)
+
+	assert.Len(t, notifier.alerts, 1)
+}
+
+func TestCheckerRunObserve(t *testing.T) {
+	t.Parallel()
+	store, productID := setupStore(t, 3)
+	notifier := recordingNotifier{alerts: make(chan Alert, 10)}
+	checker := NewChecker(store, notifier, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// scan first so the product starts above its minimum, then run like Run does
+	checker.scan(ctx, false)
+	go checker.loop(ctx)
+
+	level, err := store.AdjustStock(ctx, productID, -3, models.StockChange{Reason: models.ReasonSale})
+	assert.NoError(t, err)
+	checker.Observe(level)
+
+	select {
+	case alert := <-notifier.alerts:
+		assert.Equal(t, productID, alert.Item.ProductID)
+		assert.Equal(t, 0, alert.Item.Stock)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no alert after the stock crossed the minimum")
+	}
+}
@@ -0,0 +1,136 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"os"
+	"small_business/models"
+	"strings"
+	"time"
+)
+
+const EventLowStock = "low_stock"
+
+// Alert is emitted once when a product drops to or below its minimum stock
+type Alert struct {
+	Event string              `json:"event"`
+	Item  models.LowStockItem `json:"item"`
+	At    time.Time           `json:"at"`
+}
+
+func (a Alert) summary() string {
+	return fmt.Sprintf("%s (product %d) is low on stock: %d left, minimum %d", a.Item.Name, a.Item.ProductID, a.Item.Stock, a.Item.MinimumStock)
+}
+
+// Notifier delivers alerts somewhere a person will see them
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// LogNotifier writes alerts to the application log, the default
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(ctx context.Context, alert Alert) error {
+	log.Printf("Low stock alert: %s, reorder from %s <%s> %s", alert.summary(), alert.Item.SupplierName, alert.Item.SupplierEmail, alert.Item.SupplierPhone)
+	return nil
+}
+
+// WebhookNotifier POSTs the alert as JSON to URL
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// EmailNotifier mails the alert through an SMTP server, e.g. a local mailpit
+type EmailNotifier struct {
+	Addr string // host:port
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+func (e EmailNotifier) message(alert Alert) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	// product names are user input, encoding keeps a line break in one from starting another header
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Low stock: "+alert.Item.Name))
+	fmt.Fprintf(&b, "Date: %s\r\n", alert.At.Format(time.RFC1123Z))
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s.\r\n\r\n", alert.summary())
+	fmt.Fprintf(&b, "Supplier: %s\r\nEmail: %s\r\nPhone: %s\r\n", alert.Item.SupplierName, alert.Item.SupplierEmail, alert.Item.SupplierPhone)
+	return []byte(b.String())
+}
+
+func (e EmailNotifier) Notify(ctx context.Context, alert Alert) error {
+	return smtp.SendMail(e.Addr, e.Auth, e.From, e.To, e.message(alert))
+}
+
+// NotifierFromEnv picks the notifier from ALERT_NOTIFIER: log (default), webhook or email
+func NotifierFromEnv() (Notifier, error) {
+	switch kind := os.Getenv("ALERT_NOTIFIER"); kind {
+	case "", "log":
+		return LogNotifier{}, nil
+	case "webhook":
+		url := os.Getenv("ALERT_WEBHOOK_URL")
+		if url == "" {
+			return nil, fmt.Errorf("ALERT_WEBHOOK_URL is required for the webhook notifier")
+		}
+		return WebhookNotifier{URL: url, Client: &http.Client{Timeout: 10 * time.Second}}, nil
+	case "email":
+		notifier := EmailNotifier{
+			Addr: os.Getenv("SMTP_ADDR"),
+			From: os.Getenv("ALERT_EMAIL_FROM"),
+		}
+		for _, to := range strings.Split(os.Getenv("ALERT_EMAIL_TO"), ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				notifier.To = append(notifier.To, to)
+			}
+		}
+		if notifier.Addr == "" || notifier.From == "" || len(notifier.To) == 0 {
+			return nil, fmt.Errorf("SMTP_ADDR, ALERT_EMAIL_FROM and ALERT_EMAIL_TO are required for the email notifier")
+		}
+		// local stand-ins accept mail without auth
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			host, _, _ := strings.Cut(notifier.Addr, ":")
+			notifier.Auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		return notifier, nil
+	default:
+		return nil, fmt.Errorf("unknown ALERT_NOTIFIER %q, use log, webhook or email", kind)
+	}
+}
@@ -0,0 +1,96 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"small_business/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testAlert = Alert{
+	Event: EventLowStock,
+	Item:  models.LowStockItem{ProductID: 1, Name: "testproduct", Stock: 1, MinimumStock: 2, SupplierName: "testsupplier", SupplierEmail: "supplier@example.com"},
+	At:    time.Date(2024, 8, 20, 12, 0, 0, 0, time.UTC),
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	t.Parallel()
+
+	var received Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	err := WebhookNotifier{URL: server.URL}.Notify(context.Background(), testAlert)
+	assert.NoError(t, err)
+	assert.Equal(t, testAlert, received)
+}
+
+func TestWebhookNotifierFailure(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := WebhookNotifier{URL: server.URL}.Notify(context.Background(), testAlert)
+	assert.Error(t, err)
+}
+
+func TestEmailMessage(t *testing.T) {
+	t.Parallel()
+
+	notifier := EmailNotifier{From: "alerts@example.com", To: []string{"owner@example.com", "buyer@example.com"}}
+	message := string(notifier.message(testAlert))
+
+	assert.True(t, strings.HasPrefix(message, "From: alerts@example.com\r\nTo: owner@example.com, buyer@example.com\r\n"))
+	assert.Contains(t, message, "Subject: Low stock: testproduct\r\n")
+	assert.Contains(t, message, "Email: supplier@example.com")
+}
+
+func TestEmailMessageHeaderInjection(t *testing.T) {
+	t.Parallel()
+
+	alert := testAlert
+	alert.Item.Name = "testproduct\r\nBcc: victim@example.com"
+	message := string(EmailNotifier{From: "alerts@example.com", To: []string{"owner@example.com"}}.message(alert))
+
+	// the line break stays inside the encoded subject
+	headers, _, _ := strings.Cut(message, "\r\n\r\n")
+	assert.NotContains(t, headers, "\r\nBcc:")
+	assert.Contains(t, headers, "Subject: =?utf-8?q?Low_stock:_testproduct=0D=0ABcc:_victim@example.com?=\r\n")
+}
+
+func TestNotifierFromEnv(t *testing.T) {
+	t.Setenv("ALERT_NOTIFIER", "")
+	notifier, err := NotifierFromEnv()
+	assert.NoError(t, err)
+	assert.IsType(t, LogNotifier{}, notifier)
+
+	t.Setenv("ALERT_NOTIFIER", "email")
+	t.Setenv("SMTP_ADDR", "localhost:1025")
+	t.Setenv("ALERT_EMAIL_FROM", "alerts@example.com")
+	t.Setenv("ALERT_EMAIL_TO", "owner@example.com, buyer@example.com")
+	notifier, err = NotifierFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"owner@example.com", "buyer@example.com"}, notifier.(EmailNotifier).To)
+
+	t.Setenv("ALERT_NOTIFIER", "webhook")
+	t.Setenv("ALERT_WEBHOOK_URL", "")
+	_, err = NotifierFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("ALERT_NOTIFIER", "pigeon")
+	_, err = NotifierFromEnv()
+	assert.Error(t, err)
+}
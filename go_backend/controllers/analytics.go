@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// products without any movement for this many days count as dead stock
+const defaultDeadStockDays = 90
+
+// load the activity over the last days, responds itself on error
+func (s *Server) productActivity(c *gin.Context, days int) ([]models.ProductActivity, bool) {
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	activity, err := s.Analytics.ListProductActivity(c.Request.Context(), since)
+	if err != nil {
+		respondStoreError(c, err, "Error calculating analytics", "No products found")
+		return nil, false
+	}
+	return activity, true
+}
+
+// GET /analytics/products?days=30, turnover, days of cover and ABC class per product
+func (s *Server) ViewProductAnalytics(c *gin.Context) {
+	days, ok := daysQuery(c, defaultUsageWindowDays)
+	if !ok {
+		return
+	}
+	activity, ok := s.productActivity(c, days)
+	if !ok {
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Product Analytics": models.AnalyzeProducts(activity, days),
+		"days":              days,
+	})
+}
+
+// GET /analytics/suppliers?days=30, the product analytics added up per supplier
+func (s *Server) ViewSupplierAnalytics(c *gin.Context) {
+	days, ok := daysQuery(c, defaultUsageWindowDays)
+	if !ok {
+		return
+	}
+	activity, ok := s.productActivity(c, days)
+	if !ok {
+		return
+	}
+
+	products := models.AnalyzeProducts(activity, days)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Supplier Analytics": models.SummarizeSuppliers(activity, products, days),
+		"days":               days,
+	})
+}
+
+// GET /analytics/dead-stock?days=90, products with stock that did not move in days
+func (s *Server) ViewDeadStock(c *gin.Context) {
+	days, ok := daysQuery(c, defaultDeadStockDays)
+	if !ok {
+		return
+	}
+	activity, ok := s.productActivity(c, days)
+	if !ok {
+		return
+	}
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -days)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Dead Stock": models.FindDeadStock(activity, cutoff),
+		"days":       days,
+	})
+}
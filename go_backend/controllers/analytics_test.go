@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var productActivityColumns = []string{"id", "name", "supplier_id", "supplier_name", "stock", "net_change", "units_sold", "revenue", "last_movement"}
+
+func TestViewProductAnalytics(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	rows := sqlmock.NewRows(productActivityColumns).
+		AddRow(1, "testproduct", 1, "testsupplier", 10, -10, 10, "25.00", time.Now())
+	mock.ExpectQuery("FROM products p JOIN supplier s ON s.id = p.supplier_id").WithArgs(sqlmock.AnyArg()).WillReturnRows(rows)
+
+	w := serve(server.ViewProductAnalytics, "GET", "/analytics/products?days=10", "", nil)
+
+	// 10 sold from an average of 15, 1 a day leaves 10 days of cover
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"turnover": 0.67`)
+	assert.Contains(t, w.Body.String(), `"days_of_cover": 10`)
+	assert.Contains(t, w.Body.String(), `"abc_class": "A"`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestViewDeadStock(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	rows := sqlmock.NewRows(productActivityColumns).
+		AddRow(1, "moving", 1, "testsupplier", 10, 0, 0, "0", time.Now()).
+		AddRow(2, "dusty", 1, "testsupplier", 3, 0, 0, "0", time.Now().AddDate(0, 0, -120))
+	mock.ExpectQuery("FROM products p JOIN supplier s ON s.id = p.supplier_id").WithArgs(sqlmock.AnyArg()).WillReturnRows(rows)
+
+	w := serve(server.ViewDeadStock, "GET", "/analytics/dead-stock", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "dusty")
+	assert.NotContains(t, w.Body.String(), "moving")
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestViewSupplierAnalyticsInvalidDays(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.ViewSupplierAnalytics, "GET", "/analytics/suppliers?days=400", "", nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
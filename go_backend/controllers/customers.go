@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /customers/insert, emails are optional but unique
+func (s *Server) InsertCustomer(c *gin.Context) {
+	var body struct {
+		Name    string `json:"name" binding:"required,max=100"`
+		Email   string `json:"email" binding:"omitempty,email,max=100"`
+		Phone   string `json:"phone" binding:"max=20"`
+		Address string `json:"address" binding:"max=255"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	customer := models.Customer{Name: body.Name, Email: body.Email, Phone: body.Phone, Address: body.Address}
+	if err := s.Customers.CreateCustomer(c.Request.Context(), &customer); err != nil {
+		respondStoreError(c, err, "Error inserting new customer", "Customer not found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{
+		"message":              "Customer Information Successfully Added",
+		"Customer Information": customer,
+	})
+}
+
+// GET /customers?name=acme, name matches anywhere in the name, ignoring case
+func (s *Server) ViewCustomers(c *gin.Context) {
+	customers, err := s.Customers.ListCustomers(c.Request.Context(), models.CustomerFilter{Name: c.Query("name")})
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving customers", "No customers found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Customers Found": customers,
+	})
+}
+
+func (s *Server) ViewCustomerByID(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid customer ID")
+	if !ok {
+		return
+	}
+
+	customer, err := s.Customers.GetCustomer(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving customer", "No customer found with this ID")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Customer Found": customer,
+	})
+}
+
+// DELETE /customers/remove/:id, customers with sales orders are kept
+func (s *Server) DeleteCustomerByID(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid customer ID")
+	if !ok {
+		return
+	}
+
+	if err := s.Customers.DeleteCustomer(c.Request.Context(), id); err != nil {
+		respondStoreError(c, err, "Error removing customer", "Customer not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Customer Removed Successfully",
+		"Customer ID": id,
+	})
+}
+
+// update customer email by id, an empty email clears it
+func (s *Server) UpdateCustomerEmail(c *gin.Context) {
+	var body struct {
+		ID    int64  `json:"id" binding:"required"`
+		Email string `json:"email" binding:"omitempty,email,max=100"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := s.Customers.UpdateCustomerEmail(c.Request.Context(), body.ID, body.Email); err != nil {
+		respondStoreError(c, err, "Error updating customer email", "Customer not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Customer Email Updated Successfully",
+		"New Email": body.Email,
+	})
+}
+
+// update customer phone number by id
+func (s *Server) UpdateCustomerPhone(c *gin.Context) {
+	var body struct {
+		ID    int64  `json:"id" binding:"required"`
+		Phone string `json:"phone" binding:"max=20"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := s.Customers.UpdateCustomerPhone(c.Request.Context(), body.ID, body.Phone); err != nil {
+		respondStoreError(c, err, "Error updating phone number", "Customer not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Customer Phone Number Updated Successfully",
+		"New Phone Number": body.Phone,
+	})
+}
+
+// update customer address by id
+func (s *Server) UpdateCustomerAddress(c *gin.Context) {
+	var body struct {
+		ID      int64  `json:"id" binding:"required"`
+		Address string `json:"address" binding:"max=255"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := s.Customers.UpdateCustomerAddress(c.Request.Context(), body.ID, body.Address); err != nil {
+		respondStoreError(c, err, "Error updating address", "Customer not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Customer Address Updated Successfully",
+		"New Address": body.Address,
+	})
+}
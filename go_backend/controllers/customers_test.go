@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+var customerRowColumns = []string{"id", "name", "email", "phone", "address", "created_at", "updated_at"}
+
+func TestInsertCustomer(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectQuery("INSERT INTO customers \\(name, email, phone, address\\)").WithArgs("testcustomer", "customer@example.com", "0123456789", "").
+		WillReturnRows(sqlmock.NewRows(customerRowColumns).AddRow(1, "testcustomer", "customer@example.com", "0123456789", "", "2024-08-30", "2024-08-30"))
+
+	body := `{"name": "testcustomer", "email": "customer@example.com", "phone": "0123456789"}`
+	w := serve(server.InsertCustomer, "POST", "/customers/insert", body, nil)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), "customer@example.com")
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestInsertCustomerValidation(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	// no name
+	w := serve(server.InsertCustomer, "POST", "/customers/insert", `{"email": "customer@example.com"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// not an email address
+	w = serve(server.InsertCustomer, "POST", "/customers/insert", `{"name": "testcustomer", "email": "nope"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// email taken
+	mock.ExpectQuery("INSERT INTO customers").WillReturnError(&pq.Error{Code: "23505", Detail: "Key (email)=(customer@example.com) already exists."})
+	w = serve(server.InsertCustomer, "POST", "/customers/insert", `{"name": "other", "email": "customer@example.com"}`, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestViewCustomersByName(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	rows := sqlmock.NewRows(customerRowColumns).
+		AddRow(1, "Acme 50% Off", "", "", "", "2024-08-30", "2024-08-30")
+	mock.ExpectQuery("SELECT (.+) FROM customers WHERE name ILIKE \\$1 ORDER BY id").WithArgs(`%50\%%`).WillReturnRows(rows)
+
+	w := serve(server.ViewCustomers, "GET", "/customers/?name=50%25", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Acme 50% Off")
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateCustomerAddress(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectExec("UPDATE customers SET address = NULLIF\\(\\$1, ''\\), updated_at = NOW\\(\\) WHERE id = \\$2").WithArgs("1 Main St", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := serve(server.UpdateCustomerAddress, "PUT", "/customers/change-address", `{"id": 1, "address": "1 Main St"}`, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDeleteCustomerWithOrders(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectExec("DELETE FROM customers WHERE id = \\$1").WithArgs(1).
+		WillReturnError(&pq.Error{Code: "23503", Detail: "Key (id)=(1) is still referenced from table \"sales_orders\"."})
+
+	w := serve(server.DeleteCustomerByID, "DELETE", "/customers/remove/1", "", gin.Params{{Key: "id", Value: "1"}})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// the ETag of a product or supplier is its version, quoted like every strong ETag
+func setETag(c *gin.Context, version int64) {
+	c.Header("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// read the version a change expects from If-Match, "*" takes any version.
+// Responds 428 itself when the header is missing and 412 when it cannot match.
+func ifMatch(c *gin.Context) (int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{
+			"error":   "If-Match header is required",
+			"details": "send the ETag from the last GET so changes made since are not overwritten",
+		})
+		return 0, false
+	}
+	if header == "*" {
+		return models.AnyVersion, true
+	}
+
+	// weak ETags never match a strong comparison
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil || version <= 0 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error":   "If-Match does not match",
+			"details": "If-Match must be the ETag from the last GET, like \"3\"",
+		})
+		return 0, false
+	}
+	return version, true
+}
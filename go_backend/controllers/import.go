@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"small_business/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limits of an import upload
+const (
+	maxImportBytes = 10 << 20
+	maxImportRows  = 5000
+)
+
+var (
+	productImportFields  = []string{"name", "sku", "barcode", "description", "supplier_id", "price", "cost", "stock", "minimum_stock"}
+	supplierImportFields = []string{"name", "contact_email", "phone", "lead_time_days"}
+
+	// an empty cell clears these, other empty cells leave the field as it is
+	nullableImportFields = []string{"sku", "barcode", "description", "contact_email", "phone"}
+	intImportFields      = []string{"supplier_id", "stock", "minimum_stock", "lead_time_days"}
+)
+
+// one line of an uploaded file
+type importRecord struct {
+	Line  int
+	Cells []string
+}
+
+// an uploaded file with its header mapped to fields
+type importSheet struct {
+	fields  []string // field of each column, empty when the column is ignored
+	ignored []string // headers of the ignored columns
+	records []importRecord
+}
+
+// read the file field of a multipart upload, a .csv or .xlsx file with a header row.
+// Headers are matched to fields case insensitively, with spaces and dashes as underscores,
+// or through the optional mapping field, a JSON object from header to field. Responds 400 or 413 itself.
+func readImport(c *gin.Context, fields []string) (importSheet, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"message": fmt.Sprintf("the upload must not be larger than %d MB", maxImportBytes>>20),
+			})
+			return importSheet{}, false
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "a .csv or .xlsx file must be uploaded in the file field",
+		})
+		return importSheet{}, false
+	}
+	defer file.Close()
+
+	var records []importRecord
+	switch strings.ToLower(filepath.Ext(header.Filename)) {
+	case ".csv":
+		records, err = readCSV(file)
+	case ".xlsx":
+		records, err = readXLSX(file, header.Size)
+	default:
+		err = errors.New("the file must be a .csv or .xlsx file")
+	}
+	if err == nil && len(records) == 0 {
+		err = errors.New("the file has no header row")
+	}
+	if err == nil && len(records)-1 > maxImportRows {
+		err = fmt.Errorf("the file must not have more than %d rows", maxImportRows)
+	}
+	var sheet importSheet
+	if err == nil {
+		sheet, err = mapImportHeader(records[0].Cells, c.PostForm("mapping"), fields)
+		sheet.records = records[1:]
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid import file",
+			"details": err.Error(),
+		})
+		return importSheet{}, false
+	}
+	return sheet, true
+}
+
+// read every line of a CSV file, numbered like in the file
+func readCSV(file io.Reader) ([]importRecord, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records := []importRecord{}
+	for {
+		cells, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// spreadsheets often save CSV with a byte order mark
+		if len(records) == 0 {
+			cells[0] = strings.TrimPrefix(cells[0], "\ufeff")
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, importRecord{Line: line, Cells: cells})
+	}
+}
+
+// the field of every header column, a name column is required
+func mapImportHeader(header []string, mapping string, fields []string) (importSheet, error) {
+	byHeader := map[string]string{}
+	if mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &byHeader); err != nil {
+			return importSheet{}, errors.New("mapping must be a JSON object from column header to field")
+		}
+	}
+	for column, field := range byHeader {
+		if !slices.Contains(fields, field) {
+			return importSheet{}, fmt.Errorf("column %q cannot be mapped to %q, fields are %s", column, field, strings.Join(fields, ", "))
+		}
+	}
+
+	sheet := importSheet{fields: make([]string, len(header)), ignored: []string{}}
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		field, mapped := byHeader[column]
+		if !mapped {
+			field = strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(column))
+		}
+		if !slices.Contains(fields, field) {
+			if column != "" {
+				sheet.ignored = append(sheet.ignored, column)
+			}
+			continue
+		}
+		if slices.Contains(sheet.fields, field) {
+			return importSheet{}, fmt.Errorf("more than one column is %s", field)
+		}
+		sheet.fields[i] = field
+		delete(byHeader, column)
+	}
+	for column := range byHeader {
+		return importSheet{}, fmt.Errorf("mapped column %q is not in the file", column)
+	}
+	if !slices.Contains(sheet.fields, "name") {
+		return importSheet{}, errors.New("the file needs a name column")
+	}
+	return sheet, nil
+}
+
+// the cells of a record as a merge patch, nil for a blank line
+func (sheet importSheet) patch(record importRecord) (mergePatch, error) {
+	patch := mergePatch{}
+	blank := true
+	for i, field := range sheet.fields {
+		var cell string
+		if i < len(record.Cells) {
+			cell = strings.TrimSpace(record.Cells[i])
+		}
+		blank = blank && cell == ""
+		switch {
+		case field == "":
+		case cell == "" && slices.Contains(nullableImportFields, field):
+			patch[field] = json.RawMessage("null")
+		case cell == "" && field != "name":
+		case slices.Contains(intImportFields, field):
+			if _, err := strconv.Atoi(cell); err != nil {
+				return nil, fmt.Errorf("%s must be a whole number", field)
+			}
+			patch[field] = json.RawMessage(cell)
+		default:
+			quoted, _ := json.Marshal(cell)
+			patch[field] = quoted
+		}
+	}
+	if blank {
+		return nil, nil
+	}
+	return patch, nil
+}
+
+// parse ?dry_run=, responds 400 itself
+func dryRunQuery(c *gin.Context) (bool, bool) {
+	value := c.Query("dry_run")
+	if value == "" {
+		return false, true
+	}
+	dryRun, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "dry_run must be true or false",
+		})
+		return false, false
+	}
+	return dryRun, true
+}
+
+// POST /products/import, products matched by SKU or else name are patched, the others created.
+// The stock column is only the opening stock of new products.
+func (s *Server) ImportProducts(c *gin.Context) {
+	dryRun, ok := dryRunQuery(c)
+	if !ok {
+		return
+	}
+	sheet, ok := readImport(c, productImportFields)
+	if !ok {
+		return
+	}
+
+	rows := []models.ProductImport{}
+	invalid := []models.ImportError{}
+	for _, record := range sheet.records {
+		patch, err := sheet.patch(record)
+		if patch == nil && err == nil {
+			continue
+		}
+		row := models.ProductImport{Row: record.Line}
+		if err == nil {
+			var stock *int
+			err = errors.Join(
+				patchField(patch, "name", false, &row.Patch.Name),
+				patchField(patch, "sku", true, &row.Patch.SKU),
+				patchField(patch, "barcode", true, &row.Patch.Barcode),
+				patchField(patch, "description", true, &row.Patch.Description),
+				patchField(patch, "supplier_id", false, &row.Patch.SupplierID),
+				patchField(patch, "price", false, &row.Patch.Price),
+				patchField(patch, "cost", false, &row.Patch.Cost),
+				patchField(patch, "minimum_stock", false, &row.Patch.MinimumStock),
+				patchField(patch, "stock", false, &stock),
+			)
+			if stock != nil {
+				row.Stock = *stock
+			}
+		}
+		if err == nil {
+			err = checkProductPatch(&row.Patch)
+		}
+		if err == nil && row.Stock < 0 {
+			err = errors.New("stock must not be negative")
+		}
+		if err != nil {
+			invalid = append(invalid, models.ImportError{Row: record.Line, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	var userID int64
+	if user, ok := currentUser(c); ok {
+		userID = user.ID
+	}
+
+	// with invalid rows the rest is still checked, but not written
+	result, err := s.Products.ImportProducts(c.Request.Context(), rows, dryRun || len(invalid) > 0, userID)
+	if err != nil {
+		respondStoreError(c, err, "Error importing products", "Product not found")
+		return
+	}
+	respondImport(c, result, invalid, dryRun, sheet.ignored)
+}
+
+// POST /suppliers/import, suppliers matched by name are patched, the others created
+func (s *Server) ImportSuppliers(c *gin.Context) {
+	dryRun, ok := dryRunQuery(c)
+	if !ok {
+		return
+	}
+	sheet, ok := readImport(c, supplierImportFields)
+	if !ok {
+		return
+	}
+
+	rows := []models.SupplierImport{}
+	invalid := []models.ImportError{}
+	for _, record := range sheet.records {
+		patch, err := sheet.patch(record)
+		if patch == nil && err == nil {
+			continue
+		}
+		row := models.SupplierImport{Row: record.Line}
+		if err == nil {
+			err = errors.Join(
+				patchField(patch, "name", false, &row.Patch.Name),
+				patchField(patch, "contact_email", true, &row.Patch.ContactEmail),
+				patchField(patch, "phone", true, &row.Patch.Phone),
+				patchField(patch, "lead_time_days", false, &row.Patch.LeadTimeDays),
+			)
+		}
+		if err == nil {
+			err = checkSupplierPatch(&row.Patch)
+		}
+		if err != nil {
+			invalid = append(invalid, models.ImportError{Row: record.Line, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	result, err := s.Suppliers.ImportSuppliers(c.Request.Context(), rows, dryRun || len(invalid) > 0)
+	if err != nil {
+		respondStoreError(c, err, "Error importing suppliers", "Supplier not found")
+		return
+	}
+	respondImport(c, result, invalid, dryRun, sheet.ignored)
+}
+
+// 200 when the import was written or a dry run found nothing wrong, 422 with every row error otherwise
+func respondImport(c *gin.Context, result models.ImportResult, invalid []models.ImportError, dryRun bool, ignored []string) {
+	result.DryRun = dryRun
+	result.Errors = append(result.Errors, invalid...)
+	slices.SortStableFunc(result.Errors, func(a, b models.ImportError) int { return cmp.Compare(a.Row, b.Row) })
+
+	status, message := http.StatusOK, "Import committed"
+	switch {
+	case len(result.Errors) > 0:
+		status, message = http.StatusUnprocessableEntity, "Import has errors, nothing was written"
+	case dryRun:
+		message = "Dry run found no errors, nothing was written"
+	}
+	c.JSON(status, gin.H{
+		"message":         message,
+		"import":          result,
+		"ignored_columns": ignored,
+	})
+}
@@ -0,0 +1,225 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"small_business/models"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+// run an import handler with filename uploaded in the file field and an optional mapping
+func serveUpload(handler gin.HandlerFunc, path string, filename string, content []byte, mapping string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write(content)
+	if mapping != "" {
+		form.WriteField("mapping", mapping)
+	}
+	form.Close()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("POST", path, &body)
+	c.Request.Header.Set("Content-Type", form.FormDataContentType())
+
+	handler(c)
+	return w
+}
+
+// a workbook with one sheet, the smallest xlsx spreadsheets open
+func buildXLSX(t *testing.T, sheet string, sharedStrings string) []byte {
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml": `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheet + `</sheetData></worksheet>`,
+		"xl/sharedStrings.xml":     `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + sharedStrings + `</sst>`,
+	}
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range parts {
+		part, err := archive.Create(name)
+		if err != nil {
+			t.Fatalf("an error '%s' was not expected when writing the xlsx file", err)
+		}
+		part.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("an error '%s' was not expected when writing the xlsx file", err)
+	}
+	return buf.Bytes()
+}
+
+func TestImportProductsCSV(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	//mock queries, the row with a SKU is matched by it, then by name, and created
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE sku = \\$1").WithArgs("TP-1").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE name = \\$1").WithArgs("testproduct").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO products").WithArgs("testproduct", "TP-1", "", "", 1, decimal.RequireFromString("9.99"), decimal.Zero, 5, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO stock_movements").WithArgs(1, 5, models.ReasonAdjustment, 0, "opening balance", 0).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// spreadsheets save CSV with a byte order mark, blank lines are skipped
+	file := "\ufeffName,SKU,Supplier ID,Price,Stock,Notes\n\ntestproduct, TP-1 ,1,9.99,5,ignored\n,,,,,\n"
+	w := serveUpload(server.ImportProducts, "/products/import", "products.csv", []byte(file), "")
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"created":1`)
+	assert.Contains(t, w.Body.String(), `"committed":true`)
+	assert.Contains(t, w.Body.String(), `"ignored_columns":["Notes"]`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestImportProductsInvalidRows(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	// the valid row is still checked, but as a dry run
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE name = \\$1").WithArgs("testproduct").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}).AddRow(1, false))
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery("UPDATE products SET name = \\$1, minimum_stock = \\$2").WithArgs("testproduct", 2, 1).
+		WillReturnRows(sqlmock.NewRows(productRowColumns).AddRow(1, "testproduct", "", "", "", 1, "9.99", "0", 0, 2, "2024-08-01", "2024-09-01", 2, nil))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	file := "name,price,minimum_stock\ntestproduct,,2\notherproduct,free,1\nthirdproduct,1,many\n"
+	w := serveUpload(server.ImportProducts, "/products/import", "products.csv", []byte(file), "")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `{"row":3,"message":"invalid price"}`)
+	assert.Contains(t, w.Body.String(), `{"row":4,"message":"minimum_stock must be a whole number"}`)
+	assert.Contains(t, w.Body.String(), `"committed":false`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestImportColumnLimits(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	// rows over the column limits are refused before the store, which writes nothing
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	file := "name,sku,price,cost\nfirst," + strings.Repeat("X", 65) + ",1,1\nsecond,,10000,1\nthird,,1,100000000\n"
+	w := serveUpload(server.ImportProducts, "/products/import", "products.csv", []byte(file), "")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `{"row":2,"message":"sku must not be longer than 64 characters"}`)
+	assert.Contains(t, w.Body.String(), `{"row":3,"message":"price must not be more than 9999.99"}`)
+	assert.Contains(t, w.Body.String(), `{"row":4,"message":"cost must not be more than 99999999.99"}`)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	file = "name,phone\n" + strings.Repeat("s", 256) + ",\nsupplier,+44 (0) 20 7946 0000 ext. 12\n"
+	w = serveUpload(server.ImportSuppliers, "/suppliers/import", "suppliers.csv", []byte(file), "")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), `{"row":2,"message":"name must not be longer than 255 characters"}`)
+	assert.Contains(t, w.Body.String(), `{"row":3,"message":"phone must not be longer than 20 characters"}`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestImportSuppliersXLSX(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	//mock queries, a dry run is rolled back
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM supplier WHERE name = \\$1").WithArgs("testsupplier").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("INSERT INTO supplier").WithArgs("testsupplier", "supplier@example.com", "", 3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// shared and inline strings, a number and a row number that skips a line
+	sheet := `<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="inlineStr"><is><t>Email</t></is></c><c r="D1" t="s"><v>1</v></c></row>` +
+		`<row r="3"><c r="A3" t="inlineStr"><is><r><t>test</t></r><r><t>supplier</t></r></is></c><c r="B3" t="str"><v>supplier@example.com</v></c><c r="D3"><v>3.0</v></c></row>`
+	shared := `<si><t>Supplier</t></si><si><t>Lead Time Days</t></si>`
+	w := serveUpload(server.ImportSuppliers, "/suppliers/import?dry_run=true", "suppliers.xlsx", buildXLSX(t, sheet, shared), `{"Supplier": "name", "Email": "contact_email"}`)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"dry_run":true`)
+	assert.Contains(t, w.Body.String(), `"committed":false`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestImportBadFile(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serveUpload(server.ImportSuppliers, "/suppliers/import", "suppliers.txt", []byte("name\ntestsupplier\n"), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// a name column is required
+	w = serveUpload(server.ImportSuppliers, "/suppliers/import", "suppliers.csv", []byte("email\nsupplier@example.com\n"), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serveUpload(server.ImportSuppliers, "/suppliers/import", "suppliers.csv", []byte("name,Supplier\ntestsupplier,other\n"), `{"Supplier": "name"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "more than one column is name")
+
+	w = serveUpload(server.ImportSuppliers, "/suppliers/import", "suppliers.csv", []byte("name\ntestsupplier\n"), `{"Vendor": "name"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serveUpload(server.ImportSuppliers, "/suppliers/import", "suppliers.xlsx", []byte("name\ntestsupplier\n"), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serveUpload(server.ImportSuppliers, "/suppliers/import?dry_run=maybe", "suppliers.csv", []byte("name\ntestsupplier\n"), "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestXLSXColumn(t *testing.T) {
+	t.Parallel()
+
+	for ref, column := range map[string]int{"A1": 0, "Z9": 25, "AA10": 26, "XFD1": 16383} {
+		got, err := xlsxColumn(ref)
+		assert.NoError(t, err)
+		assert.Equal(t, column, got, ref)
+	}
+	_, err := xlsxColumn("12")
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"net/http"
+	"slices"
+	"small_business/models"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parse ?sort=, ?limit= and ?offset= of a list endpoint, responds 400 itself.
+// sort is one of keys, a leading - sorts descending.
+func listQuery(c *gin.Context, keys []string) (models.Sort, models.Page, bool) {
+	sort := models.Sort{Key: strings.TrimPrefix(c.Query("sort"), "-"), Descending: strings.HasPrefix(c.Query("sort"), "-")}
+	if sort.Key != "" && !slices.Contains(keys, sort.Key) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "sort must be one of " + strings.Join(keys, ", "),
+		})
+		return models.Sort{}, models.Page{}, false
+	}
+
+	page := models.Page{}
+	var ok bool
+	if page.Limit, ok = limitQuery(c, models.DefaultPageSize); !ok {
+		return models.Sort{}, models.Page{}, false
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "offset must be a number of at least 0",
+			})
+			return models.Sort{}, models.Page{}, false
+		}
+		page.Offset = offset
+	}
+	return sort, page, true
+}
+
+// parse the optional ?limit=, responds 400 itself when it is out of range
+func limitQuery(c *gin.Context, fallback int) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return fallback, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > models.MaxPageSize {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "limit must be a number between 1 and " + strconv.Itoa(models.MaxPageSize),
+		})
+		return 0, false
+	}
+	return limit, true
+}
+
+// parse the optional ?include_deleted=, only admins may see deleted rows.
+// Responds 400 or 403 itself.
+func includeDeletedQuery(c *gin.Context) (bool, bool) {
+	value := c.Query("include_deleted")
+	if value == "" {
+		return false, true
+	}
+	include, err := strconv.ParseBool(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "include_deleted must be true or false",
+		})
+		return false, false
+	}
+	if include && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": "Only admins can see deleted records",
+		})
+		return false, false
+	}
+	return include, true
+}
+
+// metadata sent with every page, next_offset is null on the last page
+func pagination(page models.Page, total int) gin.H {
+	var next *int
+	if page.Offset+page.Limit < total {
+		offset := page.Offset + page.Limit
+		next = &offset
+	}
+	return gin.H{
+		"total":       total,
+		"limit":       page.Limit,
+		"offset":      page.Offset,
+		"next_offset": next,
+	}
+}
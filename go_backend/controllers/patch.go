@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// body of a PATCH request, a JSON Merge Patch (RFC 7396): fields that are
+// left out stay as they are, fields set to null are removed
+type mergePatch map[string]json.RawMessage
+
+// read the body as a merge patch with only the given fields, responds 400 itself
+func bindMergePatch(c *gin.Context, fields ...string) (mergePatch, bool) {
+	var patch mergePatch
+	data, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(data, &patch)
+	}
+	if err == nil && patch == nil {
+		err = fmt.Errorf("the patch must be a JSON object")
+	}
+	if err == nil {
+		for field := range patch {
+			if !slices.Contains(fields, field) {
+				err = fmt.Errorf("%s cannot be patched, fields are %s", field, strings.Join(fields, ", "))
+				break
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return patch, true
+}
+
+// decode field into *dest when the patch has it, null is only allowed when
+// nullable and then sets the zero value
+func patchField[T any](patch mergePatch, field string, nullable bool, dest **T) error {
+	raw, ok := patch[field]
+	if !ok {
+		return nil
+	}
+	value := new(T)
+	if string(raw) == "null" {
+		if !nullable {
+			return fmt.Errorf("%s cannot be null", field)
+		}
+		*dest = value
+		return nil
+	}
+	if err := json.Unmarshal(raw, value); err != nil {
+		return fmt.Errorf("invalid %s", field)
+	}
+	*dest = value
+	return nil
+}
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// GET /products/:id/price-history, oldest first
+func (s *Server) ViewPriceHistory(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	changes, err := s.Prices.ListPriceHistory(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving price history", "No product found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Price History": changes,
+	})
+}
+
+// GET /products/:id/scheduled-prices, by start time
+func (s *Server) ViewScheduledPrices(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	schedules, err := s.Prices.ListScheduledPrices(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving scheduled prices", "No product found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Scheduled Prices": schedules,
+	})
+}
+
+// POST /products/:id/scheduled-prices, with ends_at the price is a promotion
+// and the old price comes back at ends_at
+func (s *Server) SchedulePrice(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Price    *decimal.Decimal `json:"price" binding:"required"`
+		StartsAt time.Time        `json:"starts_at" binding:"required"`
+		EndsAt   *time.Time       `json:"ends_at"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+	details := ""
+	switch {
+	case body.Price.IsNegative():
+		details = "price must not be negative"
+	case body.EndsAt != nil && !body.EndsAt.After(body.StartsAt):
+		details = "ends_at must be after starts_at"
+	case body.EndsAt != nil && !body.EndsAt.After(time.Now()):
+		details = "ends_at must be in the future"
+	}
+	if details != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid scheduled price",
+			"details": details,
+		})
+		return
+	}
+
+	// stored in TIMESTAMP columns like every other time, so in UTC
+	scheduled := models.ScheduledPrice{ProductID: id, Price: *body.Price, StartsAt: body.StartsAt.UTC()}
+	if body.EndsAt != nil {
+		endsAt := body.EndsAt.UTC()
+		scheduled.EndsAt = &endsAt
+	}
+	if user, ok := currentUser(c); ok {
+		scheduled.CreatedBy = &user.ID
+	}
+
+	if err := s.Prices.SchedulePrice(c.Request.Context(), &scheduled); err != nil {
+		respondStoreError(c, err, "Error scheduling price", "No product found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusCreated, gin.H{
+		"message":         "Price Scheduled Successfully",
+		"Scheduled Price": scheduled,
+	})
+}
+
+// DELETE /products/:id/scheduled-prices/:schedule_id, only before the price took effect
+func (s *Server) CancelScheduledPrice(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+	scheduleID, ok := idParam(c, "schedule_id", "Invalid scheduled price ID")
+	if !ok {
+		return
+	}
+
+	scheduled, err := s.Prices.CancelScheduledPrice(c.Request.Context(), id, scheduleID)
+	if err != nil {
+		respondStoreError(c, err, "Error cancelling scheduled price", "Scheduled price not found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"message":         "Scheduled Price Cancelled",
+		"Scheduled Price": scheduled,
+	})
+}
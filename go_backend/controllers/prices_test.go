@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var scheduledPriceRowColumns = []string{"id", "product_id", "price", "starts_at", "ends_at", "status", "revert_price", "created_by", "created_at"}
+
+func TestViewPriceHistory(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	//mock queries
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	rows := sqlmock.NewRows([]string{"id", "product_id", "old_price", "new_price", "reason", "changed_by", "scheduled_price_id", "created_at"}).
+		AddRow(1, 1, "10.00", "12.00", models.PriceReasonManual, 7, nil, "2024-09-01")
+	mock.ExpectQuery("SELECT (.+) FROM product_prices WHERE product_id = \\$1").WithArgs(1).WillReturnRows(rows)
+
+	w := serve(server.ViewPriceHistory, "GET", "/products/1/price-history", "", gin.Params{{Key: "id", Value: "1"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"reason": "manual"`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSchedulePrice(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+	params := gin.Params{{Key: "id", Value: "1"}}
+
+	//mock queries
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("10.00"))
+	// the start is stored in UTC
+	mock.ExpectQuery("INSERT INTO scheduled_prices").WithArgs(1, sqlmock.AnyArg(), time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), nil, nil).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns).AddRow(1, 1, "15.00", time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), nil, models.ScheduledPricePending, nil, nil, "2024-09-01"))
+	mock.ExpectCommit()
+
+	w := serve(server.SchedulePrice, "POST", "/products/1/scheduled-prices", `{"price": "15.00", "starts_at": "2099-01-01T02:00:00+02:00"}`, params)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// invalid bodies never reach the store
+	for _, body := range []string{
+		`{"starts_at": "2099-01-01T00:00:00Z"}`,
+		`{"price": "-1", "starts_at": "2099-01-01T00:00:00Z"}`,
+		`{"price": "8", "starts_at": "2099-01-02T00:00:00Z", "ends_at": "2099-01-01T00:00:00Z"}`,
+		`{"price": "8", "starts_at": "2020-01-01T00:00:00Z", "ends_at": "2020-01-02T00:00:00Z"}`,
+	} {
+		w = serve(server.SchedulePrice, "POST", "/products/1/scheduled-prices", body, params)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCancelScheduledPrice(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+	params := gin.Params{{Key: "id", Value: "1"}, {Key: "schedule_id", Value: "3"}}
+
+	//mock queries, the promotion already started
+	mock.ExpectQuery("UPDATE scheduled_prices SET status = \\$1").WithArgs(models.ScheduledPriceCancelled, 3, 1, models.ScheduledPricePending).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns))
+	mock.ExpectQuery("SELECT status FROM scheduled_prices").WithArgs(3, 1).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.ScheduledPriceActive))
+
+	w := serve(server.CancelScheduledPrice, "DELETE", "/products/1/scheduled-prices/3", "", params)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1,39 +1,27 @@
 package controllers
 
 import (
-	"context"
-	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
+	"small_business/models"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
-	_ "github.com/lib/pq" //avoid import postgres error with sql
 	"github.com/shopspring/decimal"
 )
 
-// var pool *sql.DB // Database connection pool
-
-type Product struct {
-	ID           int64           `json:"id"`
-	Name         string          `json:"name"`
-	Description  string          `json:"description"`
-	SupplierID   int64           `json: "supplier_id"`
-	Price        decimal.Decimal `json: "price"`
-	Stock        int             `json:"stock"`
-	MinimumStock int             `json:"minimum_stock"`
-	CreatedAt    string          `json: created_at`
-	DeletedAt    string          `json: deleted_at`
-}
-
-func InsertProduct(c *gin.Context) {
+func (s *Server) InsertProduct(c *gin.Context) {
 	var body struct {
 		Name         string          `json:"name"`
+		SKU          string          `json:"sku"`
+		Barcode      string          `json:"barcode"`
 		Description  string          `json:"description"`
 		SupplierID   int64           `json:"supplier_id"`
 		Price        decimal.Decimal `json:"price"`
+		Cost         decimal.Decimal `json:"cost"`
 		Stock        int             `json:"stock"`
 		MinimumStock int             `json:"minimum_stock"`
 	}
@@ -45,32 +33,41 @@ func InsertProduct(c *gin.Context) {
 		})
 		return
 	}
+	// the same rules as a PATCH, the checks trim the body and normalize the barcode
+	checked := models.ProductPatch{
+		Name:         &body.Name,
+		SKU:          &body.SKU,
+		Barcode:      &body.Barcode,
+		Price:        &body.Price,
+		Cost:         &body.Cost,
+		MinimumStock: &body.MinimumStock,
+	}
+	err := checkProductPatch(&checked)
+	if err == nil && body.Stock < 0 {
+		err = errors.New("stock must not be negative")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid product",
+			"details": err.Error(),
+		})
+		return
+	}
 
-	product := Product{
+	product := models.Product{
 		Name:         body.Name,
+		SKU:          body.SKU,
+		Barcode:      *checked.Barcode,
 		Description:  body.Description,
 		SupplierID:   body.SupplierID,
 		Price:        body.Price,
+		Cost:         body.Cost,
 		Stock:        body.Stock,
 		MinimumStock: body.MinimumStock,
 	}
 
-	// Open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
-	}
-
-	defer pool.Close()
-
-	ctx := context.Background()
-	query := "INSERT INTO products (name, description, supplier_id, price, stock, minimum_stock) VALUES($1, $2, $3, $4, $5, $6) RETURNING id"
-	err = pool.QueryRowContext(ctx, query, product.Name, product.Description, product.SupplierID, product.Price, product.Stock, product.MinimumStock).Scan(&product.ID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Error inserting new product",
-			"details": err.Error(),
-		})
+	if err := s.Products.CreateProduct(c.Request.Context(), &product); err != nil {
+		respondStoreError(c, err, "Error inserting new product", "Product not found")
 		return
 	}
 
@@ -80,50 +77,28 @@ func InsertProduct(c *gin.Context) {
 	})
 }
 
-// GET products/:id
-func ViewProductsById(c *gin.Context) {
+// GET products/:id, deleted products only with ?include_deleted=true
+func (s *Server) ViewProductsById(c *gin.Context) {
 
-	idParam := c.Param("id")
-	id, err := strconv.ParseInt(idParam, 10, 64)
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid product ID",
-		})
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
 		return
 	}
-
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
+	includeDeleted, ok := includeDeletedQuery(c)
+	if !ok {
+		return
 	}
 
-	defer pool.Close()
-
-	ctx := context.Background()
-
-	var product Product
-
-	query := "SELECT id, name, description, supplier_id, price, stock, minimum_stock FROM products WHERE id = $1"
-
-	row := pool.QueryRowContext(ctx, query, id)
-
-	// map onto database
-	err = row.Scan(&product.ID, &product.Name, &product.Description, &product.SupplierID, &product.Price, &product.Stock, &product.MinimumStock)
+	product, err := s.Products.GetProduct(c.Request.Context(), id)
+	if err == nil && product.DeletedAt != "" && !includeDeleted {
+		err = models.ErrNotFound
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.IndentedJSON(http.StatusNotFound, gin.H{
-				"message": "No product found",
-			})
-		} else {
-			log.Printf("Error scanning row: %v", err)
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{
-				"error": "Error retrieving product",
-			})
-		}
+		respondStoreError(c, err, "Error retrieving product", "No product found")
 		return
 	}
 
+	setETag(c, product.Version)
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"Product Found": product,
 	})
@@ -132,96 +107,157 @@ func ViewProductsById(c *gin.Context) {
 
 // View all products
 
-func ViewProducts(c *gin.Context) {
-
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
+// GET /products, filtered by ?supplier_id=, ?min_price=, ?max_price=, ?below_minimum=true and ?include_deleted=true
+func (s *Server) ViewProducts(c *gin.Context) {
+	filter := models.ProductFilter{}
+	var ok bool
+	if filter.Sort, filter.Page, ok = listQuery(c, models.ProductSortKeys); !ok {
+		return
 	}
-
-	defer pool.Close()
-
-	query := "SELECT * FROM products"
-
-	rows, err := pool.Query(query) //uses ctx internally
-	if err != nil {
-		print(err)
+	if filter.IncludeDeleted, ok = includeDeletedQuery(c); !ok {
+		return
 	}
-	defer rows.Close()
-
-	var products []Product
-
-	// Loop through rows and map onto databases
-	for rows.Next() {
-		var product Product
-
-		if err := rows.Scan(&product.ID, &product.Name, &product.Description, &product.SupplierID, &product.Price, &product.Stock, &product.MinimumStock, &product.CreatedAt, &product.DeletedAt); err != nil {
-			c.IndentedJSON(http.StatusBadRequest, gin.H{
-				"Error retrieving products": err,
+	if value := c.Query("supplier_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid supplier ID",
 			})
-			log.Print("Error inserting new product", err)
 			return
 		}
-		products = append(products, product)
+		filter.SupplierID = id
 	}
-	if err == sql.ErrNoRows {
-		c.IndentedJSON(http.StatusNotFound, gin.H{
-			"message": "No products found",
-		})
+	if filter.MinPrice, ok = priceQuery(c, "min_price"); !ok {
+		return
+	}
+	if filter.MaxPrice, ok = priceQuery(c, "max_price"); !ok {
+		return
+	}
+	if value := c.Query("below_minimum"); value != "" {
+		below, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "below_minimum must be true or false",
+			})
+			return
+		}
+		filter.BelowMinimum = below
+	}
+
+	products, total, err := s.Products.ListProducts(c.Request.Context(), filter)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving products", "No products found")
+		return
 	}
+
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"Products Found": products,
+		"Pagination":     pagination(filter.Page, total),
 	})
 
 }
 
-func DeleteProductByID(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseInt(idParam, 10, 64)
-	if err != nil {
+// GET /products/by-barcode/:code, takes EAN-13 and UPC-A codes as scanned
+func (s *Server) ViewProductByBarcode(c *gin.Context) {
+	barcode, ok := models.NormalizeBarcode(c.Param("code"))
+	if !ok {
 		c.IndentedJSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid product ID",
+			"message": "Invalid barcode",
 		})
 		return
 	}
+	includeDeleted, ok := includeDeletedQuery(c)
+	if !ok {
+		return
+	}
 
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	product, err := s.Products.GetProductByBarcode(c.Request.Context(), barcode)
+	if err == nil && product.DeletedAt != "" && !includeDeleted {
+		err = models.ErrNotFound
+	}
 	if err != nil {
-		log.Fatal("Error opening database connection")
+		respondStoreError(c, err, "Error retrieving product", "No product found with this barcode")
+		return
 	}
 
-	defer pool.Close()
-
-	ctx := context.Background()
-
-	// var supplier Supplier
+	setETag(c, product.Version)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Product Found": product,
+	})
+}
 
-	query := "DELETE FROM products WHERE id = $1 RETURNING id"
+// check and normalize an optional barcode from a request body, responds 400 itself
+func barcodeValue(c *gin.Context, code string) (string, bool) {
+	if code == "" {
+		return "", true
+	}
+	barcode, ok := models.NormalizeBarcode(code)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid barcode",
+			"details": "barcode must be an EAN-13 or UPC-A code with a correct check digit",
+		})
+		return "", false
+	}
+	return barcode, true
+}
 
-	result, err := pool.ExecContext(ctx, query, id)
+// results of GET /products/search without a ?limit=
+const defaultSearchLimit = 20
 
-	if err != nil {
+// GET /products/search?q=, products whose name or description has words starting with the ones in q, best match first
+func (s *Server) SearchProducts(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"Error removing product": err.Error(),
+			"message": "q is required",
 		})
-		log.Print("Error removing product", err)
+		return
+	}
+	limit, ok := limitQuery(c, defaultSearchLimit)
+	if !ok {
 		return
 	}
 
-	rows, err := result.RowsAffected()
+	matches, err := s.Products.SearchProducts(c.Request.Context(), query, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"Error getting affected rows": err.Error(),
-		})
+		respondStoreError(c, err, "Error searching products", "No products found")
 		return
 	}
 
-	if rows != 1 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "Product not found",
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Products Found": matches,
+	})
+}
+
+// parse an optional price bound from the query, responds 400 itself
+func priceQuery(c *gin.Context, name string) (decimal.NullDecimal, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return decimal.NullDecimal{}, true
+	}
+	price, err := decimal.NewFromString(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid " + name,
 		})
+		return decimal.NullDecimal{}, false
+	}
+	return decimal.NewNullDecimal(price), true
+}
+
+func (s *Server) DeleteProductByID(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	if err := s.Products.DeleteProduct(c.Request.Context(), id, version); err != nil {
+		respondStoreError(c, err, "Error removing product", "Product not found")
 		return
 	}
 
@@ -234,7 +270,49 @@ func DeleteProductByID(c *gin.Context) {
 	})
 }
 
-func UpdateProductPrice(c *gin.Context) {
+// POST /products/restore/:id, brings back a deleted product
+func (s *Server) RestoreProductByID(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	product, err := s.Products.RestoreProduct(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error restoring product", "Product not found")
+		return
+	}
+
+	setETag(c, product.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Product Restored Successfully",
+		"product_information": product,
+	})
+}
+
+// DELETE /products/purge/:id, removes a deleted product for good
+func (s *Server) PurgeProductByID(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	if err := s.Products.PurgeProduct(c.Request.Context(), id, version); err != nil {
+		respondStoreError(c, err, "Error purging product", "Product not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Product Purged Successfully",
+		"Product ID": id,
+	})
+}
+
+func (s *Server) UpdateProductPrice(c *gin.Context) {
 	var body struct {
 		ID    int64           `json:"id"`
 		Price decimal.Decimal `json:"price"`
@@ -248,114 +326,243 @@ func UpdateProductPrice(c *gin.Context) {
 		})
 		return
 	}
+	if err := checkProductPatch(&models.ProductPatch{Price: &body.Price}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid price",
+			"details": err.Error(),
+		})
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 
-	product := Product{ID: body.ID, Price: body.Price}
-
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
+	var userID int64
+	if user, ok := currentUser(c); ok {
+		userID = user.ID
 	}
 
-	defer pool.Close()
+	if err := s.Products.UpdateProductPrice(c.Request.Context(), body.ID, version, body.Price, userID); err != nil {
+		respondStoreError(c, err, "Error updating product price", "Product not found")
+		return
+	}
 
-	ctx := context.Background()
+	fmt.Println("Updating Product Price in database...")
 
-	query := "UPDATE products SET price = $1 WHERE id = $2"
+	// Respond with product information
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Product Price Updated Successfully",
+		"New Product Price": body.Price,
+	})
+}
 
-	result, err := pool.ExecContext(ctx, query, product.Price, product.ID)
+// update product cost by id, the cost values the stock in /reports/valuation
+func (s *Server) UpdateProductCost(c *gin.Context) {
+	var body struct {
+		ID   int64            `json:"id" binding:"required"`
+		Cost *decimal.Decimal `json:"cost" binding:"required"`
+	}
 
-	if err != nil {
+	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"Error updating product price": err.Error(),
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
 		})
-		log.Print("Error updating product price", err)
 		return
 	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"Error getting affected rows": err.Error(),
+	if err := checkProductPatch(&models.ProductPatch{Cost: body.Cost}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid cost",
+			"details": err.Error(),
 		})
 		return
 	}
-
-	if rows != 1 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "Product not found",
-		})
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	fmt.Println("Updating Product Price in database...")
+	if err := s.Products.UpdateProductCost(c.Request.Context(), body.ID, version, *body.Cost); err != nil {
+		respondStoreError(c, err, "Error updating product cost", "Product not found")
+		return
+	}
 
-	// Respond with product information
 	c.JSON(http.StatusOK, gin.H{
-		"message":           "Product Price Updated Successfully",
-		"New Product Price": product.Price,
+		"message":          "Product Cost Updated Successfully",
+		"New Product Cost": body.Cost,
 	})
 }
 
-func UpdateProductStock(c *gin.Context) {
+// PUT /products/change-sku, an empty sku removes it
+func (s *Server) UpdateProductSKU(c *gin.Context) {
 	var body struct {
-		ID    int64 `json:"id"`
-		Stock int   `json:"stock"`
+		ID  int64  `json:"id" binding:"required"`
+		SKU string `json:"sku"`
 	}
 
-	// if error with fields
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
 
-	if err := c.BindJSON(&body); err != nil {
+	if err := checkProductPatch(&models.ProductPatch{SKU: &body.SKU}); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"Error Binding JSON Data": err,
+			"error":   "Invalid SKU",
+			"details": err.Error(),
 		})
 		return
 	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+
+	sku := body.SKU
+	if err := s.Products.UpdateProductSKU(c.Request.Context(), body.ID, version, sku); err != nil {
+		respondStoreError(c, err, "Error updating product SKU", "Product not found")
+		return
+	}
 
-	product := Product{ID: body.ID, Stock: body.Stock}
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "Product SKU Updated Successfully",
+		"New Product SKU": sku,
+	})
+}
 
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
+// PUT /products/change-barcode, an empty barcode removes it
+func (s *Server) UpdateProductBarcode(c *gin.Context) {
+	var body struct {
+		ID      int64  `json:"id" binding:"required"`
+		Barcode string `json:"barcode"`
 	}
 
-	defer pool.Close()
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+	barcode, ok := barcodeValue(c, body.Barcode)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 
-	ctx := context.Background()
+	if err := s.Products.UpdateProductBarcode(c.Request.Context(), body.ID, version, barcode); err != nil {
+		respondStoreError(c, err, "Error updating product barcode", "Product not found")
+		return
+	}
 
-	query := "UPDATE products SET stock = $1 WHERE id = $2"
+	c.JSON(http.StatusOK, gin.H{
+		"message":             "Product Barcode Updated Successfully",
+		"New Product Barcode": barcode,
+	})
+}
 
-	result, err := pool.ExecContext(ctx, query, product.Stock, product.ID)
+// PATCH /products/:id, a JSON Merge Patch of the product. The stock is changed through /stocks.
+func (s *Server) PatchProduct(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid product ID")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	patch, ok := bindMergePatch(c, "name", "sku", "barcode", "description", "supplier_id", "price", "cost", "minimum_stock")
+	if !ok {
+		return
+	}
 
+	var changes models.ProductPatch
+	err := errors.Join(
+		patchField(patch, "name", false, &changes.Name),
+		patchField(patch, "sku", true, &changes.SKU),
+		patchField(patch, "barcode", true, &changes.Barcode),
+		patchField(patch, "description", true, &changes.Description),
+		patchField(patch, "supplier_id", false, &changes.SupplierID),
+		patchField(patch, "price", false, &changes.Price),
+		patchField(patch, "cost", false, &changes.Cost),
+		patchField(patch, "minimum_stock", false, &changes.MinimumStock),
+	)
+	if err == nil {
+		err = checkProductPatch(&changes)
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"Error updating product stock": err.Error(),
+			"error":   "Invalid product patch",
+			"details": err.Error(),
 		})
-		log.Print("Error updating product stock", err)
 		return
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"Error getting affected rows": err.Error(),
-		})
-		return
+	var userID int64
+	if user, ok := currentUser(c); ok {
+		userID = user.ID
 	}
 
-	if rows != 1 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "Product not found",
-		})
+	product, err := s.Products.PatchProduct(c.Request.Context(), id, version, changes, userID)
+	if err != nil {
+		respondStoreError(c, err, "Error updating product", "Product not found")
 		return
 	}
 
-	fmt.Println("Updating Product Stock Number in database...")
-
-	// Respond with product information
-	c.JSON(http.StatusOK, gin.H{
-		"message":           "Product Stock Updated Successfully",
-		"New Product Stock": product.Stock,
+	setETag(c, product.Version)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"message":         "Product Updated Successfully",
+		"Updated Product": product,
 	})
 }
+
+// largest values the product columns hold
+const maxSKULength = 64
+
+var (
+	maxPrice = decimal.RequireFromString("9999.99")
+	maxCost  = decimal.RequireFromString("99999999.99")
+)
+
+// validate the patched values, trimming names and normalizing the barcode
+func checkProductPatch(changes *models.ProductPatch) error {
+	if changes.Name != nil {
+		if *changes.Name = strings.TrimSpace(*changes.Name); *changes.Name == "" {
+			return errors.New("name must not be empty")
+		}
+	}
+	if changes.SKU != nil {
+		if *changes.SKU = strings.TrimSpace(*changes.SKU); utf8.RuneCountInString(*changes.SKU) > maxSKULength {
+			return fmt.Errorf("sku must not be longer than %d characters", maxSKULength)
+		}
+	}
+	if changes.Barcode != nil && *changes.Barcode != "" {
+		barcode, ok := models.NormalizeBarcode(*changes.Barcode)
+		if !ok {
+			return errors.New("barcode must be an EAN-13 or UPC-A code with a correct check digit")
+		}
+		changes.Barcode = &barcode
+	}
+	if changes.Price != nil && changes.Price.IsNegative() {
+		return errors.New("price must not be negative")
+	}
+	if changes.Price != nil && changes.Price.Round(2).GreaterThan(maxPrice) {
+		return fmt.Errorf("price must not be more than %s", maxPrice)
+	}
+	if changes.Cost != nil && changes.Cost.IsNegative() {
+		return errors.New("cost must not be negative")
+	}
+	if changes.Cost != nil && changes.Cost.Round(2).GreaterThan(maxCost) {
+		return fmt.Errorf("cost must not be more than %s", maxCost)
+	}
+	if changes.MinimumStock != nil && *changes.MinimumStock < 0 {
+		return errors.New("minimum_stock must not be negative")
+	}
+	return nil
+}
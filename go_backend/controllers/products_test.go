@@ -1,55 +1,131 @@
 package controllers
 
 import (
-	"database/sql"
+	"net/http"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 )
 
-func createProduct(db *sql.DB, name string, description string, supplierID int, price decimal.Decimal, stock int, minimumStock int) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
+var productRowColumns = []string{"id", "name", "sku", "barcode", "description", "supplier_id", "price", "cost", "stock", "minimum_stock", "created_at", "updated_at", "version", "deleted_at"}
+
+func TestInsertProduct(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	// Define the query we expect to be executed
+	query := "INSERT INTO products \\(name, sku, barcode, description, supplier_id, price, cost, stock, minimum_stock\\) VALUES \\(\\$1, NULLIF\\(\\$2, ''\\), NULLIF\\(\\$3, ''\\), \\$4, \\$5, \\$6, \\$7, \\$8, \\$9\\) RETURNING id"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier WHERE id = \\$1 FOR SHARE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	mock.ExpectQuery(query).WithArgs("testproduct", "TP-1", "0036000291452", "testdescription", 1, decimal.RequireFromString("123.99"), decimal.RequireFromString("80.50"), 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1)) //new row inserted with id 1
+	mock.ExpectExec("INSERT INTO stock_movements").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	body := `{"name": "testproduct", "sku": "TP-1", "barcode": "036000291452", "description": "testdescription", "supplier_id": 1, "price": "123.99", "cost": "80.50", "stock": 2, "minimum_stock": 3}`
+	w := serve(server.InsertProduct, "POST", "/products/insert", body, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":1`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
+}
 
-	query := "INSERT INTO products (name, description, supplier_id, price, stock, minimum_stock) VALUES ($1, $2, $3, $4, $5, $6)"
-	_, err = tx.Exec(query, name, description, supplierID, price, stock, minimumStock)
-	if err != nil {
-		tx.Rollback()
-		return err
+func TestInsertProductInvalid(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	// the same rules as a patch, nothing reaches the database
+	for _, body := range []string{
+		`{"name": " ", "price": "1.00"}`,
+		`{"name": "testproduct", "price": "-1"}`,
+		`{"name": "testproduct", "price": "10000"}`,
+		`{"name": "testproduct", "price": "1.00", "stock": -1}`,
+		`{"name": "testproduct", "price": "1.00", "minimum_stock": -1}`,
+		`{"name": "testproduct", "price": "1.00", "barcode": "12345"}`,
+	} {
+		w := serve(server.InsertProduct, "POST", "/products/insert", body, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
+}
+
+func TestViewProducts(t *testing.T) {
+	t.Parallel()
 
-	return tx.Commit()
+	server, mock := newMockServer(t)
+
+	//mock queries
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM products WHERE deleted_at IS NULL AND supplier_id = \\$1 AND price <= \\$2").
+		WithArgs(1, decimal.RequireFromString("10")).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(2, "testproduct", "", "", "", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery("SELECT (.+) ORDER BY name DESC, id DESC LIMIT \\$3 OFFSET \\$4").
+		WithArgs(1, decimal.RequireFromString("10"), 1, 1).
+		WillReturnRows(rows)
+
+	w := serve(server.ViewProducts, "GET", "/products?supplier_id=1&max_price=10&sort=-name&limit=1&offset=1", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"total": 3`)
+	assert.Contains(t, w.Body.String(), `"next_offset": 2`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
-func TestCreateProduct(t *testing.T) {
+func TestViewProductsInvalidQuery(t *testing.T) {
 	t.Parallel()
 
-	// Create a new mock database connection and sqlmock instance
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	server, _ := newMockServer(t)
+
+	for _, query := range []string{"sort=description", "limit=0", "limit=1000", "offset=-1", "min_price=cheap", "below_minimum=maybe", "supplier_id=x", "include_deleted=maybe"} {
+		w := serve(server.ViewProducts, "GET", "/products?"+query, "", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
 	}
-	defer db.Close()
 
-	// Define the query we expect to be executed
-	query := "INSERT INTO products \\(name, description, supplier_id, price, stock, minimum_stock\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\)"
+	// only admins see deleted products
+	w := serve(server.ViewProducts, "GET", "/products?include_deleted=true", "", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
 
-	// Begin a new transaction
-	mock.ExpectBegin()
+func TestPatchProduct(t *testing.T) {
+	t.Parallel()
 
-	// mock query
-	mock.ExpectExec(query).WithArgs("testproduct", "testdescription", 1, decimal.NewFromFloat(123.99), 2, 3).WillReturnResult(sqlmock.NewResult(1, 1)) //new row inserted with id 1
+	server, mock := newMockServer(t)
 
-	// Commit the transaction
+	//mock query, null removes the description and fields left out are not touched
+	query := "UPDATE products SET name = \\$1, description = NULLIF\\(\\$2, ''\\), minimum_stock = \\$3, updated_at = NOW\\(\\) WHERE id = \\$4 AND deleted_at IS NULL RETURNING (.+)"
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(1, "newname", "", "", "", 1, "9.99", "0", 2, 5, "2024-08-01", "2024-09-01", 2, nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery(query).WithArgs("newname", "", 5, 1).WillReturnRows(rows)
 	mock.ExpectCommit()
 
-	// Call the function to create a product
-	err = createProduct(db, "testproduct", "testdescription", 1, decimal.NewFromFloat(123.99), 2, 3) //avoid decimal errors
-	assert.NoError(t, err)
+	body := `{"name": " newname ", "description": null, "minimum_stock": 5}`
+	w := serveWithHeader(server.PatchProduct, "PATCH", "/products/1", body, gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"minimum_stock": 5`)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -57,82 +133,164 @@ func TestCreateProduct(t *testing.T) {
 	}
 }
 
-func getProductByID(db *sql.DB, id int) (*Product, error) {
-	query := "SELECT id, name, description, supplier_id, price, stock, minimum_stock FROM products WHERE id = $1"
-	row := db.QueryRow(query, id)
+func TestPatchProductInvalid(t *testing.T) {
+	t.Parallel()
 
-	var product Product
-	err := row.Scan(&product.ID, &product.Name, &product.Description, &product.SupplierID, &product.Price, &product.Stock, &product.MinimumStock)
-	if err != nil {
-		return nil, err
+	server, _ := newMockServer(t)
+
+	for _, body := range []string{
+		``,
+		`[]`,
+		`{"stock": 5}`,
+		`{"name": null}`,
+		`{"name": "  "}`,
+		`{"price": "cheap"}`,
+		`{"price": "-1"}`,
+		`{"minimum_stock": -1}`,
+		`{"barcode": "4006381333932"}`,
+	} {
+		w := serveWithHeader(server.PatchProduct, "PATCH", "/products/1", body, gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
 	}
-
-	return &product, nil
 }
 
-func TestGetProductByID(t *testing.T) {
+func TestViewProductByBarcode(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	server, mock := newMockServer(t)
+
+	//mock query, the scanned UPC-A code is looked up as EAN-13
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(1, "testproduct", "TP-1", "0036000291452", "", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE barcode = \\$1").WithArgs("0036000291452").WillReturnRows(rows)
+
+	w := serve(server.ViewProductByBarcode, "GET", "/products/by-barcode/036000291452", "", gin.Params{{Key: "code", Value: "036000291452"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"sku": "TP-1"`)
+
+	// a wrong check digit never reaches the database
+	w = serve(server.ViewProductByBarcode, "GET", "/products/by-barcode/036000291453", "", gin.Params{{Key: "code", Value: "036000291453"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
-	defer db.Close()
+}
+
+func TestUpdateProductBarcode(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
 
 	//mock query
-	query := "SELECT id, name, description, supplier_id, price, stock, minimum_stock FROM products WHERE id = \\$1"
-	rows := sqlmock.NewRows([]string{"id", "name", "description", "supplier_id", "price", "stock", "minimum_stock"}).
-		AddRow(1, "testproduct", "testdescription", 1, 999, 2, 3)
+	mock.ExpectExec("UPDATE products SET barcode = NULLIF\\(\\$1, ''\\)").WithArgs("4006381333931", 1, 1).
+		WillReturnError(&pq.Error{Code: "23505", Detail: "Key (barcode)=(4006381333931) already exists."})
 
-	mock.ExpectQuery(query).WithArgs(1).WillReturnRows(rows)
+	w := serveWithHeader(server.UpdateProductBarcode, "PUT", "/products/change-barcode", `{"id": 1, "barcode": "4006381333931"}`, nil, matchFirstVersion)
+	assert.Equal(t, http.StatusConflict, w.Code)
 
-	// assert that not nil and equal user email
-	product, err := getProductByID(db, 1)
-	assert.NoError(t, err)
-	assert.NotNil(t, product)
-	assert.EqualValues(t, 1, product.ID)
+	w = serve(server.UpdateProductBarcode, "PUT", "/products/change-barcode", `{"id": 1, "barcode": "12345"}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 
-	// Ensure that all expectations were met
+	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
 
-func deleteProductByID(db *sql.DB, id int64) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
+func TestSearchProducts(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	//mock query
+	rows := sqlmock.NewRows(append(productRowColumns, "rank")).
+		AddRow(1, "testproduct", "", "", "", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-08-01", 1, nil, 0.5)
+	mock.ExpectQuery("to_tsquery").WithArgs("test:*", "test", 20).WillReturnRows(rows)
+
+	w := serve(server.SearchProducts, "GET", "/products/search?q=test", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"rank": 0.5`)
+
+	w = serve(server.SearchProducts, "GET", "/products/search", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
+}
 
-	query := "DELETE FROM products WHERE id = $1"
-	_, err = tx.Exec(query, id)
-	if err != nil {
-		tx.Rollback()
-		return err
+func TestViewProductsById(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	//mock query
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(1, "testproduct", "", "", "testdescription", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE id = \\$1").WithArgs(1).WillReturnRows(rows)
+
+	w := serve(server.ViewProductsById, "GET", "/products/1", "", gin.Params{{Key: "id", Value: "1"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "testproduct")
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
+}
+
+func TestViewProductsByIdNotFound(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
 
-	return tx.Commit()
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE id = \\$1").WithArgs(9).WillReturnRows(sqlmock.NewRows(productRowColumns))
+
+	w := serve(server.ViewProductsById, "GET", "/products/9", "", gin.Params{{Key: "id", Value: "9"}})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestViewProductsByIdInvalidID(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.ViewProductsById, "GET", "/products/abc", "", gin.Params{{Key: "id", Value: "abc"}})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
 func TestDeleteProductByID(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	server, mock := newMockServer(t)
 
+	// mock queries
 	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery("SELECT p.id FROM products p").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE products SET deleted_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	// mock query
-	query := "DELETE FROM products WHERE id = \\$1"
-	mock.ExpectExec(query).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1)) // return no new row with index 1
+	w := serveWithHeader(server.DeleteProductByID, "DELETE", "/products/remove/1", "", gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
 
-	mock.ExpectCommit()
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// a confirmed sales order still waits for its stock
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery("SELECT p.id FROM products p").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectRollback()
 
-	err = deleteProductByID(db, 1)
-	assert.NoError(t, err)
+	w = serveWithHeader(server.DeleteProductByID, "DELETE", "/products/remove/1", "", gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
+	assert.Equal(t, http.StatusConflict, w.Code)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -140,42 +298,55 @@ func TestDeleteProductByID(t *testing.T) {
 	}
 }
 
-func updateProductPrice(db *sql.DB, price decimal.Decimal, id int64) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
+func TestViewDeletedProductByID(t *testing.T) {
+	t.Parallel()
 
-	query := "UPDATE products SET price = $1 WHERE id = $2"
-	_, err = tx.Exec(query, price, id)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+	server, mock := newMockServer(t)
+
+	//mock query, a deleted product is not found without ?include_deleted=true
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(1, "testproduct", "", "", "", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-09-01", 2, "2024-09-01")
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE id = \\$1").WithArgs(1).WillReturnRows(rows)
+
+	w := serve(server.ViewProductsById, "GET", "/products/1", "", gin.Params{{Key: "id", Value: "1"}})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serve(server.ViewProductsById, "GET", "/products/1?include_deleted=true", "", gin.Params{{Key: "id", Value: "1"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
 
-	return tx.Commit()
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
-func TestUpdateProductPrice(t *testing.T) {
+func TestRestoreProductByID(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	server, mock := newMockServer(t)
 
+	//mock queries
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(1, "testproduct", "", "", "", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-09-01", 3, nil)
 	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL, version FROM products WHERE id = \\$1 FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 2))
+	mock.ExpectQuery("UPDATE products SET deleted_at = NULL").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	mock.ExpectCommit()
 
-	//mock query
-
-	query := "UPDATE products SET price = \\$1 WHERE id = \\$2"
-	mock.ExpectExec(query).WithArgs(decimal.NewFromFloat(999.99), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	w := serve(server.RestoreProductByID, "POST", "/products/restore/1", "", gin.Params{{Key: "id", Value: "1"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
 
-	mock.ExpectCommit()
+	// a product that is not deleted
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL, version FROM products").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(false, 1))
+	mock.ExpectRollback()
 
-	err = updateProductPrice(db, decimal.NewFromFloat(999.99), 1)
-	assert.NoError(t, err)
+	w = serve(server.RestoreProductByID, "POST", "/products/restore/2", "", gin.Params{{Key: "id", Value: "2"}})
+	assert.Equal(t, http.StatusConflict, w.Code)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -183,42 +354,103 @@ func TestUpdateProductPrice(t *testing.T) {
 	}
 }
 
-func updateProductStock(db *sql.DB, stock int, id int64) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
+func TestPurgeProductByID(t *testing.T) {
+	t.Parallel()
 
-	query := "UPDATE products SET stock = $1 WHERE id = $2"
-	_, err = tx.Exec(query, stock, id)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+	server, mock := newMockServer(t)
+
+	//mock queries, sold products stay
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL, version FROM products WHERE id = \\$1 FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 1))
+	mock.ExpectExec("DELETE FROM products WHERE id = \\$1").WithArgs(1).
+		WillReturnError(&pq.Error{Code: "23503", Detail: "Key (id)=(1) is still referenced from table \"sales_order_lines\"."})
+	mock.ExpectRollback()
+
+	w := serveWithHeader(server.PurgeProductByID, "DELETE", "/products/purge/1", "", gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "sales_order_lines")
+
+	w = serve(server.PurgeProductByID, "DELETE", "/products/purge/1", "", gin.Params{{Key: "id", Value: "1"}})
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
 
-	return tx.Commit()
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
-func TestUpdateProductStock(t *testing.T) {
+func TestUpdateProductPrice(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	server, mock := newMockServer(t)
 
+	//mock queries
 	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("10.00"))
+	query := "UPDATE products SET price = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs(decimal.RequireFromString("999.99"), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO product_prices").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "product_id", "old_price", "new_price", "reason", "changed_by", "scheduled_price_id", "created_at"}).
+			AddRow(1, 1, "10.00", "999.99", "manual", nil, nil, "2024-09-01"))
+	mock.ExpectCommit()
+
+	w := serveWithHeader(server.UpdateProductPrice, "PUT", "/products/change-price", `{"id": 1, "price": "999.99"}`, nil, matchFirstVersion)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = serveWithHeader(server.UpdateProductPrice, "PUT", "/products/change-price", `{"id": 1, "price": "-1"}`, nil, matchFirstVersion)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateProductCost(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
 
 	//mock query
+	query := "UPDATE products SET cost = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs(decimal.RequireFromString("45.10"), 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
 
-	query := "UPDATE products SET stock = \\$1 WHERE id = \\$2"
-	mock.ExpectExec(query).WithArgs(2, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	w := serveWithHeader(server.UpdateProductCost, "PUT", "/products/change-cost", `{"id": 1, "cost": "45.10"}`, nil, matchFirstVersion)
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	mock.ExpectCommit()
+	w = serveWithHeader(server.UpdateProductCost, "PUT", "/products/change-cost", `{"id": 1, "cost": "-1"}`, nil, matchFirstVersion)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateProductCostPreconditions(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+	body := `{"id": 1, "cost": "45.10"}`
+
+	//mock queries, someone else changed the product since it was read
+	query := "UPDATE products SET cost = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2 AND version = \\$3"
+	mock.ExpectExec(query).WithArgs(decimal.RequireFromString("45.10"), 1, 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM products WHERE id = \\$1 AND deleted_at IS NULL\\)").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	w := serveWithHeader(server.UpdateProductCost, "PUT", "/products/change-cost", body, nil, matchFirstVersion)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = serve(server.UpdateProductCost, "PUT", "/products/change-cost", body, nil)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
 
-	err = updateProductStock(db, 2, 1)
-	assert.NoError(t, err)
+	// weak ETags never match
+	w = serveWithHeader(server.UpdateProductCost, "PUT", "/products/change-cost", body, nil, http.Header{"If-Match": {`W/"1"`}})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"small_business/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// POST /purchase-orders, creates a draft
+func (s *Server) CreatePurchaseOrder(c *gin.Context) {
+	var body struct {
+		SupplierID int64  `json:"supplier_id" binding:"required"`
+		Notes      string `json:"notes" binding:"max=255"`
+		Lines      []struct {
+			ProductID int64           `json:"product_id" binding:"required"`
+			Quantity  int             `json:"quantity" binding:"required,min=1"`
+			UnitCost  decimal.Decimal `json:"unit_cost"`
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	order := models.PurchaseOrder{SupplierID: body.SupplierID, Notes: body.Notes}
+	for _, line := range body.Lines {
+		if line.UnitCost.IsNegative() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid unit cost",
+				"details": "unit_cost must not be negative",
+			})
+			return
+		}
+		order.Lines = append(order.Lines, models.PurchaseOrderLine{ProductID: line.ProductID, QuantityOrdered: line.Quantity, UnitCost: line.UnitCost})
+	}
+	if user, ok := currentUser(c); ok {
+		order.CreatedBy = &user.ID
+	}
+
+	if err := s.PurchaseOrders.CreatePurchaseOrder(c.Request.Context(), &order); err != nil {
+		respondStoreError(c, err, "Error creating purchase order", "Supplier not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":        "Purchase order created as draft",
+		"Purchase Order": order,
+	})
+}
+
+// GET /purchase-orders?status=submitted&supplier_id=1
+func (s *Server) ViewPurchaseOrders(c *gin.Context) {
+	filter := models.PurchaseOrderFilter{Status: c.Query("status")}
+	if filter.Status != "" && !models.ValidPOStatus(filter.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid status",
+		})
+		return
+	}
+	if value := c.Query("supplier_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid supplier ID",
+			})
+			return
+		}
+		filter.SupplierID = id
+	}
+
+	orders, err := s.PurchaseOrders.ListPurchaseOrders(c.Request.Context(), filter)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving purchase orders", "No purchase orders found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Purchase Orders Found": orders,
+	})
+}
+
+// GET /purchase-orders/:id, with lines and receipts
+func (s *Server) ViewPurchaseOrder(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid purchase order ID")
+	if !ok {
+		return
+	}
+
+	order, err := s.PurchaseOrders.GetPurchaseOrder(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving purchase order", "Purchase order not found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Purchase Order": order,
+	})
+}
+
+// POST /purchase-orders/:id/submit, draft -> submitted
+func (s *Server) SubmitPurchaseOrder(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid purchase order ID")
+	if !ok {
+		return
+	}
+
+	order, err := s.PurchaseOrders.SubmitPurchaseOrder(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error submitting purchase order", "Purchase order not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Purchase order submitted",
+		"Purchase Order": order,
+	})
+}
+
+// POST /purchase-orders/:id/cancel, only before anything was received
+func (s *Server) CancelPurchaseOrder(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid purchase order ID")
+	if !ok {
+		return
+	}
+
+	order, err := s.PurchaseOrders.CancelPurchaseOrder(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error cancelling purchase order", "Purchase order not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Purchase order cancelled",
+		"Purchase Order": order,
+	})
+}
+
+// POST /purchase-orders/:id/receive, without a body everything outstanding is received
+func (s *Server) ReceivePurchaseOrder(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid purchase order ID")
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Lines []struct {
+			ProductID int64            `json:"product_id" binding:"required"`
+			Quantity  int              `json:"quantity" binding:"required,min=1"`
+			UnitCost  *decimal.Decimal `json:"unit_cost"` // defaults to the ordered cost
+		} `json:"lines" binding:"omitempty,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var lines []models.ReceiptLine
+	for _, line := range body.Lines {
+		if line.UnitCost != nil && line.UnitCost.IsNegative() {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid unit cost",
+				"details": "unit_cost must not be negative",
+			})
+			return
+		}
+		lines = append(lines, models.ReceiptLine{ProductID: line.ProductID, Quantity: line.Quantity, UnitCost: line.UnitCost})
+	}
+
+	var userID int64
+	if user, ok := currentUser(c); ok {
+		userID = user.ID
+	}
+
+	order, levels, err := s.PurchaseOrders.ReceivePurchaseOrder(c.Request.Context(), id, lines, userID)
+	if err != nil {
+		respondStoreError(c, err, "Error receiving purchase order", "Purchase order not found")
+		return
+	}
+	for _, level := range levels {
+		s.stockChanged(level)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Purchase order received",
+		"Purchase Order": order,
+		"Stock Levels":   levels,
+	})
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreatePurchaseOrder(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	created := "2024-08-20T12:00:00Z"
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO purchase_orders").WithArgs(1, models.POStatusDraft, "restock", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "status", "notes", "created_by", "submitted_at", "received_at", "created_at", "updated_at"}).
+			AddRow(1, 1, models.POStatusDraft, "restock", nil, nil, nil, created, created))
+	mock.ExpectQuery("SELECT supplier_id FROM products").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"supplier_id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO purchase_order_lines").WithArgs(1, 2, 10, decimal.RequireFromString("4.25")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	body := `{"supplier_id": 1, "notes": "restock", "lines": [{"product_id": 2, "quantity": 10, "unit_cost": "4.25"}]}`
+	w := serve(server.CreatePurchaseOrder, "POST", "/purchase-orders/", body, nil)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"draft"`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreatePurchaseOrderValidation(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	// no lines
+	w := serve(server.CreatePurchaseOrder, "POST", "/purchase-orders/", `{"supplier_id": 1, "lines": []}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// zero quantity
+	w = serve(server.CreatePurchaseOrder, "POST", "/purchase-orders/", `{"supplier_id": 1, "lines": [{"product_id": 2, "quantity": 0}]}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// negative cost
+	w = serve(server.CreatePurchaseOrder, "POST", "/purchase-orders/", `{"supplier_id": 1, "lines": [{"product_id": 2, "quantity": 1, "unit_cost": "-1"}]}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestReceivePurchaseOrderNotSubmitted(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM purchase_orders WHERE id = \\$1 FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.POStatusDraft))
+	mock.ExpectRollback()
+
+	// no body receives everything, but a draft cannot be received
+	w := serve(server.ReceivePurchaseOrder, "POST", "/purchase-orders/1/receive", "", gin.Params{{Key: "id", Value: "1"}})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestViewPurchaseOrdersInvalidStatus(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.ViewPurchaseOrders, "GET", "/purchase-orders/?status=lost", "", nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"small_business/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// how far back consumption is looked at by default
+const defaultUsageWindowDays = 30
+
+// load the candidates and turn them into suggestions over the last days
+func (s *Server) reorderSuggestions(c *gin.Context, days int) ([]models.SupplierSuggestions, error) {
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	candidates, err := s.PurchaseOrders.ListReorderCandidates(c.Request.Context(), since)
+	if err != nil {
+		return nil, err
+	}
+	return models.SuggestReorders(candidates, days), nil
+}
+
+// GET /purchasing/suggestions?days=30
+func (s *Server) ViewReorderSuggestions(c *gin.Context) {
+	days, ok := daysQuery(c, defaultUsageWindowDays)
+	if !ok {
+		return
+	}
+
+	suggestions, err := s.reorderSuggestions(c, days)
+	if err != nil {
+		respondStoreError(c, err, "Error calculating reorder suggestions", "No products found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Reorder Suggestions": suggestions,
+		"days":                days,
+	})
+}
+
+// POST /purchasing/suggestions/orders, one draft purchase order per supplier
+func (s *Server) CreateSuggestedPurchaseOrders(c *gin.Context) {
+	var body struct {
+		Days        int     `json:"days" binding:"omitempty,min=1,max=365"`
+		SupplierIDs []int64 `json:"supplier_ids"` // only these suppliers, all when empty
+	}
+
+	// body is optional
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+	if body.Days == 0 {
+		body.Days = defaultUsageWindowDays
+	}
+
+	suggestions, err := s.reorderSuggestions(c, body.Days)
+	if err != nil {
+		respondStoreError(c, err, "Error calculating reorder suggestions", "No products found")
+		return
+	}
+
+	var createdBy *int64
+	if user, ok := currentUser(c); ok {
+		createdBy = &user.ID
+	}
+
+	orders := []models.PurchaseOrder{}
+	for _, group := range suggestions {
+		if len(body.SupplierIDs) > 0 && !slices.Contains(body.SupplierIDs, group.SupplierID) {
+			continue
+		}
+
+		order := models.PurchaseOrder{SupplierID: group.SupplierID, Notes: "Created from reorder suggestions", CreatedBy: createdBy}
+		for _, line := range group.Lines {
+			order.Lines = append(order.Lines, models.PurchaseOrderLine{ProductID: line.ProductID, QuantityOrdered: line.Quantity, UnitCost: line.UnitCost})
+		}
+
+		orders = append(orders, order)
+	}
+
+	// all orders or none, so a failed request can simply be retried
+	if len(orders) > 0 {
+		if err := s.PurchaseOrders.CreatePurchaseOrders(c.Request.Context(), orders); err != nil {
+			respondStoreError(c, err, "Error creating purchase orders", "Supplier not found")
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":         "Draft purchase orders created",
+		"Purchase Orders": orders,
+	})
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var reorderCandidateColumns = []string{"id", "name", "stock", "minimum_stock", "supplier_id", "supplier_name", "lead_time_days", "consumed", "on_order", "unit_cost"}
+
+func TestViewReorderSuggestions(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	rows := sqlmock.NewRows(reorderCandidateColumns).
+		AddRow(1, "testproduct", 1, 4, 1, "testsupplier", 10, 20, 0, "2.50")
+	// products of deleted suppliers are not reordered
+	mock.ExpectQuery("FROM products p JOIN supplier s ON s.id = p.supplier_id\\s+WHERE p.stock - p.reserved <= p.minimum_stock AND p.deleted_at IS NULL AND s.deleted_at IS NULL").
+		WithArgs(sqlmock.AnyArg()).WillReturnRows(rows)
+
+	w := serve(server.ViewReorderSuggestions, "GET", "/purchasing/suggestions?days=10", "", nil)
+
+	// 2 a day over 10 days lead time: 4 + 20 - 1 = 23
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"quantity": 23`)
+	assert.Contains(t, w.Body.String(), `"daily_usage": 2`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestViewReorderSuggestionsInvalidDays(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.ViewReorderSuggestions, "GET", "/purchasing/suggestions?days=0", "", nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCreateSuggestedPurchaseOrders(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	rows := sqlmock.NewRows(reorderCandidateColumns).
+		AddRow(1, "testproduct", 0, 3, 1, "testsupplier", 7, 0, 0, "2.50").
+		AddRow(2, "otherproduct", 0, 3, 2, "othersupplier", 7, 0, 0, "1.00")
+	mock.ExpectQuery("FROM products p JOIN supplier s").WillReturnRows(rows)
+
+	// only supplier 2 was asked for
+	created := "2024-08-25T12:00:00Z"
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO purchase_orders").WithArgs(2, "draft", "Created from reorder suggestions", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "supplier_id", "status", "notes", "created_by", "submitted_at", "received_at", "created_at", "updated_at"}).
+			AddRow(1, 2, "draft", "Created from reorder suggestions", nil, nil, nil, created, created))
+	mock.ExpectQuery("SELECT supplier_id FROM products").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"supplier_id"}).AddRow(2))
+	mock.ExpectQuery("INSERT INTO purchase_order_lines").WithArgs(1, 2, 3, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	w := serve(server.CreateSuggestedPurchaseOrders, "POST", "/purchasing/suggestions/orders", `{"supplier_ids": [2]}`, nil)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateSuggestedPurchaseOrdersAllOrNothing(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	rows := sqlmock.NewRows(reorderCandidateColumns).
+		AddRow(1, "testproduct", 0, 3, 1, "testsupplier", 7, 0, 0, "2.50").
+		AddRow(2, "otherproduct", 0, 3, 2, "othersupplier", 7, 0, 0, "1.00")
+	mock.ExpectQuery("FROM products p JOIN supplier s").WillReturnRows(rows)
+
+	// the second order fails, so the first one is rolled back with it
+	created := "2024-08-25T12:00:00Z"
+	orderColumns := []string{"id", "supplier_id", "status", "notes", "created_by", "submitted_at", "received_at", "created_at", "updated_at"}
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO purchase_orders").WithArgs(1, "draft", "Created from reorder suggestions", nil).
+		WillReturnRows(sqlmock.NewRows(orderColumns).AddRow(1, 1, "draft", "Created from reorder suggestions", nil, nil, nil, created, created))
+	mock.ExpectQuery("SELECT supplier_id FROM products").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"supplier_id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO purchase_order_lines").WithArgs(1, 1, 3, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO purchase_orders").WithArgs(2, "draft", "Created from reorder suggestions", nil).
+		WillReturnRows(sqlmock.NewRows(orderColumns).AddRow(2, 2, "draft", "Created from reorder suggestions", nil, nil, nil, created, created))
+	mock.ExpectQuery("SELECT supplier_id FROM products").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"supplier_id"}))
+	mock.ExpectRollback()
+
+	w := serve(server.CreateSuggestedPurchaseOrders, "POST", "/purchasing/suggestions/orders", "", nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /reports/valuation?method=fifo&as_of=2024-08-31, stock value at cost and
+// at retail per supplier. as_of replays the stock ledger up to the end of that day.
+func (s *Server) ViewValuationReport(c *gin.Context) {
+	method := c.DefaultQuery("method", models.CostingStandard)
+	if !models.ValidCostingMethod(method) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "method must be standard, fifo or average",
+		})
+		return
+	}
+
+	asOf, err := parseTimeQuery(c.Query("as_of"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid as_of parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+	if asOf.IsZero() {
+		asOf = time.Now().UTC()
+	}
+
+	inputs, err := s.Analytics.ListValuationInputs(c.Request.Context(), asOf)
+	if err != nil {
+		respondStoreError(c, err, "Error calculating stock valuation", "No products found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Valuation": models.ValueStock(inputs, method),
+		"as_of":     asOf.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestViewValuationReport(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectQuery("SELECT p.id, p.name, s.id, s.name,\\s+COALESCE").WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "id", "name", "cost", "price"}).AddRow(1, "testproduct", 1, "testsupplier", "2.00", "5.00"))
+	mock.ExpectQuery("FROM stock_movements m").WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"product_id", "delta", "unit_cost"}).AddRow(1, 4, "1.50"))
+
+	w := serve(server.ViewValuationReport, "GET", "/reports/valuation?method=fifo&as_of=2024-08-31", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"as_of": "2024-09-01T00:00:00Z"`)
+	assert.Contains(t, w.Body.String(), `"cost_value": "6"`)
+	assert.Contains(t, w.Body.String(), `"retail_value": "20"`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestViewValuationReportInvalidMethod(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.ViewValuationReport, "GET", "/reports/valuation?method=lifo", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = serve(server.ViewValuationReport, "GET", "/reports/valuation?as_of=yesterday", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,164 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /sales-orders, creates a draft at the current product prices
+func (s *Server) CreateSalesOrder(c *gin.Context) {
+	var body struct {
+		CustomerID int64  `json:"customer_id" binding:"required"`
+		Notes      string `json:"notes" binding:"max=255"`
+		Lines      []struct {
+			ProductID int64 `json:"product_id" binding:"required"`
+			Quantity  int   `json:"quantity" binding:"required,min=1"`
+		} `json:"lines" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	order := models.SalesOrder{CustomerID: body.CustomerID, Notes: body.Notes}
+	for _, line := range body.Lines {
+		order.Lines = append(order.Lines, models.SalesOrderLine{ProductID: line.ProductID, Quantity: line.Quantity})
+	}
+	if user, ok := currentUser(c); ok {
+		order.CreatedBy = &user.ID
+	}
+
+	if err := s.SalesOrders.CreateSalesOrder(c.Request.Context(), &order); err != nil {
+		respondStoreError(c, err, "Error creating sales order", "Customer not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":     "Sales order created as draft",
+		"Sales Order": order,
+		"Total":       order.Total(),
+	})
+}
+
+// GET /sales-orders?status=confirmed&customer_id=1
+func (s *Server) ViewSalesOrders(c *gin.Context) {
+	filter := models.SalesOrderFilter{Status: c.Query("status")}
+	if filter.Status != "" && !models.ValidSOStatus(filter.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid status",
+		})
+		return
+	}
+	if value := c.Query("customer_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid customer ID",
+			})
+			return
+		}
+		filter.CustomerID = id
+	}
+
+	orders, err := s.SalesOrders.ListSalesOrders(c.Request.Context(), filter)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving sales orders", "No sales orders found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Sales Orders Found": orders,
+	})
+}
+
+// GET /sales-orders/:id, with lines
+func (s *Server) ViewSalesOrder(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid sales order ID")
+	if !ok {
+		return
+	}
+
+	order, err := s.SalesOrders.GetSalesOrder(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving sales order", "Sales order not found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Sales Order": order,
+		"Total":       order.Total(),
+	})
+}
+
+// POST /sales-orders/:id/confirm, draft -> confirmed, reserves the stock
+func (s *Server) ConfirmSalesOrder(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid sales order ID")
+	if !ok {
+		return
+	}
+
+	order, err := s.SalesOrders.ConfirmSalesOrder(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error confirming sales order", "Sales order not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Sales order confirmed, stock reserved",
+		"Sales Order": order,
+	})
+}
+
+// POST /sales-orders/:id/fulfil, confirmed -> fulfilled, takes the stock out
+func (s *Server) FulfilSalesOrder(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid sales order ID")
+	if !ok {
+		return
+	}
+
+	var userID int64
+	if user, ok := currentUser(c); ok {
+		userID = user.ID
+	}
+
+	order, levels, err := s.SalesOrders.FulfilSalesOrder(c.Request.Context(), id, userID)
+	if err != nil {
+		respondStoreError(c, err, "Error fulfilling sales order", "Sales order not found")
+		return
+	}
+	for _, level := range levels {
+		s.stockChanged(level)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Sales order fulfilled",
+		"Sales Order":  order,
+		"Stock Levels": levels,
+	})
+}
+
+// POST /sales-orders/:id/cancel, releases the reservation of a confirmed order
+func (s *Server) CancelSalesOrder(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid sales order ID")
+	if !ok {
+		return
+	}
+
+	order, err := s.SalesOrders.CancelSalesOrder(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error cancelling sales order", "Sales order not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Sales order cancelled",
+		"Sales Order": order,
+	})
+}
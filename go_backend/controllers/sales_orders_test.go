@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSalesOrderUnknownProduct(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	created := "2024-08-30T12:00:00Z"
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO sales_orders").WithArgs(1, models.SOStatusDraft, "", nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_id", "status", "notes", "created_by", "confirmed_at", "fulfilled_at", "cancelled_at", "created_at", "updated_at"}).
+			AddRow(1, 1, models.SOStatusDraft, "", nil, nil, nil, nil, created, created))
+	mock.ExpectQuery("SELECT price FROM products").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"price"}))
+	mock.ExpectRollback()
+
+	body := `{"customer_id": 1, "lines": [{"product_id": 9, "quantity": 1}]}`
+	w := serve(server.CreateSalesOrder, "POST", "/sales-orders/", body, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "product 9 does not exist")
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCreateSalesOrderValidation(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	// no customer
+	w := serve(server.CreateSalesOrder, "POST", "/sales-orders/", `{"lines": [{"product_id": 2, "quantity": 1}]}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// zero quantity
+	w = serve(server.CreateSalesOrder, "POST", "/sales-orders/", `{"customer_id": 1, "lines": [{"product_id": 2, "quantity": 0}]}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestConfirmSalesOrderNotDraft(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM sales_orders WHERE id = \\$1 FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.SOStatusFulfilled))
+	mock.ExpectRollback()
+
+	w := serve(server.ConfirmSalesOrder, "POST", "/sales-orders/1/confirm", "", gin.Params{{Key: "id", Value: "1"}})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestViewSalesOrdersInvalidStatus(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.ViewSalesOrders, "GET", "/sales-orders/?status=lost", "", nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
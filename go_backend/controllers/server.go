@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"small_business/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server holds what the handlers share, handlers only see the store interfaces
+type Server struct {
+	models.Stores
+
+	// told about every stock change made through the API, optional
+	LowStock StockObserver
+}
+
+// StockObserver is implemented by alerts.Checker
+type StockObserver interface {
+	Observe(level models.StockLevel)
+}
+
+// report a new stock level to the low stock checker, if there is one
+func (s *Server) stockChanged(level models.StockLevel) {
+	if s.LowStock != nil {
+		s.LowStock.Observe(level)
+	}
+}
+
+func NewServer(stores models.Stores) *Server {
+	return &Server{Stores: stores}
+}
+
+// GET /ready, 503 until the storage answers a ping
+func (s *Server) Ready(c *gin.Context) {
+	if err := s.Health.Ping(c.Request.Context()); err != nil {
+		log.Printf("Readiness check failed: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status": "unavailable",
+			"error":  "Database is not reachable",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ready",
+	})
+}
+
+// respond to a failed store call. Not found gets notFound as message,
+// constraint errors are the client's fault, anything else is logged and hidden.
+func respondStoreError(c *gin.Context, err error, action string, notFound string) {
+	switch {
+	case errors.Is(err, models.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": notFound,
+		})
+	case errors.Is(err, models.ErrDuplicate), errors.Is(err, models.ErrInsufficientStock), errors.Is(err, models.ErrInvalidState), errors.Is(err, models.ErrInUse):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   action,
+			"details": err.Error(),
+		})
+	case errors.Is(err, models.ErrVersionMismatch):
+		c.JSON(http.StatusPreconditionFailed, gin.H{
+			"error":   action,
+			"details": err.Error(),
+		})
+	case errors.Is(err, models.ErrInvalidReference), errors.Is(err, models.ErrInvalidValue):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   action,
+			"details": err.Error(),
+		})
+	default:
+		log.Printf("%s: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": action,
+		})
+	}
+}
+
+// parse the :id path parameter, responds 400 itself when it is not a number
+func idParam(c *gin.Context, name string, message string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.IndentedJSON(http.StatusBadRequest, gin.H{
+			"message": message,
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// parse the optional ?days= window, responds 400 itself when it is out of range
+func daysQuery(c *gin.Context, fallback int) (int, bool) {
+	value := c.Query("days")
+	if value == "" {
+		return fallback, true
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 1 || days > 365 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "days must be a number between 1 and 365",
+		})
+		return 0, false
+	}
+	return days, true
+}
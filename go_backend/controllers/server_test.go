@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"small_business/models"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// server backed by the postgres stores on a mock database
+func newMockServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewServer(models.NewPostgresStores(db)), mock
+}
+
+// run a handler directly with an optional JSON body and path params
+func serve(handler gin.HandlerFunc, method string, path string, body string, params gin.Params) *httptest.ResponseRecorder {
+	return serveWithHeader(handler, method, path, body, params, nil)
+}
+
+// If-Match of a change to a product or supplier still at version 1
+var matchFirstVersion = http.Header{"If-Match": {`"1"`}}
+
+// serve with extra request headers
+func serveWithHeader(handler gin.HandlerFunc, method string, path string, body string, params gin.Params, header http.Header) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(method, path, bytes.NewBufferString(body))
+	for key, values := range header {
+		c.Request.Header[key] = values
+	}
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = params
+
+	handler(c)
+	return w
+}
+
+func TestReady(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+	mock.ExpectPing()
+
+	w := serve(server.Ready, "GET", "/ready", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestReadyDatabaseDown(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+	w := serve(server.Ready, "GET", "/ready", "", nil)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
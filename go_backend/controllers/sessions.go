@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"small_business/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	refreshCookieName = "RefreshToken"
+	refreshCookiePath = "/user-auth" // only sent to the refresh and logout routes
+)
+
+// random 32 byte token, hex encoded
+func newRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// only the sha256 of a refresh token is stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// short lived access token tied to a session (refresh token family)
+func signAccessToken(user models.User, sessionID string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   user.ID,
+		"email": user.Email,
+		"role":  user.Role,
+		"sid":   sessionID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(accessTokenTTL).Unix(),
+	})
+
+	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
+
+// start a new session for the user and return its id and first refresh token
+func (s *Server) createSession(ctx context.Context, userID int64) (string, string, error) {
+	sessionID, err := newRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := newRandomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.Users.CreateSession(ctx, userID, sessionID, hashToken(refreshToken), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		return "", "", err
+	}
+
+	return sessionID, refreshToken, nil
+}
+
+func setAuthCookies(c *gin.Context, accessToken string, refreshToken string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("Authorization", accessToken, int(accessTokenTTL.Seconds()), "", "", false, true)
+	c.SetCookie(refreshCookieName, refreshToken, int(refreshTokenTTL.Seconds()), refreshCookiePath, "", false, true)
+}
+
+func clearAuthCookies(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("Authorization", "", -1, "", "", false, true)
+	c.SetCookie(refreshCookieName, "", -1, refreshCookiePath, "", false, true)
+}
+
+// read the refresh token from the JSON body or the RefreshToken cookie
+func refreshTokenFromRequest(c *gin.Context) string {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// body is optional, browsers rely on the cookie
+	if err := c.ShouldBindJSON(&body); err == nil && body.RefreshToken != "" {
+		return body.RefreshToken
+	}
+
+	cookie, err := c.Cookie(refreshCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie
+}
+
+// POST /user-auth/refresh
+func (s *Server) RefreshToken(c *gin.Context) {
+	refreshToken := refreshTokenFromRequest(c)
+	if refreshToken == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "Missing refresh token",
+		})
+		return
+	}
+
+	newRefreshToken, err := newRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to refresh token",
+		})
+		return
+	}
+
+	user, sessionID, err := s.Users.RotateRefreshToken(c.Request.Context(), hashToken(refreshToken), hashToken(newRefreshToken), time.Now().Add(refreshTokenTTL))
+	if err != nil {
+		if errors.Is(err, models.ErrRefreshTokenInvalid) || errors.Is(err, models.ErrRefreshTokenReused) {
+			if errors.Is(err, models.ErrRefreshTokenReused) {
+				log.Printf("Refresh token reuse detected for user %d, session revoked", user.ID)
+			}
+			clearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Unauthorized",
+				"message": err.Error(),
+			})
+			return
+		}
+		log.Printf("Error rotating refresh token: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to refresh token",
+		})
+		return
+	}
+
+	accessToken, err := signAccessToken(user, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to create token",
+		})
+		return
+	}
+
+	setAuthCookies(c, accessToken, newRefreshToken)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Token refreshed",
+		"token":         accessToken,
+		"refresh_token": newRefreshToken,
+	})
+}
+
+// POST /user-auth/logout
+func (s *Server) LogoutUser(c *gin.Context) {
+	refreshToken := refreshTokenFromRequest(c)
+
+	if refreshToken != "" {
+		if err := s.Users.RevokeSession(c.Request.Context(), hashToken(refreshToken)); err != nil {
+			log.Printf("Error revoking session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Failed to revoke session",
+			})
+			return
+		}
+	}
+
+	clearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Logout successful",
+	})
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashToken(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, hashToken("refreshtoken"), hashToken("refreshtoken"))
+	assert.NotEqual(t, hashToken("refreshtoken"), hashToken("othertoken"))
+	assert.Len(t, hashToken("refreshtoken"), 64)
+}
+
+func TestRefreshTokenMissing(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.RefreshToken, "POST", "/user-auth/refresh", "", nil)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRefreshTokenUnknown(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM refresh_tokens").WithArgs(hashToken("unknowntoken")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "family_id", "expires_at", "revoked_at", "id", "email", "role"}))
+	mock.ExpectRollback()
+
+	w := serve(server.RefreshToken, "POST", "/user-auth/refresh", `{"refresh_token": "unknowntoken"}`, nil)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestLogoutUser(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\)").WithArgs(hashToken("refreshtoken")).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	w := serve(server.LogoutUser, "POST", "/user-auth/logout", `{"refresh_token": "refreshtoken"}`, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Values("Set-Cookie")[0], "Authorization=;")
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -1 +1,228 @@
 package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"small_business/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /stocks
+func (s *Server) ViewStockLevels(c *gin.Context) {
+	levels, err := s.Stocks.ListStockLevels(c.Request.Context())
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving stock levels", "No products found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Stock Levels": levels,
+	})
+}
+
+// GET /stocks/:product_id
+func (s *Server) ViewStockLevel(c *gin.Context) {
+	id, ok := idParam(c, "product_id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	level, err := s.Stocks.GetStockLevel(c.Request.Context(), id)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving stock level", "Product not found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Stock Level": level,
+	})
+}
+
+// GET /stocks/low, products at or below their minimum stock with supplier contact details
+func (s *Server) ViewLowStock(c *gin.Context) {
+	items, err := s.Stocks.ListLowStock(c.Request.Context())
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving low stock", "No products found")
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Low Stock": items,
+	})
+}
+
+// build the ledger entry for a stock change, reason defaults to adjustment.
+// Responds 400 itself when the reason is unknown.
+func stockChange(c *gin.Context, reason string, reference string) (models.StockChange, bool) {
+	if reason == "" {
+		reason = models.ReasonAdjustment
+	}
+	if !models.ValidMovementReason(reason) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid reason",
+			"details": "reason must be one of receipt, sale, adjustment, damage, return",
+		})
+		return models.StockChange{}, false
+	}
+
+	change := models.StockChange{Reason: reason, Reference: reference}
+	if user, ok := currentUser(c); ok {
+		change.UserID = user.ID
+	}
+	return change, true
+}
+
+// PUT /stocks/:product_id, overwrite the count (e.g. after a stock take)
+func (s *Server) UpdateProductStock(c *gin.Context) {
+	id, ok := idParam(c, "product_id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Stock     *int   `json:"stock" binding:"required,min=0"`
+		Reason    string `json:"reason"`
+		Reference string `json:"reference" binding:"max=255"`
+	}
+
+	// if error with fields
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	change, ok := stockChange(c, body.Reason, body.Reference)
+	if !ok {
+		return
+	}
+
+	level, err := s.Stocks.SetStock(c.Request.Context(), id, *body.Stock, change)
+	if err != nil {
+		respondStoreError(c, err, "Error updating product stock", "Product not found")
+		return
+	}
+	s.stockChanged(level)
+
+	fmt.Println("Updating Product Stock Number in database...")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Product Stock Updated Successfully",
+		"New Product Stock": level.Stock,
+	})
+}
+
+// POST /stocks/:product_id/increment
+func (s *Server) IncrementProductStock(c *gin.Context) {
+	s.adjustProductStock(c, 1)
+}
+
+// POST /stocks/:product_id/decrement, refuses to go below zero
+func (s *Server) DecrementProductStock(c *gin.Context) {
+	s.adjustProductStock(c, -1)
+}
+
+// apply sign * quantity to the stock in one atomic update
+func (s *Server) adjustProductStock(c *gin.Context, sign int) {
+	id, ok := idParam(c, "product_id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Quantity  int    `json:"quantity" binding:"required,min=1"`
+		Reason    string `json:"reason"`
+		Reference string `json:"reference" binding:"max=255"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	change, ok := stockChange(c, body.Reason, body.Reference)
+	if !ok {
+		return
+	}
+
+	level, err := s.Stocks.AdjustStock(c.Request.Context(), id, sign*body.Quantity, change)
+	if err != nil {
+		respondStoreError(c, err, "Error adjusting product stock", "Product not found")
+		return
+	}
+	s.stockChanged(level)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Product Stock Adjusted Successfully",
+		"Stock Level": level,
+	})
+}
+
+// parse a from/to query value, either a date (2024-08-15) or an RFC 3339 timestamp.
+// A bare date as upper bound includes the whole day.
+func parseTimeQuery(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (2006-01-02) or RFC 3339 timestamp", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// GET /stocks/:product_id/history?from=2024-08-01&to=2024-08-31
+func (s *Server) ViewStockHistory(c *gin.Context) {
+	id, ok := idParam(c, "product_id", "Invalid product ID")
+	if !ok {
+		return
+	}
+
+	from, err := parseTimeQuery(c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid from parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+	to, err := parseTimeQuery(c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid to parameter",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	movements, err := s.Stocks.ListMovements(c.Request.Context(), id, models.MovementFilter{From: from, To: to})
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving stock history", "Product not found")
+		return
+	}
+
+	// net change over the window, lets a count be reconciled against the ledger
+	total := 0
+	for _, movement := range movements {
+		total += movement.Delta
+	}
+
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"Stock Movements": movements,
+		"net_change":      total,
+	})
+}
@@ -1 +1,202 @@
 package controllers
+
+import (
+	"net/http"
+	"small_business/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var stockRowColumns = []string{"id", "name", "stock", "minimum_stock", "reserved"}
+
+func TestViewStockLevels(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	rows := sqlmock.NewRows(stockRowColumns).
+		AddRow(1, "testproduct", 5, 2, 0).
+		AddRow(2, "otherproduct", 0, 1, 0)
+	mock.ExpectQuery("SELECT id, name, stock, minimum_stock, reserved FROM products WHERE deleted_at IS NULL ORDER BY id").WillReturnRows(rows)
+
+	w := serve(server.ViewStockLevels, "GET", "/stocks/", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "otherproduct")
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestUpdateProductStock(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	//mock query
+	query := "UPDATE products SET stock = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2 RETURNING id, name, stock, minimum_stock, reserved"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT stock, reserved FROM products").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"stock", "reserved"}).AddRow(5, 0))
+	mock.ExpectQuery(query).WithArgs(2, 1).WillReturnRows(sqlmock.NewRows(stockRowColumns).AddRow(1, "testproduct", 2, 3, 0))
+	mock.ExpectExec("INSERT INTO stock_movements").WithArgs(1, -3, models.ReasonDamage, 0, "broken in transit", 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := serve(server.UpdateProductStock, "PUT", "/stocks/1", `{"stock": 2, "reason": "damage", "reference": "broken in transit"}`, gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// negative stock is rejected before the database
+	w = serve(server.UpdateProductStock, "PUT", "/stocks/1", `{"stock": -1}`, gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestIncrementProductStock(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1").WithArgs(4, 1).
+		WillReturnRows(sqlmock.NewRows(stockRowColumns).AddRow(1, "testproduct", 9, 3, 0))
+	// no reason given, recorded as an adjustment
+	mock.ExpectExec("INSERT INTO stock_movements").WithArgs(1, 4, models.ReasonAdjustment, 0, "", 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := serve(server.IncrementProductStock, "POST", "/stocks/1/increment", `{"quantity": 4}`, gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"stock":9`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDecrementProductStockInsufficient(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	// the guarded update matches nothing, but the product exists
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1").WithArgs(-10, 1).WillReturnRows(sqlmock.NewRows(stockRowColumns))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	w := serve(server.DecrementProductStock, "POST", "/stocks/1/decrement", `{"quantity": 10, "reason": "sale"}`, gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDecrementProductStockInvalidQuantity(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.DecrementProductStock, "POST", "/stocks/1/decrement", `{"quantity": -3}`, gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAdjustProductStockInvalidReason(t *testing.T) {
+	t.Parallel()
+
+	server, _ := newMockServer(t)
+
+	w := serve(server.IncrementProductStock, "POST", "/stocks/1/increment", `{"quantity": 1, "reason": "magic"}`, gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestViewStockHistory(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	rows := sqlmock.NewRows([]string{"id", "product_id", "delta", "reason", "user_id", "reference", "receipt_id", "created_at"}).
+		AddRow(1, 1, 5, models.ReasonReceipt, 2, "PO-1", 6, "2024-08-02T10:00:00Z").
+		AddRow(2, 1, -2, models.ReasonSale, 2, "", nil, "2024-08-03T10:00:00Z")
+	// a bare "to" date includes the whole day
+	from := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 8, 4, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM stock_movements").WithArgs(1, from, to).WillReturnRows(rows)
+
+	w := serve(server.ViewStockHistory, "GET", "/stocks/1/history?from=2024-08-01&to=2024-08-03", "", gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"net_change": 3`)
+
+	w = serve(server.ViewStockHistory, "GET", "/stocks/1/history?from=yesterday", "", gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+// remembers what the handler reported
+type observerFunc func(level models.StockLevel)
+
+func (f observerFunc) Observe(level models.StockLevel) { f(level) }
+
+func TestIncrementProductStockNotifiesObserver(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+	var observed []models.StockLevel
+	server.LowStock = observerFunc(func(level models.StockLevel) { observed = append(observed, level) })
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1").WithArgs(1, 1).
+		WillReturnRows(sqlmock.NewRows(stockRowColumns).AddRow(1, "testproduct", 1, 3, 0))
+	mock.ExpectExec("INSERT INTO stock_movements").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	w := serve(server.IncrementProductStock, "POST", "/stocks/1/increment", `{"quantity": 1}`, gin.Params{{Key: "product_id", Value: "1"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []models.StockLevel{{ProductID: 1, Name: "testproduct", Stock: 1, MinimumStock: 3}}, observed)
+}
+
+func TestViewLowStock(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
+
+	rows := sqlmock.NewRows([]string{"id", "name", "stock", "minimum_stock", "supplier_id", "supplier_name", "contact_email", "phone"}).
+		AddRow(1, "testproduct", 1, 3, 1, "testsupplier", "supplier@example.com", "555-0100")
+	mock.ExpectQuery("FROM products p JOIN supplier s ON s.id = p.supplier_id\\s+WHERE p.stock <= p.minimum_stock").WillReturnRows(rows)
+
+	w := serve(server.ViewLowStock, "GET", "/stocks/low", "", nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"supplier_email": "supplier@example.com"`)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
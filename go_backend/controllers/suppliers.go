@@ -1,38 +1,25 @@
 package controllers
 
 import (
-	"context"
-	"database/sql"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"os"
+	"net/mail"
+	"small_business/models"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
 
-// var pool *sql.DB
-
-type Supplier struct {
-	ID           int64  `json:"id"`
-	Name         string `json:"name"`
-	ContactEmail string `json:"contact_email"`
-	Phone        string `json:"phone"`
-	CreatedAt    string `json: created_at`
-	DeletedAt    string `json: deleted_at`
-}
-
-var pool *sql.DB
-
-// fix insert issue by using a transaction to verify supplier id first from the supplier table and then use that instead for the insert.
-
-func InsertSupplier(c *gin.Context) {
+func (s *Server) InsertSupplier(c *gin.Context) {
 
 	var body struct {
 		Name         string `json:"name"`
 		ContactEmail string `json:"contact_email"`
 		Phone        string `json:"phone"`
+		LeadTimeDays *int   `json:"lead_time_days" binding:"omitempty,min=0"`
 	}
 
 	// if error with fields
@@ -44,197 +31,215 @@ func InsertSupplier(c *gin.Context) {
 		return
 	}
 
-	supplier := Supplier{Name: body.Name, ContactEmail: body.ContactEmail, Phone: body.Phone}
-
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
+	supplier := models.Supplier{Name: body.Name, ContactEmail: body.ContactEmail, Phone: body.Phone, LeadTimeDays: models.DefaultLeadTimeDays}
+	if body.LeadTimeDays != nil {
+		supplier.LeadTimeDays = *body.LeadTimeDays
 	}
 
-	defer pool.Close()
-
-	ctx := context.Background()
-
-	query := "INSERT INTO supplier (name, contact_email, phone) VALUES($1, $2, $3) Returning ID"
-
-	err = pool.QueryRowContext(ctx, query, supplier.Name, supplier.ContactEmail, supplier.Phone).Scan(&supplier.ID) //due to auto increment
-
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{
-			"Error inserting new supplier": err,
-		})
-		log.Print("Error inserting new supplier", err)
+	if err := s.Suppliers.CreateSupplier(c.Request.Context(), &supplier); err != nil {
+		respondStoreError(c, err, "Error inserting new supplier", "Supplier not found")
 		return
+	}
 
-	} else {
-		fmt.Println("Inserting supplier information into database...")
+	fmt.Println("Inserting supplier information into database...")
 
-		// Respond with product information
-		c.IndentedJSON(http.StatusOK, gin.H{
-			"message":              "Supplier Information Successfully Added",
-			"Supplier Information": supplier,
-		})
-	}
+	// Respond with product information
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"message":              "Supplier Information Successfully Added",
+		"Supplier Information": supplier,
+	})
 
 }
 
-func ViewSuppliers(c *gin.Context) {
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
+// GET /suppliers, filtered by ?name= and ?include_deleted=true
+func (s *Server) ViewSuppliers(c *gin.Context) {
+	filter := models.SupplierFilter{Name: c.Query("name")}
+	var ok bool
+	if filter.Sort, filter.Page, ok = listQuery(c, models.SupplierSortKeys); !ok {
+		return
+	}
+	if filter.IncludeDeleted, ok = includeDeletedQuery(c); !ok {
+		return
 	}
 
-	defer pool.Close()
-
-	// ctx := context.Background()
-
-	// var supplier Supplier
-
-	query := "SELECT * FROM supplier"
-
-	rows, err := pool.Query(query) //uses ctx internally
+	suppliers, total, err := s.Suppliers.ListSuppliers(c.Request.Context(), filter)
 	if err != nil {
-		print(err)
+		respondStoreError(c, err, "Error retrieving suppliers", "No suppliers found")
+		return
 	}
-	defer rows.Close()
 
-	var suppliers []Supplier
-
-	// Loop through rows and map onto databases
-	for rows.Next() {
-		var supplier Supplier
-		if err := rows.Scan(&supplier.ID, &supplier.Name, &supplier.ContactEmail, &supplier.Phone, &supplier.CreatedAt, &supplier.DeletedAt); err != nil {
-			c.IndentedJSON(http.StatusBadRequest, gin.H{
-				"Error retrieving suppliers": err,
-			})
-			log.Print("Error inserting new supplier", err)
-			return
-		}
-		suppliers = append(suppliers, supplier)
-	}
-	if err == sql.ErrNoRows {
-		c.IndentedJSON(http.StatusNotFound, gin.H{
-			"message": "No suppliers found",
-		})
-	}
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"Suppliers Found": suppliers,
+		"Pagination":      pagination(filter.Page, total),
 	})
 
 }
 
-func ViewSuppliersById(c *gin.Context) {
+func (s *Server) ViewSuppliersById(c *gin.Context) {
 
-	idParam := c.Param("id")
-	id, err := strconv.ParseInt(idParam, 10, 64)
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid supplier ID",
-		})
+	id, ok := idParam(c, "id", "Invalid supplier ID")
+	if !ok {
 		return
 	}
-
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
+	includeDeleted, ok := includeDeletedQuery(c)
+	if !ok {
+		return
 	}
 
-	defer pool.Close()
-
-	ctx := context.Background()
-
-	var supplier Supplier
-
-	query := "SELECT id, name, contact_email, phone FROM supplier WHERE id = $1"
-
-	row := pool.QueryRowContext(ctx, query, id)
-
-	// map onto database
-	err = row.Scan(&supplier.ID, &supplier.Name, &supplier.ContactEmail, &supplier.Phone)
+	supplier, err := s.Suppliers.GetSupplier(c.Request.Context(), id)
+	if err == nil && supplier.DeletedAt != "" && !includeDeleted {
+		err = models.ErrNotFound
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.IndentedJSON(http.StatusNotFound, gin.H{
-				"message": "No supplier found with this ID",
-			})
-		} else {
-			log.Printf("Error scanning row: %v", err)
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{
-				"error": "Error retrieving supplier",
-			})
-		}
+		respondStoreError(c, err, "Error retrieving supplier", "No supplier found with this ID")
 		return
 	}
 
+	setETag(c, supplier.Version)
 	c.IndentedJSON(http.StatusOK, gin.H{
 		"Supplier Found": supplier,
 	})
 
 }
 
-func DeleteSupplierByID(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := strconv.ParseInt(idParam, 10, 64)
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{
-			"message": "Invalid supplier ID",
-		})
+// DELETE /suppliers/remove/:id, refused with 409 while the supplier has products unless
+// ?reassign_to= moves them to another supplier or ?cascade=true deletes them too
+func (s *Server) DeleteSupplierByID(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid supplier ID")
+	if !ok {
+		return
+	}
+	deletion, ok := supplierDeletionQuery(c)
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	err := s.Suppliers.DeleteSupplier(c.Request.Context(), id, version, deletion)
+	if errors.Is(err, models.ErrInUse) {
+		s.respondSupplierInUse(c, id)
+		return
+	}
 	if err != nil {
-		log.Fatal("Error opening database connection")
+		respondStoreError(c, err, "Error removing supplier", "Supplier not found")
+		return
 	}
 
-	defer pool.Close()
-
-	ctx := context.Background()
+	fmt.Println("Removing supplier from database...")
 
-	// var supplier Supplier
+	// Respond with product information
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Supplier Removed Successfully",
+		"Supplier ID": id,
+	})
 
-	query := "DELETE FROM supplier WHERE id = $1 RETURNING id"
+}
 
-	result, err := pool.ExecContext(ctx, query, id)
+// parse ?reassign_to= and ?cascade= of a supplier delete, cascading is for admins only.
+// Responds 400 or 403 itself.
+func supplierDeletionQuery(c *gin.Context) (models.SupplierDeletion, bool) {
+	deletion := models.SupplierDeletion{}
+	if value := c.Query("reassign_to"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "Invalid reassign_to supplier ID",
+			})
+			return deletion, false
+		}
+		deletion.ReassignTo = id
+	}
+	if value := c.Query("cascade"); value != "" {
+		cascade, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"message": "cascade must be true or false",
+			})
+			return deletion, false
+		}
+		deletion.Cascade = cascade
+	}
 
-	if err != nil {
+	if deletion.Cascade && deletion.ReassignTo != 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"Error removing supplier": err.Error(),
+			"message": "reassign_to and cascade cannot be combined",
 		})
-		log.Print("Error removing supplier", err)
+		return deletion, false
+	}
+	if deletion.Cascade && !isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": "Only admins can delete a supplier with its products",
+		})
+		return deletion, false
+	}
+	return deletion, true
+}
+
+// 409 listing the products that keep a supplier from being deleted
+func (s *Server) respondSupplierInUse(c *gin.Context, id int64) {
+	filter := models.ProductFilter{SupplierID: id, Page: models.Page{Limit: models.MaxPageSize}}
+	products, total, err := s.Products.ListProducts(c.Request.Context(), filter)
+	if err != nil {
+		respondStoreError(c, err, "Error retrieving products of supplier", "No products found")
+		return
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error":    "Supplier still has products",
+		"details":  "delete the products first, move them with ?reassign_to= or delete them along with ?cascade=true",
+		"products": products,
+		"total":    total,
+	})
+}
+
+// POST /suppliers/restore/:id, brings back a deleted supplier
+func (s *Server) RestoreSupplierByID(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid supplier ID")
+	if !ok {
 		return
 	}
 
-	rows, err := result.RowsAffected()
+	supplier, err := s.Suppliers.RestoreSupplier(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"Error getting affected rows": err.Error(),
-		})
+		respondStoreError(c, err, "Error restoring supplier", "Supplier not found")
 		return
 	}
 
-	if rows != 1 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "Supplier not found",
-		})
+	setETag(c, supplier.Version)
+	c.JSON(http.StatusOK, gin.H{
+		"message":              "Supplier Restored Successfully",
+		"supplier_information": supplier,
+	})
+}
+
+// DELETE /suppliers/purge/:id, removes a deleted supplier for good
+func (s *Server) PurgeSupplierByID(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid supplier ID")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	fmt.Println("Removing supplier from database...")
+	if err := s.Suppliers.PurgeSupplier(c.Request.Context(), id, version); err != nil {
+		respondStoreError(c, err, "Error purging supplier", "Supplier not found")
+		return
+	}
 
-	// Respond with product information
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "Supplier Removed Successfully",
+		"message":     "Supplier Purged Successfully",
 		"Supplier ID": id,
 	})
-
 }
 
 // update supplier email by id
-func UpdateSupplierEmail(c *gin.Context) {
+func (s *Server) UpdateSupplierEmail(c *gin.Context) {
 	var body struct {
 		ID           int64  `json:"id"`
 		ContactEmail string `json:"contact_email"`
@@ -248,43 +253,13 @@ func UpdateSupplierEmail(c *gin.Context) {
 		})
 		return
 	}
-
-	supplier := Supplier{ID: body.ID, ContactEmail: body.ContactEmail}
-
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
-	}
-
-	defer pool.Close()
-
-	ctx := context.Background()
-
-	query := "UPDATE supplier SET contact_email = $1 WHERE id = $2"
-
-	result, err := pool.ExecContext(ctx, query, supplier.ContactEmail, supplier.ID)
-
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"Error updating supplier email": err.Error(),
-		})
-		log.Print("Error updating supplier email", err)
-		return
-	}
-
-	rows, err := result.RowsAffected()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"Error getting affected rows": err.Error(),
-		})
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	if rows != 1 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "Supplier not found",
-		})
+	if err := s.Suppliers.UpdateSupplierEmail(c.Request.Context(), body.ID, version, body.ContactEmail); err != nil {
+		respondStoreError(c, err, "Error updating supplier email", "Supplier not found")
 		return
 	}
 
@@ -293,12 +268,12 @@ func UpdateSupplierEmail(c *gin.Context) {
 	// Respond with product information
 	c.JSON(http.StatusOK, gin.H{
 		"message":           "Supplier Contact Email Updated Successfully",
-		"New Contact Email": supplier.ContactEmail,
+		"New Contact Email": body.ContactEmail,
 	})
 }
 
 // update supplier phone number by id
-func UpdateSupplierPhone(c *gin.Context) {
+func (s *Server) UpdateSupplierPhone(c *gin.Context) {
 	var body struct {
 		ID    int64  `json:"id"`
 		Phone string `json:"phone"`
@@ -312,51 +287,131 @@ func UpdateSupplierPhone(c *gin.Context) {
 		})
 		return
 	}
-
-	supplier := Supplier{ID: body.ID, Phone: body.Phone}
-
-	//open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		log.Fatal("Error opening database connection")
+	version, ok := ifMatch(c)
+	if !ok {
+		return
 	}
 
-	defer pool.Close()
+	if err := s.Suppliers.UpdateSupplierPhone(c.Request.Context(), body.ID, version, body.Phone); err != nil {
+		respondStoreError(c, err, "Error updating phone number", "Supplier not found")
+		return
+	}
 
-	ctx := context.Background()
+	fmt.Println("Updating Supplier Phone Number in database...")
 
-	query := "UPDATE supplier SET phone = $1 WHERE id = $2"
+	// Respond with product information
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Supplier Phone Number Updated Successfully",
+		"New Phone Number": body.Phone,
+	})
+}
 
-	result, err := pool.ExecContext(ctx, query, supplier.Phone, supplier.ID)
+// update supplier lead time (days from ordering to delivery) by id
+func (s *Server) UpdateSupplierLeadTime(c *gin.Context) {
+	var body struct {
+		ID           int64 `json:"id" binding:"required"`
+		LeadTimeDays *int  `json:"lead_time_days" binding:"required,min=0"`
+	}
 
-	if err != nil {
+	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"Error updating phone number": err.Error(),
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
 		})
-		log.Print("Error updating phone number", err)
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"Error getting affected rows": err.Error(),
-		})
+	if err := s.Suppliers.UpdateSupplierLeadTime(c.Request.Context(), body.ID, version, *body.LeadTimeDays); err != nil {
+		respondStoreError(c, err, "Error updating lead time", "Supplier not found")
 		return
 	}
 
-	if rows != 1 {
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "Supplier not found",
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Supplier Lead Time Updated Successfully",
+		"New Lead Time": *body.LeadTimeDays,
+	})
+}
+
+// PATCH /suppliers/:id, a JSON Merge Patch of the supplier
+func (s *Server) PatchSupplier(c *gin.Context) {
+	id, ok := idParam(c, "id", "Invalid supplier ID")
+	if !ok {
+		return
+	}
+	version, ok := ifMatch(c)
+	if !ok {
+		return
+	}
+	patch, ok := bindMergePatch(c, "name", "contact_email", "phone", "lead_time_days")
+	if !ok {
+		return
+	}
+
+	var changes models.SupplierPatch
+	err := errors.Join(
+		patchField(patch, "name", false, &changes.Name),
+		patchField(patch, "contact_email", true, &changes.ContactEmail),
+		patchField(patch, "phone", true, &changes.Phone),
+		patchField(patch, "lead_time_days", false, &changes.LeadTimeDays),
+	)
+	if err == nil {
+		err = checkSupplierPatch(&changes)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid supplier patch",
+			"details": err.Error(),
 		})
 		return
 	}
 
-	fmt.Println("Updating Supplier Phone Number in database...")
+	supplier, err := s.Suppliers.PatchSupplier(c.Request.Context(), id, version, changes)
+	if err != nil {
+		respondStoreError(c, err, "Error updating supplier", "Supplier not found")
+		return
+	}
 
-	// Respond with product information
-	c.JSON(http.StatusOK, gin.H{
-		"message":          "Supplier Phone Number Updated Successfully",
-		"New Phone Number": supplier.Phone,
+	setETag(c, supplier.Version)
+	c.IndentedJSON(http.StatusOK, gin.H{
+		"message":          "Supplier Updated Successfully",
+		"Updated Supplier": supplier,
 	})
 }
+
+// longest values the supplier columns hold
+const (
+	maxSupplierNameLength = 255
+	maxEmailLength        = 255
+	maxPhoneLength        = 20
+)
+
+// validate the patched values, trimming the name
+func checkSupplierPatch(changes *models.SupplierPatch) error {
+	if changes.Name != nil {
+		if *changes.Name = strings.TrimSpace(*changes.Name); *changes.Name == "" {
+			return errors.New("name must not be empty")
+		}
+		if utf8.RuneCountInString(*changes.Name) > maxSupplierNameLength {
+			return fmt.Errorf("name must not be longer than %d characters", maxSupplierNameLength)
+		}
+	}
+	if changes.ContactEmail != nil && *changes.ContactEmail != "" {
+		if address, err := mail.ParseAddress(*changes.ContactEmail); err != nil || address.Address != *changes.ContactEmail {
+			return errors.New("contact_email must be an email address")
+		}
+		if utf8.RuneCountInString(*changes.ContactEmail) > maxEmailLength {
+			return fmt.Errorf("contact_email must not be longer than %d characters", maxEmailLength)
+		}
+	}
+	if changes.Phone != nil && utf8.RuneCountInString(*changes.Phone) > maxPhoneLength {
+		return fmt.Errorf("phone must not be longer than %d characters", maxPhoneLength)
+	}
+	if changes.LeadTimeDays != nil && *changes.LeadTimeDays < 0 {
+		return errors.New("lead_time_days must not be negative")
+	}
+	return nil
+}
@@ -1,54 +1,63 @@
 package controllers
 
 import (
-	"database/sql"
+	"net/http"
+	"small_business/models"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
-func createSupplier(db *sql.DB, name string, contact_email string, phone string) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
+func TestInsertSupplier(t *testing.T) {
+	t.Parallel()
 
-	query := "INSERT INTO supplier (name, contact_email, phone) VALUES ($1, $2, $3)"
-	_, err = tx.Exec(query, name, contact_email, phone)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+	server, mock := newMockServer(t)
+
+	// Define the query we expect to be executed
+	mock.ExpectQuery("INSERT INTO supplier \\(name, contact_email, phone, lead_time_days\\)").WithArgs("testsupplier", "testsupplier@gmail.com", "0123456789", models.DefaultLeadTimeDays).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1)) //new row inserted with id 1
 
-	return tx.Commit()
+	body := `{"name": "testsupplier", "contact_email": "testsupplier@gmail.com", "phone": "0123456789"}`
+	w := serve(server.InsertSupplier, "POST", "/suppliers/insert", body, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
-func TestCreateSupplier(t *testing.T) {
+func TestInsertSupplierDuplicate(t *testing.T) {
 	t.Parallel()
 
-	// Create a new mock database connection and sqlmock instance
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	server, mock := newMockServer(t)
 
-	// Define the query we expect to be executed
-	query := "INSERT INTO supplier \\(name, contact_email, phone\\) VALUES \\(\\$1, \\$2, \\$3\\)"
+	mock.ExpectQuery("INSERT INTO supplier").WillReturnError(&pq.Error{Code: "23505", Detail: "Key (name)=(testsupplier) already exists."})
 
-	// Begin a new transaction
-	mock.ExpectBegin()
+	w := serve(server.InsertSupplier, "POST", "/suppliers/insert", `{"name": "testsupplier"}`, nil)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
 
-	// mock query
-	mock.ExpectExec(query).WithArgs("testsupplier", "testsupplier@gmail.com", "0123456789").WillReturnResult(sqlmock.NewResult(1, 1)) //new row inserted with id 1
+func TestViewSuppliers(t *testing.T) {
+	t.Parallel()
 
-	// Commit the transaction
-	mock.ExpectCommit()
+	server, mock := newMockServer(t)
+
+	//mock queries, the last page has no next_offset
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM supplier").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	rows := sqlmock.NewRows([]string{"id", "name", "contact_email", "phone", "lead_time_days", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "testsupplier", "", "", 7, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery("SELECT (.+) FROM supplier WHERE deleted_at IS NULL ORDER BY created_at, id LIMIT \\$1").WithArgs(models.DefaultPageSize).WillReturnRows(rows)
+
+	w := serve(server.ViewSuppliers, "GET", "/suppliers?sort=created_at", "", nil)
 
-	// Call the function to create a product
-	err = createSupplier(db, "testsupplier", "testsupplier@gmail.com", "0123456789")
-	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"next_offset": null`)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -56,40 +65,54 @@ func TestCreateSupplier(t *testing.T) {
 	}
 }
 
-func getSupplierByID(db *sql.DB, id int) (*Supplier, error) {
-	query := "SELECT id, name, contact_email, phone FROM supplier WHERE id = $1"
-	row := db.QueryRow(query, id)
+func TestPatchSupplier(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
 
-	var supplier Supplier
-	err := row.Scan(&supplier.ID, &supplier.Name, &supplier.ContactEmail, &supplier.Phone)
-	if err != nil {
-		return nil, err
+	//mock query
+	query := "UPDATE supplier SET contact_email = NULLIF\\(\\$1, ''\\), lead_time_days = \\$2, updated_at = NOW\\(\\) WHERE id = \\$3 AND version = \\$4 AND deleted_at IS NULL RETURNING (.+)"
+	rows := sqlmock.NewRows([]string{"id", "name", "contact_email", "phone", "lead_time_days", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "testsupplier", "new@example.com", "", 3, "2024-08-01", "2024-09-01", 2, nil)
+	mock.ExpectQuery(query).WithArgs("new@example.com", 3, 1, 1).WillReturnRows(rows)
+
+	w := serveWithHeader(server.PatchSupplier, "PATCH", "/suppliers/1", `{"contact_email": "new@example.com", "lead_time_days": 3}`, gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"lead_time_days": 3`)
+	assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+
+	// a missing supplier
+	mock.ExpectQuery("UPDATE supplier SET phone").WithArgs("", 9, 1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM supplier WHERE id = \\$1 AND deleted_at IS NULL\\)").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	w = serveWithHeader(server.PatchSupplier, "PATCH", "/suppliers/9", `{"phone": null}`, gin.Params{{Key: "id", Value: "9"}}, matchFirstVersion)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	for _, body := range []string{`{"contact_email": "not an email"}`, `{"lead_time_days": -1}`, `{"lead_time_days": null}`, `{"id": 2}`} {
+		w = serveWithHeader(server.PatchSupplier, "PATCH", "/suppliers/1", body, gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
 	}
 
-	return &supplier, nil
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
-func TestGetSupplierByID(t *testing.T) {
+func TestViewSuppliersById(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	server, mock := newMockServer(t)
 
 	//mock query
-	query := "SELECT id, name, contact_email, phone FROM supplier WHERE id = \\$1"
-	rows := sqlmock.NewRows([]string{"id", "name", "contact_email", "phone"}).
-		AddRow(1, "testsupplier", "supplier@gmail.com", "012345678")
+	rows := sqlmock.NewRows([]string{"id", "name", "contact_email", "phone", "lead_time_days", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "testsupplier", "supplier@gmail.com", "012345678", 7, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery("SELECT (.+) FROM supplier WHERE id = \\$1").WithArgs(1).WillReturnRows(rows)
 
-	mock.ExpectQuery(query).WithArgs(1).WillReturnRows(rows)
+	w := serve(server.ViewSuppliersById, "GET", "/suppliers/1", "", gin.Params{{Key: "id", Value: "1"}})
 
-	// assert that not nil and equal user email
-	supplier, err := getSupplierByID(db, 1)
-	assert.NoError(t, err)
-	assert.NotNil(t, supplier)
-	assert.EqualValues(t, 1, supplier.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "supplier@gmail.com")
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
 
 	// Ensure that all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -97,41 +120,25 @@ func TestGetSupplierByID(t *testing.T) {
 	}
 }
 
-func deleteSupplierByID(db *sql.DB, id int64) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-
-	query := "DELETE FROM supplier WHERE id = $1"
-	_, err = tx.Exec(query, id)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	return tx.Commit()
-}
-
 func TestDeleteSupplierByID(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	server, mock := newMockServer(t)
 
+	// mock queries
 	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE supplier SET deleted_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE id = \\$1 AND version = \\$2").WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM products").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectCommit()
 
-	// mock query
-	query := "DELETE FROM supplier WHERE id = \\$1"
-	mock.ExpectExec(query).WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1)) // return no new row with index 1
+	w := serveWithHeader(server.DeleteSupplierByID, "DELETE", "/suppliers/remove/1", "", gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
 
-	mock.ExpectCommit()
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	err = deleteSupplierByID(db, 1)
-	assert.NoError(t, err)
+	// without If-Match nothing is deleted
+	w = serve(server.DeleteSupplierByID, "DELETE", "/suppliers/remove/1", "", gin.Params{{Key: "id", Value: "1"}})
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -139,42 +146,76 @@ func TestDeleteSupplierByID(t *testing.T) {
 	}
 }
 
-func updateSupplierEmail(db *sql.DB, contact_email string, id int64) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
+func TestDeleteSupplierInUse(t *testing.T) {
+	t.Parallel()
+
+	server, mock := newMockServer(t)
 
-	query := "UPDATE supplier SET contact_email = $1 WHERE id = $2"
-	_, err = tx.Exec(query, contact_email, id)
-	if err != nil {
-		tx.Rollback()
-		return err
+	//mock queries, the 409 lists the products that keep the supplier
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE supplier SET deleted_at").WithArgs(1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM products").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM products WHERE deleted_at IS NULL AND supplier_id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(4, "testproduct", "", "", "", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE deleted_at IS NULL AND supplier_id = \\$1").WithArgs(1, models.MaxPageSize).WillReturnRows(rows)
+
+	w := serveWithHeader(server.DeleteSupplierByID, "DELETE", "/suppliers/remove/1", "", gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"testproduct"`)
+	assert.Contains(t, w.Body.String(), `"total":1`)
+
+	// cascading is for admins, and cannot be mixed with reassigning
+	for query, code := range map[string]int{
+		"cascade=true":               http.StatusForbidden,
+		"cascade=true&reassign_to=2": http.StatusBadRequest,
+		"cascade=maybe":              http.StatusBadRequest,
+		"reassign_to=x":              http.StatusBadRequest,
+	} {
+		w = serveWithHeader(server.DeleteSupplierByID, "DELETE", "/suppliers/remove/1?"+query, "", gin.Params{{Key: "id", Value: "1"}}, matchFirstVersion)
+		assert.Equal(t, code, w.Code, query)
 	}
 
-	return tx.Commit()
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
-func TestUpdateSupplierEmail(t *testing.T) {
+func TestRestoreAndPurgeSupplierByID(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
+	server, mock := newMockServer(t)
 
+	//mock queries
+	lock := "SELECT deleted_at IS NOT NULL, version FROM supplier WHERE id = \\$1 FOR UPDATE"
+	rows := sqlmock.NewRows([]string{"id", "name", "contact_email", "phone", "lead_time_days", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "testsupplier", "", "", 7, "2024-08-01", "2024-09-01", 3, nil)
 	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 2))
+	mock.ExpectExec("UPDATE products SET deleted_at = NULL").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("UPDATE supplier SET deleted_at = NULL").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectCommit()
 
-	//mock query
-
-	query := "UPDATE supplier SET contact_email = \\$1 WHERE id = \\$2"
-	mock.ExpectExec(query).WithArgs("supplier2@gmail.com", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	w := serve(server.RestoreSupplierByID, "POST", "/suppliers/restore/1", "", gin.Params{{Key: "id", Value: "1"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
 
+	// purging needs the ETag of the deleted supplier
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 2))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 2))
+	mock.ExpectExec("DELETE FROM supplier WHERE id = \\$1").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
-	err = updateSupplierEmail(db, "supplier2@gmail.com", 1)
-	assert.NoError(t, err)
+	w = serveWithHeader(server.PurgeSupplierByID, "DELETE", "/suppliers/purge/2", "", gin.Params{{Key: "id", Value: "2"}}, matchFirstVersion)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = serveWithHeader(server.PurgeSupplierByID, "DELETE", "/suppliers/purge/2", "", gin.Params{{Key: "id", Value: "2"}}, http.Header{"If-Match": {`"2"`}})
+	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -182,42 +223,59 @@ func TestUpdateSupplierEmail(t *testing.T) {
 	}
 }
 
-func updateSupplierPhone(db *sql.DB, phone string, id int64) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
+func TestUpdateSupplierEmail(t *testing.T) {
+	t.Parallel()
 
-	query := "UPDATE supplier SET phone = $1 WHERE id = $2"
-	_, err = tx.Exec(query, phone, id)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+	server, mock := newMockServer(t)
+
+	//mock query
+	query := "UPDATE supplier SET contact_email = NULLIF\\(\\$1, ''\\), updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs("supplier2@gmail.com", 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := serveWithHeader(server.UpdateSupplierEmail, "PUT", "/suppliers/change-email", `{"id": 1, "contact_email": "supplier2@gmail.com"}`, nil, matchFirstVersion)
+
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	return tx.Commit()
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
 func TestUpdateSupplierPhone(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	server, mock := newMockServer(t)
+
+	//mock query
+	query := "UPDATE supplier SET phone = NULLIF\\(\\$1, ''\\), updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs("0123456789", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	// "*" changes whatever version the supplier is at
+	w := serveWithHeader(server.UpdateSupplierPhone, "PUT", "/suppliers/change-phone", `{"id": 1, "phone": "0123456789"}`, nil, http.Header{"If-Match": {"*"}})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
-	defer db.Close()
+}
 
-	mock.ExpectBegin()
+func TestUpdateSupplierLeadTime(t *testing.T) {
+	t.Parallel()
 
-	//mock query
+	server, mock := newMockServer(t)
 
-	query := "UPDATE supplier SET phone = \\$1 WHERE id = \\$2"
-	mock.ExpectExec(query).WithArgs("0123456789", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	query := "UPDATE supplier SET lead_time_days = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs(0, 1, 1).WillReturnResult(sqlmock.NewResult(0, 1))
 
-	mock.ExpectCommit()
+	// same day delivery is a valid lead time
+	w := serveWithHeader(server.UpdateSupplierLeadTime, "PUT", "/suppliers/change-lead-time", `{"id": 1, "lead_time_days": 0}`, nil, matchFirstVersion)
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	err = updateSupplierPhone(db, "0123456789", 1)
-	assert.NoError(t, err)
+	w = serve(server.UpdateSupplierLeadTime, "PUT", "/suppliers/change-lead-time", `{"id": 1, "lead_time_days": -2}`, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -1,32 +1,22 @@
 package controllers
 
 import (
-	"context"
-	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"time"
+	"small_business/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
-	_ "github.com/lib/pq" //avoid import postgres error with sql
 	"golang.org/x/crypto/bcrypt"
 )
 
-// create user struct
-type User struct {
-	ID       int64  `json:"id"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-}
-
-// var pool *sql.DB // Database connection pool.
+// key the auth middleware stores the logged in models.User under
+const UserContextKey = "user"
 
 //handler for creating user
 
-func CreateUser(c *gin.Context) {
+func (s *Server) CreateUser(c *gin.Context) {
 	// receive user data (validated)
 	var body struct {
 		Email    string `json:"email" binding:"required,email"`
@@ -48,26 +38,15 @@ func CreateUser(c *gin.Context) {
 		log.Fatal("Password was not successfully hashed", err)
 	}
 
-	user := User{Email: body.Email, Password: string(hashedPassword)}
+	user := models.User{Email: body.Email, Password: string(hashedPassword)}
 
 	// Log the received user data
 	log.Printf("Received user data: %+v", user)
 
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		panic(err)
-	}
-
-	defer pool.Close()
-
-	ctx := context.Background()
-
-	_, err = pool.ExecContext(ctx, "INSERT INTO users (email, password) VALUES ($1, $2)", user.Email, user.Password)
+	err = s.Users.CreateUser(c.Request.Context(), &user)
 
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{
-			"Error creating new user": err,
-		})
+		respondStoreError(c, err, "Error creating new user", "User not found")
 		return
 
 	} else {
@@ -79,7 +58,7 @@ func CreateUser(c *gin.Context) {
 
 }
 
-func LoginUser(c *gin.Context) {
+func (s *Server) LoginUser(c *gin.Context) {
 	// Parse email and password from body
 	var body struct {
 		Email    string `json:"email" binding:"required,email"`
@@ -95,27 +74,14 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
-	// Open database connection
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Failed to connect to database",
-		})
-		return
-	}
-	defer pool.Close()
-
-	ctx := context.Background()
+	ctx := c.Request.Context()
 
 	// Get user from database
-	var storedEmail, storedHashedPassword string
-	row := pool.QueryRowContext(ctx, "SELECT email, password FROM users WHERE email=$1", body.Email)
-
-	err = row.Scan(&storedEmail, &storedHashedPassword)
+	user, err := s.Users.GetUserByEmail(ctx, body.Email)
 
 	// if email and password not found
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, models.ErrNotFound) {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"message": "Email and/or password is incorrect",
 			})
@@ -128,7 +94,7 @@ func LoginUser(c *gin.Context) {
 	}
 
 	// Compare passwords
-	err = bcrypt.CompareHashAndPassword([]byte(storedHashedPassword), []byte(body.Password))
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"message": "Invalid email or password",
@@ -136,14 +102,17 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
-	// jwt authentication (refreshes every 30 days)
-	var user User
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24 * 30).Unix(),
-	})
+	// short lived access token plus a rotating refresh token for the new session
+	sessionID, refreshToken, err := s.createSession(ctx, user.ID)
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Failed to create session",
+		})
+		return
+	}
 
-	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	tokenString, err := signAccessToken(user, sessionID)
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -153,13 +122,75 @@ func LoginUser(c *gin.Context) {
 		return
 	}
 
-	// set cookie
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("Authorization", tokenString, 3600*24*30, "", "", false, true)
+	// set cookies
+	setAuthCookies(c, tokenString, refreshToken)
 
 	// Successfully authenticated
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   tokenString,
+		"message":       "Login successful",
+		"token":         tokenString,
+		"refresh_token": refreshToken,
+	})
+}
+
+// GET /user-auth/me
+func (s *Server) GetCurrentUser(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error":   "Unauthorized",
+			"message": "No authenticated user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user": user,
+	})
+}
+
+// the user set by middleware.RequireAuth, false on routes without it
+func currentUser(c *gin.Context) (models.User, bool) {
+	value, exists := c.Get(UserContextKey)
+	user, ok := value.(models.User)
+	return user, exists && ok
+}
+
+// whether the user of the request is an admin, for checks finer than RequireRole
+func isAdmin(c *gin.Context) bool {
+	user, ok := currentUser(c)
+	return ok && user.Role == models.RoleAdmin
+}
+
+// PUT /user-auth/role (admin only)
+func (s *Server) UpdateUserRole(c *gin.Context) {
+	var body struct {
+		ID   int64  `json:"id" binding:"required"`
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Error binding JSON data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !models.ValidRole(body.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Role must be one of admin, manager, clerk or read_only",
+		})
+		return
+	}
+
+	if err := s.Users.UpdateUserRole(c.Request.Context(), body.ID, body.Role); err != nil {
+		respondStoreError(c, err, "Error updating user role", "User not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "User Role Updated Successfully",
+		"New Role": body.Role,
 	})
 }
@@ -1,55 +1,31 @@
 package controllers
 
 import (
-	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"small_business/models"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
-// createUser inserts a new user into the database
-func createUser(db *sql.DB, email string, password string) error {
-	// use transactions
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-	//mimic login functionality
-	query := "INSERT INTO users (email, password) VALUES ($1, $2)"
-	_, err = tx.Exec(query, email, password)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	return tx.Commit()
-}
 func TestCreateUser(t *testing.T) {
 	t.Parallel()
 
-	// Create a new mock database connection and sqlmock instance
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-
-	// Define the query we expect to be executed
-	query := "INSERT INTO users \\(email, password\\) VALUES \\(\\$1, \\$2\\)"
+	server, mock := newMockServer(t)
 
-	// Begin a new transaction
-	mock.ExpectBegin()
+	// password is hashed before it reaches the database
+	query := "INSERT INTO users \\(email, password\\) VALUES \\(\\$1, \\$2\\) RETURNING id, role"
+	mock.ExpectQuery(query).WithArgs("test@example.com", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(1, models.RoleReadOnly)) //new row inserted with id 1
 
-	// mock query
-	mock.ExpectExec(query).WithArgs("test@example.com", "hashedpassword").WillReturnResult(sqlmock.NewResult(1, 1)) //new row inserted with id 1
+	w := serve(server.CreateUser, "POST", "/user-auth/register", `{"email": "test@example.com", "password": "password123"}`, nil)
 
-	// Commit the transaction
-	mock.ExpectCommit()
-
-	// Call the function to create a user
-	err = createUser(db, "test@example.com", "hashedpassword")
-	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -57,41 +33,32 @@ func TestCreateUser(t *testing.T) {
 	}
 }
 
-func deleteUserByEmail(db *sql.DB, email string) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
-
-	query := "DELETE FROM users WHERE email = $1"
-	_, err = tx.Exec(query, email)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
+func TestLoginUser(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
 
-	return tx.Commit()
-}
+	server, mock := newMockServer(t)
 
-func TestDeleteUserByEmail(t *testing.T) {
-	t.Parallel()
-
-	db, mock, err := sqlmock.New()
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
 	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+		t.Fatalf("an error '%s' was not expected when hashing a password", err)
 	}
-	defer db.Close()
 
-	mock.ExpectBegin()
+	rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "created_at"}).
+		AddRow(7, "test@example.com", string(hashedPassword), models.RoleClerk, "2024-08-01")
+	mock.ExpectQuery("SELECT id, email, password, role, created_at FROM users WHERE email = \\$1").WithArgs("test@example.com").WillReturnRows(rows)
+	mock.ExpectExec("INSERT INTO refresh_tokens").WithArgs(7, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
 
-	// mock query
-	query := "DELETE FROM users WHERE email = \\$1"
-	mock.ExpectExec(query).WithArgs("test@example.com").WillReturnResult(sqlmock.NewResult(0, 1)) // return no new row with index 1
+	w := serve(server.LoginUser, "POST", "/user-auth/login", `{"email": "test@example.com", "password": "password123"}`, nil)
 
-	mock.ExpectCommit()
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	err = deleteUserByEmail(db, "test@example.com")
-	assert.NoError(t, err)
+	var body struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotEmpty(t, body.Token)
+	assert.NotEmpty(t, body.RefreshToken)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -99,45 +66,78 @@ func TestDeleteUserByEmail(t *testing.T) {
 	}
 }
 
-func updateUserPassword(db *sql.DB, email string, newPassword string) error {
-	tx, err := db.Begin()
-	if err != nil {
-		return err
-	}
+func TestLoginUserWrongPassword(t *testing.T) {
+	t.Parallel()
 
-	query := "UPDATE users SET password = $1 WHERE email = $2"
-	_, err = tx.Exec(query, newPassword, email)
+	server, mock := newMockServer(t)
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
 	if err != nil {
-		tx.Rollback()
-		return err
+		t.Fatalf("an error '%s' was not expected when hashing a password", err)
 	}
 
-	return tx.Commit()
+	rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "created_at"}).
+		AddRow(7, "test@example.com", string(hashedPassword), models.RoleClerk, "2024-08-01")
+	mock.ExpectQuery("SELECT id, email, password, role, created_at FROM users WHERE email = \\$1").WithArgs("test@example.com").WillReturnRows(rows)
+
+	w := serve(server.LoginUser, "POST", "/user-auth/login", `{"email": "test@example.com", "password": "wrongpassword"}`, nil)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
 
-func TestUpdateUserPassword(t *testing.T) {
+func TestUpdateUserRole(t *testing.T) {
 	t.Parallel()
 
-	db, mock, err := sqlmock.New()
-	if err != nil {
-		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
-	}
-	defer db.Close()
-
-	mock.ExpectBegin()
+	server, mock := newMockServer(t)
 
 	//mock query
+	query := "UPDATE users SET role = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs(models.RoleManager, 1).WillReturnResult(sqlmock.NewResult(0, 1))
 
-	query := "UPDATE users SET password = \\$1 WHERE email = \\$2"
-	mock.ExpectExec(query).WithArgs("newhashedpassword", "test@example.com").WillReturnResult(sqlmock.NewResult(0, 1))
+	w := serve(server.UpdateUserRole, "PUT", "/user-auth/role", `{"id": 1, "role": "manager"}`, nil)
 
-	mock.ExpectCommit()
+	assert.Equal(t, http.StatusOK, w.Code)
 
-	err = updateUserPassword(db, "test@example.com", "newhashedpassword")
-	assert.NoError(t, err)
+	// unknown roles never reach the database
+	w = serve(server.UpdateUserRole, "PUT", "/user-auth/role", `{"id": 1, "role": "owner"}`, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Ensure all expectations were met
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestGetCurrentUser(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Set(UserContextKey, models.User{ID: 7, Email: "test@example.com", Password: "hashedpassword"})
+
+	NewServer(models.Stores{}).GetCurrentUser(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "hashedpassword")
+
+	var body struct {
+		User models.User `json:"user"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.EqualValues(t, 7, body.User.ID)
+	assert.Equal(t, "test@example.com", body.User.Email)
+}
+
+func TestGetCurrentUserWithoutAuth(t *testing.T) {
+	t.Parallel()
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	NewServer(models.Stores{}).GetCurrentUser(c)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
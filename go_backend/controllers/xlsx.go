@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// an xlsx is a zip of XML parts, only the few needed to read the first sheet are decoded.
+// Parts larger than this are refused, a small upload can unzip to a lot.
+const maxXLSXPartBytes = 64 << 20
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelationshipID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+// text of a shared or inline string, plain or in formatted runs
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	text := t.Text
+	for _, run := range t.Runs {
+		text += run.Text
+	}
+	return text
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxWorksheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// read the rows of the first sheet of an xlsx file, numbered like in the spreadsheet
+func readXLSX(r io.ReaderAt, size int64) ([]importRecord, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, errors.New("the file is not a valid xlsx file")
+	}
+	parts := map[string]*zip.File{}
+	for _, file := range archive.File {
+		parts[file.Name] = file
+	}
+
+	sheetPath, err := firstSheetPath(parts)
+	if err != nil {
+		return nil, err
+	}
+	var shared xlsxSharedStrings
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		if err := decodeXLSXPart(parts, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, err
+		}
+	}
+	var sheet xlsxWorksheet
+	if err := decodeXLSXPart(parts, sheetPath, &sheet); err != nil {
+		return nil, err
+	}
+
+	records := []importRecord{}
+	line := 0
+	for _, row := range sheet.Rows {
+		// the row number may be left out, it then follows the previous row
+		line++
+		if row.Number > 0 {
+			line = row.Number
+		}
+		cells := []string{}
+		for _, cell := range row.Cells {
+			column := len(cells)
+			if cell.Ref != "" {
+				if column, err = xlsxColumn(cell.Ref); err != nil {
+					return nil, err
+				}
+			}
+			value, err := xlsxValue(cell.Type, cell.Value, cell.Inline, shared)
+			if err != nil {
+				return nil, fmt.Errorf("cell %s: %w", cell.Ref, err)
+			}
+			for len(cells) <= column {
+				cells = append(cells, "")
+			}
+			cells[column] = value
+		}
+		records = append(records, importRecord{Line: line, Cells: cells})
+	}
+	return records, nil
+}
+
+// the part of the first sheet, found through the workbook and its relationships
+func firstSheetPath(parts map[string]*zip.File) (string, error) {
+	var workbook xlsxWorkbook
+	if err := decodeXLSXPart(parts, "xl/workbook.xml", &workbook); err != nil {
+		return "", err
+	}
+	if len(workbook.Sheets) == 0 {
+		return "", errors.New("the xlsx file has no sheets")
+	}
+	var relationships xlsxRelationships
+	if err := decodeXLSXPart(parts, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return "", err
+	}
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID != workbook.Sheets[0].RelationshipID {
+			continue
+		}
+		// targets are relative to xl/ unless absolute within the package
+		if strings.HasPrefix(relationship.Target, "/") {
+			return strings.TrimPrefix(relationship.Target, "/"), nil
+		}
+		return path.Join("xl", relationship.Target), nil
+	}
+	return "", errors.New("the first sheet of the xlsx file is missing")
+}
+
+func decodeXLSXPart(parts map[string]*zip.File, name string, dest any) error {
+	part, ok := parts[name]
+	if !ok {
+		return fmt.Errorf("the xlsx file has no %s", name)
+	}
+	if part.UncompressedSize64 > maxXLSXPartBytes {
+		return fmt.Errorf("%s of the xlsx file is too large", name)
+	}
+	reader, err := part.Open()
+	if err != nil {
+		return fmt.Errorf("cannot read %s of the xlsx file", name)
+	}
+	defer reader.Close()
+	if err := xml.NewDecoder(io.LimitReader(reader, maxXLSXPartBytes)).Decode(dest); err != nil {
+		return fmt.Errorf("cannot read %s of the xlsx file", name)
+	}
+	return nil
+}
+
+// zero based column of a cell reference like "AB12"
+func xlsxColumn(ref string) (int, error) {
+	column := 0
+	letters := 0
+	for _, r := range strings.ToUpper(ref) {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A') + 1
+		letters++
+	}
+	// XFD is the last column
+	if letters == 0 || column > 16384 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return column - 1, nil
+}
+
+// the cell as text. Numbers are written the shortest way, so 12.5 and not 12.500000000000002.
+func xlsxValue(cellType string, value string, inline xlsxText, shared xlsxSharedStrings) (string, error) {
+	switch cellType {
+	case "s":
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 || index >= len(shared.Items) {
+			return "", errors.New("invalid shared string")
+		}
+		return shared.Items[index].String(), nil
+	case "inlineStr":
+		return inline.String(), nil
+	case "", "n":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return strconv.FormatFloat(number, 'f', -1, 64), nil
+		}
+	}
+	return value, nil
+}
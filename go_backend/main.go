@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"small_business/alerts"
 	"small_business/controllers"
+	"small_business/middleware"
+	"small_business/models"
+	"small_business/pricing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // load env file
@@ -39,10 +45,8 @@ func securityHeaders(c *gin.Context) {
 	c.Next()
 }
 
-func main() {
-
-	LoadEnv()
-
+// register every route on a new engine, handlers share the server's stores
+func newRouter(server *controllers.Server) *gin.Engine {
 	r := gin.Default()
 
 	//Security headers
@@ -53,33 +57,209 @@ func main() {
 		c.String(200, "Welcome to the business API")
 	})
 
+	// readiness probe, checks the database pool
+	r.GET("/ready", server.Ready)
+
+	requireAuth := middleware.RequireAuth(server.Users)
+
+	// roles allowed to change the catalog (prices, products, suppliers)
+	managers := middleware.RequireRole(models.RoleAdmin, models.RoleManager)
+	// roles allowed to adjust stock levels
+	stockKeepers := middleware.RequireRole(models.RoleAdmin, models.RoleManager, models.RoleClerk)
+	// purging deleted records cannot be undone
+	admins := middleware.RequireRole(models.RoleAdmin)
+
 	// user handlers
 	user := r.Group("/user-auth")
 	{
-		user.POST("/register", controllers.CreateUser)
-		user.POST("/login", controllers.LoginUser)
+		user.POST("/register", server.CreateUser)
+		user.POST("/login", server.LoginUser)
+		user.POST("/refresh", server.RefreshToken)
+		user.POST("/logout", server.LogoutUser)
+		user.GET("/me", requireAuth, server.GetCurrentUser)
+		user.PUT("/role", requireAuth, middleware.RequireRole(models.RoleAdmin), server.UpdateUserRole)
+	}
+
+	//products handlers (authenticated, every role can read)
+	products := r.Group("/products", requireAuth)
+	{
+		products.GET("/search", server.SearchProducts)
+		products.GET("/by-barcode/:code", server.ViewProductByBarcode)
+		products.GET("/:id", server.ViewProductsById)
+		products.GET("/:id/price-history", server.ViewPriceHistory)
+		products.GET("/:id/scheduled-prices", server.ViewScheduledPrices)
+		products.POST("/:id/scheduled-prices", managers, server.SchedulePrice)
+		products.DELETE("/:id/scheduled-prices/:schedule_id", managers, server.CancelScheduledPrice)
+		products.GET("/", server.ViewProducts) // "/products"
+		products.POST("insert", managers, server.InsertProduct)
+		products.POST("/import", managers, server.ImportProducts)
+		products.PATCH("/:id", managers, server.PatchProduct)
+		// single field updates from before PATCH, kept for existing clients
+		products.PUT("/change-price", managers, server.UpdateProductPrice)
+		products.PUT("/change-cost", managers, server.UpdateProductCost)
+		products.PUT("/change-sku", managers, server.UpdateProductSKU)
+		products.PUT("/change-barcode", managers, server.UpdateProductBarcode)
+		products.DELETE("/remove/:id", managers, server.DeleteProductByID)
+		products.POST("/restore/:id", managers, server.RestoreProductByID)
+		products.DELETE("/purge/:id", admins, server.PurgeProductByID)
+	}
+
+	//stock handlers (authenticated, every role can read)
+	stocks := r.Group("/stocks", requireAuth)
+	{
+		stocks.GET("/", server.ViewStockLevels)
+		stocks.GET("/low", server.ViewLowStock)
+		stocks.GET("/:product_id", server.ViewStockLevel)
+		stocks.GET("/:product_id/history", server.ViewStockHistory)
+		stocks.PUT("/:product_id", stockKeepers, server.UpdateProductStock)
+		stocks.POST("/:product_id/increment", stockKeepers, server.IncrementProductStock)
+		stocks.POST("/:product_id/decrement", stockKeepers, server.DecrementProductStock)
+	}
+
+	//purchase order handlers (authenticated, every role can read, clerks receive goods)
+	purchaseOrders := r.Group("/purchase-orders", requireAuth)
+	{
+		purchaseOrders.GET("/", server.ViewPurchaseOrders)
+		purchaseOrders.GET("/:id", server.ViewPurchaseOrder)
+		purchaseOrders.POST("/", managers, server.CreatePurchaseOrder)
+		purchaseOrders.POST("/:id/submit", managers, server.SubmitPurchaseOrder)
+		purchaseOrders.POST("/:id/cancel", managers, server.CancelPurchaseOrder)
+		purchaseOrders.POST("/:id/receive", stockKeepers, server.ReceivePurchaseOrder)
+	}
+
+	//customer handlers (authenticated, every role can read, stock keepers look after customers)
+	customers := r.Group("/customers", requireAuth)
+	{
+		customers.GET("/", server.ViewCustomers)
+		customers.GET("/:id", server.ViewCustomerByID)
+		customers.POST("/insert", stockKeepers, server.InsertCustomer)
+		customers.PUT("/change-email", stockKeepers, server.UpdateCustomerEmail)
+		customers.PUT("/change-phone", stockKeepers, server.UpdateCustomerPhone)
+		customers.PUT("/change-address", stockKeepers, server.UpdateCustomerAddress)
+		customers.DELETE("/remove/:id", managers, server.DeleteCustomerByID)
+	}
+
+	//sales order handlers (authenticated, every role can read, stock keepers sell)
+	salesOrders := r.Group("/sales-orders", requireAuth)
+	{
+		salesOrders.GET("/", server.ViewSalesOrders)
+		salesOrders.GET("/:id", server.ViewSalesOrder)
+		salesOrders.POST("/", stockKeepers, server.CreateSalesOrder)
+		salesOrders.POST("/:id/confirm", stockKeepers, server.ConfirmSalesOrder)
+		salesOrders.POST("/:id/fulfil", stockKeepers, server.FulfilSalesOrder)
+		salesOrders.POST("/:id/cancel", stockKeepers, server.CancelSalesOrder)
+	}
+
+	//purchasing handlers, suggestions are read by everyone and ordered by managers
+	purchasing := r.Group("/purchasing", requireAuth)
+	{
+		purchasing.GET("/suggestions", server.ViewReorderSuggestions)
+		purchasing.POST("/suggestions/orders", managers, server.CreateSuggestedPurchaseOrders)
+	}
+
+	//analytics handlers (authenticated, every role can read)
+	analytics := r.Group("/analytics", requireAuth)
+	{
+		analytics.GET("/products", server.ViewProductAnalytics)
+		analytics.GET("/suppliers", server.ViewSupplierAnalytics)
+		analytics.GET("/dead-stock", server.ViewDeadStock)
 	}
 
-	//products handlers
-	products := r.Group("/products")
+	//report handlers (authenticated, every role can read)
+	reports := r.Group("/reports", requireAuth)
 	{
-		products.GET("/:id", controllers.ViewProductsById)
-		products.GET("/", controllers.ViewProducts) // "/products"
-		products.POST("insert", controllers.InsertProduct)
-		products.PUT("/change-price", controllers.UpdateProductPrice)
-		products.DELETE("/remove/:id", controllers.DeleteProductByID)
+		reports.GET("/valuation", server.ViewValuationReport)
 	}
 
-	//supplier handlers
-	suppliers := r.Group("/suppliers")
+	//supplier handlers (authenticated, every role can read)
+	suppliers := r.Group("/suppliers", requireAuth)
 	{
-		suppliers.GET("/", controllers.ViewSuppliers)
-		suppliers.GET("/:id", controllers.ViewSuppliersById)
-		suppliers.POST("/insert", controllers.InsertSupplier)
-		suppliers.PUT("/change-email", controllers.UpdateSupplierEmail)
-		suppliers.PUT("/change-phone", controllers.UpdateSupplierPhone)
-		suppliers.DELETE("/remove/:id", controllers.DeleteSupplierByID)
+		suppliers.GET("/", server.ViewSuppliers)
+		suppliers.GET("/:id", server.ViewSuppliersById)
+		suppliers.POST("/insert", managers, server.InsertSupplier)
+		suppliers.POST("/import", managers, server.ImportSuppliers)
+		suppliers.PATCH("/:id", managers, server.PatchSupplier)
+		// single field updates from before PATCH, kept for existing clients
+		suppliers.PUT("/change-email", managers, server.UpdateSupplierEmail)
+		suppliers.PUT("/change-phone", managers, server.UpdateSupplierPhone)
+		suppliers.PUT("/change-lead-time", managers, server.UpdateSupplierLeadTime)
+		suppliers.DELETE("/remove/:id", managers, server.DeleteSupplierByID)
+		suppliers.POST("/restore/:id", managers, server.RestoreSupplierByID)
+		suppliers.DELETE("/purge/:id", admins, server.PurgeSupplierByID)
 	}
 
+	return r
+}
+
+// pick the storage backend from STORAGE ("postgres" by default, or "memory").
+// The returned func releases it on shutdown.
+func openStores() (models.Stores, func(), error) {
+	switch os.Getenv("STORAGE") {
+	case "memory":
+		stores := models.NewMemoryStores()
+		if err := seedAdmin(stores.Users); err != nil {
+			return stores, nil, err
+		}
+		fmt.Println("Using in-memory storage, data is lost on restart")
+		return stores, func() {}, nil
+	case "", "postgres":
+		// one pool for the whole app, created once and shared by every request
+		pool, err := models.ConnectToDB()
+		if err != nil {
+			return models.Stores{}, nil, err
+		}
+		return models.NewPostgresStores(pool), func() { pool.Close() }, nil
+	default:
+		return models.Stores{}, nil, fmt.Errorf("unknown STORAGE %q, use postgres or memory", os.Getenv("STORAGE"))
+	}
+}
+
+// memory storage starts empty, ADMIN_EMAIL/ADMIN_PASSWORD create a first admin to log in with
+func seedAdmin(users models.UserStore) error {
+	email, password := os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return err
+	}
+
+	admin := models.User{Email: email, Password: string(hashedPassword), Role: models.RoleAdmin}
+	return users.CreateUser(context.Background(), &admin)
+}
+
+func main() {
+
+	LoadEnv()
+
+	stores, closeStores, err := openStores()
+	if err != nil {
+		log.Fatal("Error opening storage: ", err)
+	}
+	defer closeStores()
+
+	server := controllers.NewServer(stores)
+
+	// low stock alerts run next to the API until shutdown
+	checker, err := alerts.NewCheckerFromEnv(stores.Stocks)
+	if err != nil {
+		log.Fatal("Error configuring low stock alerts: ", err)
+	}
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go checker.Run(ctx)
+	server.LowStock = checker
+
+	// scheduled prices and promotions are applied in the background too
+	scheduler, err := pricing.NewSchedulerFromEnv(stores.Prices)
+	if err != nil {
+		log.Fatal("Error configuring the price scheduler: ", err)
+	}
+	go scheduler.Run(ctx)
+
+	r := newRouter(server)
+
 	r.Run() //running on port in env due to fresh
 }
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"small_business/controllers"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -28,3 +32,268 @@ func TestPingRoute(t *testing.T) {
 	assert.Equal(t, 200, w.Code)
 	assert.Equal(t, "pong", w.Body.String())
 }
+
+// full router on in-memory storage
+func setupMemoryRouter(t *testing.T) *gin.Engine {
+	t.Setenv("PORT", "3000")
+	t.Setenv("JWT_SECRET", "testsecret")
+	t.Setenv("ADMIN_EMAIL", "admin@example.com")
+	t.Setenv("ADMIN_PASSWORD", "adminpassword")
+	t.Setenv("STORAGE", "memory")
+
+	stores, _, err := openStores()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening memory storage", err)
+	}
+	return newRouter(controllers.NewServer(stores))
+}
+
+// send a JSON request through the router, token may be empty
+func request(router *gin.Engine, method string, path string, token string, body string) *httptest.ResponseRecorder {
+	return requestWithHeader(router, method, path, token, body, nil)
+}
+
+// request with extra headers, like If-Match
+func requestWithHeader(router *gin.Engine, method string, path string, token string, body string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header = header.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Host = "localhost:3000"
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func login(t *testing.T, router *gin.Engine, email string, password string) string {
+	w := request(router, "POST", "/user-auth/login", "", `{"email": "`+email+`", "password": "`+password+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("an error '%s' was not expected when reading the login response", err)
+	}
+	return body.Token
+}
+
+func TestMemoryStorageRoutes(t *testing.T) {
+	router := setupMemoryRouter(t)
+
+	// products need a token
+	w := request(router, "GET", "/products/", "", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	adminToken := login(t, router, "admin@example.com", "adminpassword")
+
+	w = request(router, "POST", "/suppliers/insert", adminToken, `{"name": "testsupplier", "contact_email": "supplier@example.com"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = request(router, "POST", "/products/insert", adminToken, `{"name": "testproduct", "supplier_id": 1, "price": "9.99", "stock": 5, "minimum_stock": 2}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// unique product name and supplier foreign key hold without postgres
+	w = request(router, "POST", "/products/insert", adminToken, `{"name": "testproduct", "supplier_id": 1, "price": "9.99"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = request(router, "POST", "/products/insert", adminToken, `{"name": "otherproduct", "supplier_id": 42, "price": "9.99"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// new accounts are read only
+	w = request(router, "POST", "/user-auth/register", "", `{"email": "clerk@example.com", "password": "clerkpassword"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	clerkToken := login(t, router, "clerk@example.com", "clerkpassword")
+
+	w = request(router, "GET", "/products/1", clerkToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	read := http.Header{"If-Match": {w.Header().Get("ETag")}}
+	w = request(router, "PATCH", "/products/1", clerkToken, `{"minimum_stock": 1}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request(router, "PATCH", "/products/1", adminToken, `{"minimum_stock": 1}`)
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	w = requestWithHeader(router, "PATCH", "/products/1", adminToken, `{"description": "patched", "minimum_stock": 1}`, read)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"description": "patched"`)
+	patched := http.Header{"If-Match": {w.Header().Get("ETag")}}
+	// a second change based on the first read would overwrite the patch
+	w = requestWithHeader(router, "PUT", "/products/change-barcode", adminToken, `{"id": 1, "barcode": "036000291452"}`, read)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = requestWithHeader(router, "PUT", "/products/change-barcode", adminToken, `{"id": 1, "barcode": "036000291452"}`, patched)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "GET", "/products/by-barcode/0036000291452", clerkToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"name": "testproduct"`)
+	// search is not mistaken for a product id, and forgives a typo
+	w = request(router, "GET", "/products/search?q=tesproduct", clerkToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"name": "testproduct"`)
+	w = request(router, "PUT", "/products/change-price", clerkToken, `{"id": 1, "price": "1.00"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// stock changes land in the ledger with the acting user
+	w = request(router, "POST", "/stocks/1/decrement", adminToken, `{"quantity": 2, "reason": "sale", "reference": "order 1"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "GET", "/stocks/1/history", clerkToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"reference": "order 1"`)
+	assert.MatchRegex(t, w.Body.String(), `"net_change": 3`)
+
+	// order, submit and receive goods, read only users can do none of it
+	w = request(router, "POST", "/purchase-orders/", clerkToken, `{"supplier_id": 1, "lines": [{"product_id": 1, "quantity": 4, "unit_cost": "5.00"}]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request(router, "POST", "/purchase-orders/", adminToken, `{"supplier_id": 1, "lines": [{"product_id": 1, "quantity": 4, "unit_cost": "5.00"}]}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = request(router, "POST", "/purchase-orders/1/submit", adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "POST", "/purchase-orders/1/receive", clerkToken, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request(router, "POST", "/purchase-orders/1/receive", adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "GET", "/stocks/1", clerkToken, "")
+	assert.MatchRegex(t, w.Body.String(), `"stock": 7`)
+
+	// sell 5 to a customer, the stock is reserved on confirm and taken on fulfil
+	w = request(router, "POST", "/customers/insert", adminToken, `{"name": "testcustomer", "email": "customer@example.com"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = request(router, "GET", "/customers/?name=TEST", clerkToken, "")
+	assert.MatchRegex(t, w.Body.String(), "customer@example.com")
+	w = request(router, "POST", "/sales-orders/", adminToken, `{"customer_id": 1, "lines": [{"product_id": 1, "quantity": 5}]}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = request(router, "POST", "/sales-orders/1/confirm", adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "POST", "/stocks/1/decrement", adminToken, `{"quantity": 3}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = request(router, "POST", "/sales-orders/1/fulfil", adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "GET", "/stocks/1", clerkToken, "")
+	assert.MatchRegex(t, w.Body.String(), `"stock": 2`)
+	w = request(router, "DELETE", "/customers/remove/1", adminToken, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestMemorySoftDeleteRoutes(t *testing.T) {
+	router := setupMemoryRouter(t)
+	adminToken := login(t, router, "admin@example.com", "adminpassword")
+	w := request(router, "POST", "/user-auth/register", "", `{"email": "clerk@example.com", "password": "clerkpassword"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	clerkToken := login(t, router, "clerk@example.com", "clerkpassword")
+
+	w = request(router, "POST", "/suppliers/insert", adminToken, `{"name": "testsupplier"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "POST", "/products/insert", adminToken, `{"name": "testproduct", "supplier_id": 1, "price": "9.99"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// a supplier with products is kept
+	w = requestWithHeader(router, "DELETE", "/suppliers/remove/1", adminToken, "", http.Header{"If-Match": {"*"}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"name":"testproduct"`)
+
+	w = request(router, "GET", "/products/1", clerkToken, "")
+	w = requestWithHeader(router, "DELETE", "/products/remove/1", adminToken, "", http.Header{"If-Match": {w.Header().Get("ETag")}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// deleted products are gone unless an admin asks for them
+	w = request(router, "GET", "/products/1", clerkToken, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = request(router, "GET", "/products/", clerkToken, "")
+	assert.MatchRegex(t, w.Body.String(), `"total": 0`)
+	w = request(router, "GET", "/products/?include_deleted=true", clerkToken, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request(router, "GET", "/products/1?include_deleted=true", adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"deleted_at": "[^"]+"`)
+
+	// restore is undone by deleting again, purge is for admins only
+	w = request(router, "POST", "/products/restore/1", clerkToken, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request(router, "POST", "/products/restore/1", adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = requestWithHeader(router, "DELETE", "/products/purge/1", adminToken, "", http.Header{"If-Match": {"*"}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	// the ETag read before the first delete is stale by now
+	w = requestWithHeader(router, "DELETE", "/products/remove/1", adminToken, "", http.Header{"If-Match": {`"1"`}})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = requestWithHeader(router, "DELETE", "/products/remove/1", adminToken, "", http.Header{"If-Match": {"*"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "GET", "/products/1?include_deleted=true", adminToken, "")
+	deleted := http.Header{"If-Match": {w.Header().Get("ETag")}}
+	w = requestWithHeader(router, "DELETE", "/products/purge/1", clerkToken, "", deleted)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = request(router, "DELETE", "/products/purge/1", adminToken, "")
+	assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	w = requestWithHeader(router, "DELETE", "/products/purge/1", adminToken, "", deleted)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "GET", "/products/1?include_deleted=true", adminToken, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// the supplier can go now
+	w = requestWithHeader(router, "DELETE", "/suppliers/remove/1", adminToken, "", http.Header{"If-Match": {"*"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "GET", "/suppliers/?include_deleted=true", adminToken, "")
+	assert.MatchRegex(t, w.Body.String(), `"name": "testsupplier"`)
+	w = request(router, "POST", "/suppliers/restore/1", adminToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// products can move to another supplier as theirs goes
+	w = request(router, "POST", "/suppliers/insert", adminToken, `{"name": "othersupplier"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "POST", "/products/insert", adminToken, `{"name": "otherproduct", "supplier_id": 2, "price": "1.00"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = requestWithHeader(router, "DELETE", "/suppliers/remove/2?reassign_to=2", adminToken, "", http.Header{"If-Match": {"*"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = requestWithHeader(router, "DELETE", "/suppliers/remove/2?reassign_to=1", adminToken, "", http.Header{"If-Match": {"*"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = request(router, "GET", "/products/?supplier_id=1", clerkToken, "")
+	assert.MatchRegex(t, w.Body.String(), `"name": "otherproduct"`)
+}
+
+func TestMemoryImportRoutes(t *testing.T) {
+	router := setupMemoryRouter(t)
+	adminToken := login(t, router, "admin@example.com", "adminpassword")
+
+	upload := func(path string, file string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreateFormFile("file", "import.csv")
+		part.Write([]byte(file))
+		form.Close()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, &body)
+		req.Host = "localhost:3000"
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload("/suppliers/import", "name,contact_email,lead_time_days\ntestsupplier,supplier@example.com,3\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// a row with an unknown supplier stops the whole file
+	products := "name,sku,supplier_id,price,stock\ntestproduct,TP-1,1,9.99,5\notherproduct,,2,1.00,1\n"
+	w = upload("/products/import", products)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"row":3`)
+	w = request(router, "GET", "/products/", adminToken, "")
+	assert.MatchRegex(t, w.Body.String(), `"total": 0`)
+
+	w = upload("/products/import?dry_run=true", "name,sku,supplier_id,price,stock\ntestproduct,TP-1,1,9.99,5\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = upload("/products/import", "name,sku,supplier_id,price,stock\ntestproduct,TP-1,1,9.99,5\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"created":1`)
+
+	// the SKU finds the product again
+	w = upload("/products/import", "name,sku,price\nrenamedproduct,TP-1,8.99\n")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.MatchRegex(t, w.Body.String(), `"updated":1`)
+	w = request(router, "GET", "/products/1", adminToken, "")
+	assert.MatchRegex(t, w.Body.String(), `"name": "renamedproduct"`)
+	assert.MatchRegex(t, w.Body.String(), `"stock": 5`)
+}
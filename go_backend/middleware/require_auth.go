@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"small_business/controllers"
+	"small_business/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+// respond with a consistent 401 body and stop the handler chain
+func abortUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"error":   "Unauthorized",
+		"message": message,
+	})
+}
+
+// read the token from the Authorization cookie or an "Authorization: Bearer" header
+func tokenFromRequest(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, token, found := strings.Cut(header, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	cookie, err := c.Cookie("Authorization")
+	if err != nil {
+		return ""
+	}
+	return cookie
+}
+
+// validate signature (HS256 only) and expiry against JWT_SECRET
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodHS256 {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+
+	// jwt only checks exp when it is present, we require it
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, fmt.Errorf("token is expired or has no expiry")
+	}
+
+	return claims, nil
+}
+
+// RequireAuth rejects requests without a valid token and loads the user into the context
+func RequireAuth(users models.UserStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := tokenFromRequest(c)
+		if tokenString == "" {
+			abortUnauthorized(c, "Missing authentication token")
+			return
+		}
+
+		claims, err := parseToken(tokenString)
+		if err != nil {
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		sub, ok := claims["sub"].(float64) // json numbers decode as float64
+		if !ok {
+			abortUnauthorized(c, "Invalid token subject")
+			return
+		}
+
+		sessionID, ok := claims["sid"].(string)
+		if !ok || sessionID == "" {
+			abortUnauthorized(c, "Invalid token session")
+			return
+		}
+
+		user, err := users.GetSessionUser(c.Request.Context(), int64(sub), sessionID)
+		if err != nil {
+			if errors.Is(err, models.ErrNotFound) {
+				abortUnauthorized(c, "Session is no longer valid")
+				return
+			}
+			log.Printf("Error loading authenticated user: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to retrieve user data",
+			})
+			return
+		}
+
+		c.Set(controllers.UserContextKey, user)
+		c.Next()
+	}
+}
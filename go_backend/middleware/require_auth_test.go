@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"small_business/controllers"
+	"small_business/models"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+// router with a single protected route
+func setupAuthRouter(users models.UserStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/protected", RequireAuth(users), func(c *gin.Context) {
+		user := c.MustGet(controllers.UserContextKey).(models.User)
+		c.String(http.StatusOK, user.Email)
+	})
+	return r
+}
+
+func signToken(t *testing.T, secret string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when signing a token", err)
+	}
+	return tokenString
+}
+
+func TestRequireAuthValidToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows([]string{"id", "email", "role", "created_at"}).
+		AddRow(1, "test@example.com", models.RoleClerk, "2024-08-01T12:00:00Z")
+	mock.ExpectQuery("SELECT id, email, role, created_at FROM users WHERE id = \\$1").WithArgs(1, "session1").WillReturnRows(rows)
+
+	router := setupAuthRouter(&models.PostgresUserStore{DB: db})
+
+	tokenString := signToken(t, "testsecret", jwt.MapClaims{
+		"sub": 1,
+		"sid": "session1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "test@example.com", w.Body.String())
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRequireAuthMissingToken(t *testing.T) {
+	router := setupAuthRouter(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "Unauthorized", body["error"])
+	assert.Equal(t, "Missing authentication token", body["message"])
+}
+
+func TestRequireAuthInvalidSignature(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	router := setupAuthRouter(nil)
+
+	tokenString := signToken(t, "wrongsecret", jwt.MapClaims{
+		"sub": 1,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRequireAuthExpiredToken(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	router := setupAuthRouter(nil)
+
+	tokenString := signToken(t, "testsecret", jwt.MapClaims{
+		"sub": 1,
+		"exp": time.Now().Add(-time.Hour).Unix(),
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.AddCookie(&http.Cookie{Name: "Authorization", Value: tokenString})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRequireAuthMissingSession(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+	router := setupAuthRouter(nil)
+
+	tokenString := signToken(t, "testsecret", jwt.MapClaims{
+		"sub": 1,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+tokenString)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestParseTokenRequiresExpiry(t *testing.T) {
+	t.Setenv("JWT_SECRET", "testsecret")
+
+	tokenString := signToken(t, "testsecret", jwt.MapClaims{"sub": 1})
+
+	_, err := parseToken(tokenString)
+	assert.Error(t, err)
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// bearer header wins over cookie
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/", nil)
+	c.Request.Header.Set("Authorization", "Bearer headertoken")
+	c.Request.AddCookie(&http.Cookie{Name: "Authorization", Value: "cookietoken"})
+	assert.Equal(t, "headertoken", tokenFromRequest(c))
+
+	// falls back to cookie
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("GET", "/", nil)
+	c.Request.AddCookie(&http.Cookie{Name: "Authorization", Value: "cookietoken"})
+	assert.Equal(t, "cookietoken", tokenFromRequest(c))
+}
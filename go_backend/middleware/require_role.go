@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"small_business/controllers"
+	"small_business/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole only lets through users whose role is one of roles.
+// Must run after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get(controllers.UserContextKey)
+		user, ok := value.(models.User)
+		if !ok {
+			abortUnauthorized(c, "No authenticated user")
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":   "Forbidden",
+			"message": "Your role does not allow this action",
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"small_business/controllers"
+	"small_business/models"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// router that fakes RequireAuth with the given user
+func setupRoleRouter(user *models.User, roles ...string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/protected", func(c *gin.Context) {
+		if user != nil {
+			c.Set(controllers.UserContextKey, *user)
+		}
+	}, RequireRole(roles...), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+func TestRequireRoleAllowed(t *testing.T) {
+	user := models.User{ID: 1, Role: models.RoleManager}
+	router := setupRoleRouter(&user, models.RoleManager, models.RoleAdmin)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/protected", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRequireRoleForbidden(t *testing.T) {
+	user := models.User{ID: 1, Role: models.RoleReadOnly}
+	router := setupRoleRouter(&user, models.RoleManager, models.RoleAdmin)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/protected", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestRequireRoleWithoutUser(t *testing.T) {
+	router := setupRoleRouter(nil, models.RoleAdmin)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/protected", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'read_only',
+    ADD CONSTRAINT chk_user_role CHECK (role IN ('admin', 'manager', 'clerk', 'read_only'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP CONSTRAINT chk_user_role,
+    DROP COLUMN role;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE products
+    ADD CONSTRAINT chk_products_stock_non_negative CHECK (stock >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE products
+    DROP CONSTRAINT chk_products_stock_non_negative;
+-- +goose StatementEnd
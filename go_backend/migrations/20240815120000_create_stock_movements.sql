@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE stock_movements (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    delta INT NOT NULL,
+    reason VARCHAR(20) NOT NULL CHECK (reason IN ('receipt', 'sale', 'adjustment', 'damage', 'return')),
+    user_id INT,
+    reference VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_product FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE,
+    CONSTRAINT fk_user FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_stock_movements_product_id_created_at ON stock_movements(product_id, created_at);
+
+-- existing stock becomes the opening balance so the ledger adds up
+INSERT INTO stock_movements (product_id, delta, reason, reference)
+SELECT id, stock, 'adjustment', 'opening balance' FROM products WHERE stock <> 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE stock_movements;
+-- +goose StatementEnd
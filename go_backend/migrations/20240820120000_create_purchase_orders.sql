@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE purchase_orders (
+    id SERIAL PRIMARY KEY,
+    supplier_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'submitted', 'partially_received', 'received', 'cancelled')),
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    created_by INT,
+    submitted_at TIMESTAMP,
+    received_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_supplier FOREIGN KEY(supplier_id) REFERENCES "supplier"(id),
+    CONSTRAINT fk_user FOREIGN KEY(created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_purchase_orders_supplier_id ON purchase_orders(supplier_id);
+
+CREATE TABLE purchase_order_lines (
+    id SERIAL PRIMARY KEY,
+    purchase_order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity_ordered INT NOT NULL CHECK (quantity_ordered > 0),
+    quantity_received INT NOT NULL DEFAULT 0 CHECK (quantity_received >= 0 AND quantity_received <= quantity_ordered),
+    unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+    CONSTRAINT fk_purchase_order FOREIGN KEY(purchase_order_id) REFERENCES purchase_orders(id) ON DELETE CASCADE,
+    CONSTRAINT fk_product FOREIGN KEY(product_id) REFERENCES products(id),
+    UNIQUE (purchase_order_id, product_id)
+);
+
+-- what actually arrived and at what cost, a line can be received in several goes
+CREATE TABLE purchase_order_receipts (
+    id SERIAL PRIMARY KEY,
+    purchase_order_line_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_cost DECIMAL(10,2) NOT NULL CHECK (unit_cost >= 0),
+    received_by INT,
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_purchase_order_line FOREIGN KEY(purchase_order_line_id) REFERENCES purchase_order_lines(id) ON DELETE CASCADE,
+    CONSTRAINT fk_user FOREIGN KEY(received_by) REFERENCES users(id) ON DELETE SET NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE purchase_order_receipts;
+DROP TABLE purchase_order_lines;
+DROP TABLE purchase_orders;
+-- +goose StatementEnd
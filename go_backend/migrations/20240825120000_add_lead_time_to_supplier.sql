@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE supplier
+    ADD COLUMN lead_time_days INT NOT NULL DEFAULT 7,
+    ADD CONSTRAINT chk_supplier_lead_time CHECK (lead_time_days >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE supplier
+    DROP CONSTRAINT chk_supplier_lead_time,
+    DROP COLUMN lead_time_days;
+-- +goose StatementEnd
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE customers (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(100) UNIQUE,
+    phone VARCHAR(20),
+    address VARCHAR(255),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- stock held for confirmed sales orders, manual decrements cannot take it
+ALTER TABLE products ADD COLUMN reserved INT NOT NULL DEFAULT 0;
+ALTER TABLE products ADD CONSTRAINT chk_products_reserved_non_negative CHECK (reserved >= 0);
+
+CREATE TABLE sales_orders (
+    id SERIAL PRIMARY KEY,
+    customer_id INT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'confirmed', 'fulfilled', 'cancelled')),
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    created_by INT,
+    confirmed_at TIMESTAMP,
+    fulfilled_at TIMESTAMP,
+    cancelled_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_customer FOREIGN KEY(customer_id) REFERENCES customers(id),
+    CONSTRAINT fk_user FOREIGN KEY(created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_sales_orders_customer_id ON sales_orders(customer_id);
+
+-- unit_price is copied from products.price when the order is created
+CREATE TABLE sales_order_lines (
+    id SERIAL PRIMARY KEY,
+    sales_order_id INT NOT NULL,
+    product_id INT NOT NULL,
+    quantity INT NOT NULL CHECK (quantity > 0),
+    unit_price DECIMAL(10,2) NOT NULL CHECK (unit_price >= 0),
+    CONSTRAINT fk_sales_order FOREIGN KEY(sales_order_id) REFERENCES sales_orders(id) ON DELETE CASCADE,
+    CONSTRAINT fk_product FOREIGN KEY(product_id) REFERENCES products(id),
+    UNIQUE (sales_order_id, product_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE sales_order_lines;
+DROP TABLE sales_orders;
+ALTER TABLE products DROP CONSTRAINT chk_products_reserved_non_negative;
+ALTER TABLE products DROP COLUMN reserved;
+DROP TABLE customers;
+-- +goose StatementEnd
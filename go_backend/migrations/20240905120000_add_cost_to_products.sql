@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- what one unit costs us, values the stock in /reports/valuation
+ALTER TABLE products
+    ADD COLUMN cost DECIMAL(10,2) NOT NULL DEFAULT 0,
+    ADD CONSTRAINT chk_products_cost_non_negative CHECK (cost >= 0);
+
+-- start from the latest receipt cost where there is one
+UPDATE products p SET cost = r.unit_cost
+FROM (
+    SELECT DISTINCT ON (l.product_id) l.product_id, r.unit_cost
+    FROM purchase_order_receipts r JOIN purchase_order_lines l ON l.id = r.purchase_order_line_id
+    ORDER BY l.product_id, r.received_at DESC, r.id DESC
+) r
+WHERE r.product_id = p.id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE products
+    DROP CONSTRAINT chk_products_cost_non_negative,
+    DROP COLUMN cost;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- GET /products filters by supplier and sorts by these columns, ties are broken by id
+CREATE INDEX idx_products_supplier_id ON products(supplier_id);
+CREATE INDEX idx_products_price_id ON products(price, id);
+CREATE INDEX idx_products_created_at_id ON products(created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_products_created_at_id;
+DROP INDEX idx_products_price_id;
+DROP INDEX idx_products_supplier_id;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- GET /products/search, words of the name weigh more than the description
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE products
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', name), 'A') ||
+        setweight(to_tsvector('english', COALESCE(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+-- trigrams find names with typos in them
+CREATE INDEX idx_products_name_trgm ON products USING GIN (name gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_products_name_trgm;
+DROP INDEX idx_products_search_vector;
+ALTER TABLE products DROP COLUMN search_vector;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- both optional, barcodes are stored as 13 digits (EAN-13, UPC-A with a leading zero)
+ALTER TABLE products
+    ADD COLUMN sku VARCHAR(64) UNIQUE,
+    ADD COLUMN barcode CHAR(13) UNIQUE,
+    ADD CONSTRAINT chk_products_barcode_digits CHECK (barcode ~ '^[0-9]{13}$');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE products
+    DROP CONSTRAINT chk_products_barcode_digits,
+    DROP COLUMN barcode,
+    DROP COLUMN sku;
+-- +goose StatementEnd
@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+-- future prices and promotions, applied by the price scheduler
+CREATE TABLE scheduled_prices (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    price DECIMAL(6,2) NOT NULL,
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    revert_price DECIMAL(6,2),
+    created_by INT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_scheduled_prices_product FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE,
+    CONSTRAINT fk_scheduled_prices_user FOREIGN KEY(created_by) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT chk_scheduled_prices_price_non_negative CHECK (price >= 0),
+    CONSTRAINT chk_scheduled_prices_ends_after_start CHECK (ends_at IS NULL OR ends_at > starts_at),
+    CONSTRAINT chk_scheduled_prices_status CHECK (status IN ('pending', 'active', 'done', 'cancelled'))
+);
+
+CREATE INDEX idx_scheduled_prices_status_starts_at ON scheduled_prices(status, starts_at);
+
+-- every price change, written in the transaction that changed products.price
+CREATE TABLE product_prices (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    old_price DECIMAL(6,2) NOT NULL,
+    new_price DECIMAL(6,2) NOT NULL,
+    reason VARCHAR(20) NOT NULL,
+    changed_by INT,
+    scheduled_price_id INT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_product_prices_product FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE,
+    CONSTRAINT fk_product_prices_user FOREIGN KEY(changed_by) REFERENCES users(id) ON DELETE SET NULL,
+    CONSTRAINT fk_product_prices_scheduled_price FOREIGN KEY(scheduled_price_id) REFERENCES scheduled_prices(id)
+);
+
+CREATE INDEX idx_product_prices_product_id_created_at ON product_prices(product_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE product_prices;
+DROP TABLE scheduled_prices;
+-- +goose StatementEnd
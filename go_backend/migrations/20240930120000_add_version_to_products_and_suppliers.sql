@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the version is the ETag of the API, every update bumps it so a stale If-Match fails
+ALTER TABLE products ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE supplier ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+CREATE FUNCTION bump_version() RETURNS trigger AS $$
+BEGIN
+    NEW.version := OLD.version + 1;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_products_version BEFORE UPDATE ON products
+    FOR EACH ROW EXECUTE FUNCTION bump_version();
+CREATE TRIGGER trg_supplier_version BEFORE UPDATE ON supplier
+    FOR EACH ROW EXECUTE FUNCTION bump_version();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER trg_supplier_version ON supplier;
+DROP TRIGGER trg_products_version ON products;
+DROP FUNCTION bump_version();
+ALTER TABLE supplier DROP COLUMN version;
+ALTER TABLE products DROP COLUMN version;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- deleted rows stay until purged and keep their unique names, SKUs and barcodes so a restore never collides
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP;
+ALTER TABLE supplier ADD COLUMN deleted_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE supplier DROP COLUMN deleted_at;
+ALTER TABLE products DROP COLUMN deleted_at;
+-- +goose StatementEnd
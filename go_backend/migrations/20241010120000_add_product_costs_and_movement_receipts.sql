@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+-- every change of products.cost, so stock can be valued at the cost it had on an earlier day
+CREATE TABLE product_costs (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL,
+    old_cost DECIMAL(10,2) NOT NULL,
+    new_cost DECIMAL(10,2) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_product_costs_product FOREIGN KEY(product_id) REFERENCES products(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_product_costs_product_id_created_at ON product_costs(product_id, created_at);
+
+-- a trigger like bump_version, so no path that changes the cost can skip the history
+CREATE FUNCTION record_product_cost() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO product_costs (product_id, old_cost, new_cost) VALUES (NEW.id, OLD.cost, NEW.cost);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_products_cost AFTER UPDATE OF cost ON products
+    FOR EACH ROW WHEN (OLD.cost IS DISTINCT FROM NEW.cost) EXECUTE FUNCTION record_product_cost();
+
+-- the receipt a receipt movement booked, its unit cost is what the stock came in at
+ALTER TABLE stock_movements ADD COLUMN receipt_id INT,
+    ADD CONSTRAINT fk_stock_movements_receipt FOREIGN KEY(receipt_id) REFERENCES purchase_order_receipts(id) ON DELETE SET NULL;
+
+-- earlier receipts were written in one transaction with their movement, so both carry its timestamp
+UPDATE stock_movements m SET receipt_id = (
+    SELECT r.id FROM purchase_order_receipts r
+    JOIN purchase_order_lines l ON l.id = r.purchase_order_line_id
+    WHERE l.product_id = m.product_id AND m.reference = 'PO-' || l.purchase_order_id AND r.received_at = m.created_at
+    ORDER BY r.id LIMIT 1
+)
+WHERE m.reason = 'receipt';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE stock_movements DROP COLUMN receipt_id;
+DROP TRIGGER trg_products_cost ON products;
+DROP FUNCTION record_product_cost();
+DROP TABLE product_costs;
+-- +goose StatementEnd
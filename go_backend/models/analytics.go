@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// raw numbers behind the analytics of one product over a window
+type ProductActivity struct {
+	ProductID      int64
+	Name           string
+	SupplierID     int64
+	SupplierName   string
+	Stock          int
+	NetChange      int             // sum of every movement since the start of the window
+	UnitsSold      int             // sale movements since the start of the window
+	Revenue        decimal.Decimal // units sold at the sales order price, or the current price for manual sales
+	LastMovementAt time.Time       // zero if the product never moved
+}
+
+// stock at the start of the window
+func (a ProductActivity) OpeningStock() int {
+	return a.Stock - a.NetChange
+}
+
+// AnalyticsStore reads what the analytics and reports are computed from
+type AnalyticsStore interface {
+	// ListProductActivity returns every product sorted by supplier, with its movements since
+	ListProductActivity(ctx context.Context, since time.Time) ([]ProductActivity, error)
+	// ListValuationInputs returns every product sorted by supplier, with its cost, price and ledger before asOf
+	ListValuationInputs(ctx context.Context, asOf time.Time) ([]ValuationInput, error)
+}
+
+// ABC classes, A products make up the first 80% of revenue, B the next 15%
+const (
+	ClassA = "A"
+	ClassB = "B"
+	ClassC = "C"
+)
+
+type ProductAnalytics struct {
+	ProductID    int64           `json:"product_id"`
+	Name         string          `json:"name"`
+	SupplierID   int64           `json:"supplier_id"`
+	Stock        int             `json:"stock"`
+	UnitsSold    int             `json:"units_sold"`
+	Revenue      decimal.Decimal `json:"revenue"`
+	Turnover     *float64        `json:"turnover"`      // null without any stock to turn over
+	DaysOfCover  *float64        `json:"days_of_cover"` // null without sales
+	Class        string          `json:"abc_class"`
+	RevenueShare float64         `json:"revenue_share"`
+}
+
+type SupplierAnalytics struct {
+	SupplierID   int64           `json:"supplier_id"`
+	SupplierName string          `json:"supplier_name"`
+	Products     int             `json:"products"`
+	Stock        int             `json:"stock"`
+	UnitsSold    int             `json:"units_sold"`
+	Revenue      decimal.Decimal `json:"revenue"`
+	Turnover     *float64        `json:"turnover"`
+	DaysOfCover  *float64        `json:"days_of_cover"`
+	RevenueShare float64         `json:"revenue_share"`
+	ClassA       int             `json:"class_a"`
+	ClassB       int             `json:"class_b"`
+	ClassC       int             `json:"class_c"`
+}
+
+type DeadStockItem struct {
+	ProductID      int64  `json:"product_id"`
+	Name           string `json:"name"`
+	SupplierID     int64  `json:"supplier_id"`
+	SupplierName   string `json:"supplier_name"`
+	Stock          int    `json:"stock"`
+	LastMovementAt string `json:"last_movement_at"` // empty if the product never moved
+}
+
+func round2(value float64) *float64 {
+	rounded := math.Round(value*100) / 100
+	return &rounded
+}
+
+// turnover is units sold over the average of opening and closing stock,
+// days of cover is how long the stock lasts at the average daily sales
+func turnoverAndCover(unitsSold int, opening int, closing int, windowDays int) (*float64, *float64) {
+	var turnover, cover *float64
+	if average := float64(opening+closing) / 2; average > 0 {
+		turnover = round2(float64(unitsSold) / average)
+	}
+	if unitsSold > 0 {
+		cover = round2(float64(closing) / (float64(unitsSold) / float64(windowDays)))
+	}
+	return turnover, cover
+}
+
+// AnalyzeProducts works out turnover, days of cover and the ABC class of
+// every product over the last windowDays, in the order of activity
+func AnalyzeProducts(activity []ProductActivity, windowDays int) []ProductAnalytics {
+	total := decimal.Zero
+	for _, a := range activity {
+		total = total.Add(a.Revenue)
+	}
+
+	products := make([]ProductAnalytics, 0, len(activity))
+	for _, a := range activity {
+		product := ProductAnalytics{
+			ProductID:  a.ProductID,
+			Name:       a.Name,
+			SupplierID: a.SupplierID,
+			Stock:      a.Stock,
+			UnitsSold:  a.UnitsSold,
+			Revenue:    a.Revenue,
+			Class:      ClassC,
+		}
+		product.Turnover, product.DaysOfCover = turnoverAndCover(a.UnitsSold, a.OpeningStock(), a.Stock, windowDays)
+		if total.IsPositive() {
+			product.RevenueShare = *round2(a.Revenue.Div(total).InexactFloat64() * 100)
+		}
+		products = append(products, product)
+	}
+
+	// rank by revenue, a product belongs to the class the revenue before it falls in
+	ranked := make([]int, len(products))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return products[ranked[i]].Revenue.GreaterThan(products[ranked[j]].Revenue)
+	})
+	before := decimal.Zero
+	for _, i := range ranked {
+		if !products[i].Revenue.IsPositive() {
+			break
+		}
+		share := before.Div(total).InexactFloat64()
+		switch {
+		case share < 0.8:
+			products[i].Class = ClassA
+		case share < 0.95:
+			products[i].Class = ClassB
+		}
+		before = before.Add(products[i].Revenue)
+	}
+	return products
+}
+
+// SummarizeSuppliers adds up the product analytics per supplier. activity and
+// products must be in the same order, sorted by supplier.
+func SummarizeSuppliers(activity []ProductActivity, products []ProductAnalytics, windowDays int) []SupplierAnalytics {
+	suppliers := []SupplierAnalytics{}
+	opening := 0
+	for i, a := range activity {
+		if len(suppliers) == 0 || suppliers[len(suppliers)-1].SupplierID != a.SupplierID {
+			suppliers = append(suppliers, SupplierAnalytics{SupplierID: a.SupplierID, SupplierName: a.SupplierName, Revenue: decimal.Zero})
+			opening = 0
+		}
+		supplier := &suppliers[len(suppliers)-1]
+		supplier.Products++
+		supplier.Stock += a.Stock
+		supplier.UnitsSold += a.UnitsSold
+		supplier.Revenue = supplier.Revenue.Add(a.Revenue)
+		supplier.RevenueShare = *round2(supplier.RevenueShare + products[i].RevenueShare)
+		switch products[i].Class {
+		case ClassA:
+			supplier.ClassA++
+		case ClassB:
+			supplier.ClassB++
+		default:
+			supplier.ClassC++
+		}
+
+		opening += a.OpeningStock()
+		supplier.Turnover, supplier.DaysOfCover = turnoverAndCover(supplier.UnitsSold, opening, supplier.Stock, windowDays)
+	}
+	return suppliers
+}
+
+// FindDeadStock returns the products with stock that did not move since cutoff
+func FindDeadStock(activity []ProductActivity, cutoff time.Time) []DeadStockItem {
+	items := []DeadStockItem{}
+	for _, a := range activity {
+		if a.Stock <= 0 || a.LastMovementAt.After(cutoff) {
+			continue
+		}
+		item := DeadStockItem{
+			ProductID:    a.ProductID,
+			Name:         a.Name,
+			SupplierID:   a.SupplierID,
+			SupplierName: a.SupplierName,
+			Stock:        a.Stock,
+		}
+		if !a.LastMovementAt.IsZero() {
+			item.LastMovementAt = a.LastMovementAt.UTC().Format(time.RFC3339)
+		}
+		items = append(items, item)
+	}
+	return items
+}
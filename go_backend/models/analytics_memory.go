@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func (m *MemoryStore) ListProductActivity(ctx context.Context, since time.Time) ([]ProductActivity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// captured prices by movement reference and product
+	type saleKey struct {
+		reference string
+		productID int64
+	}
+	prices := map[saleKey]decimal.Decimal{}
+	for _, order := range m.salesOrders {
+		for _, line := range order.Lines {
+			prices[saleKey{salesOrderReference(order.ID), line.ProductID}] = line.UnitPrice
+		}
+	}
+
+	byProduct := map[int64]*ProductActivity{}
+	for _, product := range m.products {
+		if product.DeletedAt != "" {
+			continue
+		}
+		supplier := m.suppliers[product.SupplierID]
+		byProduct[product.ID] = &ProductActivity{
+			ProductID:    product.ID,
+			Name:         product.Name,
+			SupplierID:   supplier.ID,
+			SupplierName: supplier.Name,
+			Stock:        product.Stock,
+			Revenue:      decimal.Zero,
+		}
+	}
+
+	for _, movement := range m.movements {
+		a, ok := byProduct[movement.ProductID]
+		if !ok {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339Nano, movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if createdAt.After(a.LastMovementAt) {
+			a.LastMovementAt = createdAt
+		}
+		if createdAt.Before(since) {
+			continue
+		}
+
+		a.NetChange += movement.Delta
+		if movement.Reason == ReasonSale && movement.Delta < 0 {
+			price, ok := prices[saleKey{movement.Reference, movement.ProductID}]
+			if !ok {
+				price = m.products[movement.ProductID].Price
+			}
+			a.UnitsSold -= movement.Delta
+			a.Revenue = a.Revenue.Add(price.Mul(decimal.NewFromInt(int64(-movement.Delta))))
+		}
+	}
+
+	activity := make([]ProductActivity, 0, len(byProduct))
+	for _, a := range byProduct {
+		activity = append(activity, *a)
+	}
+	sort.Slice(activity, func(i, j int) bool {
+		if activity[i].SupplierID != activity[j].SupplierID {
+			return activity[i].SupplierID < activity[j].SupplierID
+		}
+		return activity[i].ProductID < activity[j].ProductID
+	})
+	return activity, nil
+}
+
+func (m *MemoryStore) ListValuationInputs(ctx context.Context, asOf time.Time) ([]ValuationInput, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	unitCosts := map[int64]decimal.Decimal{}
+	for _, order := range m.purchaseOrders {
+		for _, receipt := range order.Receipts {
+			unitCosts[receipt.ID] = receipt.UnitCost
+		}
+	}
+
+	byProduct := map[int64]*ValuationInput{}
+	for _, product := range m.products {
+		// products deleted since asOf were still there
+		if product.DeletedAt != "" {
+			deletedAt, err := time.Parse(time.RFC3339Nano, product.DeletedAt)
+			if err != nil {
+				return nil, err
+			}
+			if deletedAt.Before(asOf) {
+				continue
+			}
+		}
+		supplier := m.suppliers[product.SupplierID]
+		byProduct[product.ID] = &ValuationInput{
+			ProductID:    product.ID,
+			Name:         product.Name,
+			SupplierID:   supplier.ID,
+			SupplierName: supplier.Name,
+			Cost:         product.Cost,
+			Price:        product.Price,
+		}
+	}
+
+	// the cost and price at asOf are what the first later change replaced
+	changedSince := func(createdAt string) (bool, error) {
+		changed, err := time.Parse(time.RFC3339Nano, createdAt)
+		return !changed.Before(asOf), err
+	}
+	for i := len(m.costHistory) - 1; i >= 0; i-- {
+		change := m.costHistory[i]
+		later, err := changedSince(change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if input, ok := byProduct[change.ProductID]; ok && later {
+			input.Cost = change.OldCost
+		}
+	}
+	for i := len(m.priceHistory) - 1; i >= 0; i-- {
+		change := m.priceHistory[i]
+		later, err := changedSince(change.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if input, ok := byProduct[change.ProductID]; ok && later {
+			input.Price = change.OldPrice
+		}
+	}
+
+	for _, movement := range m.movements {
+		input, ok := byProduct[movement.ProductID]
+		if !ok {
+			continue
+		}
+		costed := CostedMovement{Delta: movement.Delta}
+		if movement.ReceiptID != nil {
+			if unitCost, ok := unitCosts[*movement.ReceiptID]; ok {
+				costed.UnitCost = &unitCost
+			}
+		}
+
+		createdAt, err := time.Parse(time.RFC3339Nano, movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if !createdAt.Before(asOf) {
+			continue
+		}
+		input.Movements = append(input.Movements, costed)
+	}
+
+	inputs := make([]ValuationInput, 0, len(byProduct))
+	for _, input := range byProduct {
+		inputs = append(inputs, *input)
+	}
+	sort.Slice(inputs, func(i, j int) bool {
+		if inputs[i].SupplierID != inputs[j].SupplierID {
+			return inputs[i].SupplierID < inputs[j].SupplierID
+		}
+		return inputs[i].ProductID < inputs[j].ProductID
+	})
+	return inputs, nil
+}
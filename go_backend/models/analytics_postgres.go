@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PostgresAnalyticsStore aggregates products and stock_movements
+type PostgresAnalyticsStore struct {
+	DB *sql.DB
+}
+
+func (s *PostgresAnalyticsStore) ListProductActivity(ctx context.Context, since time.Time) ([]ProductActivity, error) {
+	// sales of a sales order are valued at the captured price, manual sales at today's price
+	query := `SELECT p.id, p.name, s.id, s.name, p.stock,
+			COALESCE((SELECT SUM(m.delta) FROM stock_movements m
+				WHERE m.product_id = p.id AND m.created_at >= $1), 0),
+			COALESCE((SELECT -SUM(m.delta) FROM stock_movements m
+				WHERE m.product_id = p.id AND m.reason = 'sale' AND m.delta < 0 AND m.created_at >= $1), 0),
+			COALESCE((SELECT SUM(-m.delta * COALESCE(l.unit_price, p.price)) FROM stock_movements m
+				LEFT JOIN sales_order_lines l ON l.product_id = m.product_id AND m.reference = 'SO-' || l.sales_order_id
+				WHERE m.product_id = p.id AND m.reason = 'sale' AND m.delta < 0 AND m.created_at >= $1), 0),
+			(SELECT MAX(m.created_at) FROM stock_movements m WHERE m.product_id = p.id)
+		FROM products p JOIN supplier s ON s.id = p.supplier_id
+		WHERE p.deleted_at IS NULL
+		ORDER BY s.id, p.id`
+	rows, err := s.DB.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	activity := []ProductActivity{}
+	for rows.Next() {
+		var (
+			a            ProductActivity
+			lastMovement sql.NullTime
+		)
+		err := rows.Scan(&a.ProductID, &a.Name, &a.SupplierID, &a.SupplierName, &a.Stock, &a.NetChange, &a.UnitsSold, &a.Revenue, &lastMovement)
+		if err != nil {
+			return nil, err
+		}
+		a.LastMovementAt = lastMovement.Time
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
+func (s *PostgresAnalyticsStore) ListValuationInputs(ctx context.Context, asOf time.Time) ([]ValuationInput, error) {
+	// the cost and price at asOf are what the first later change replaced, today's without one.
+	// Products deleted since asOf were still there, an earlier report does not change after the fact.
+	query := `SELECT p.id, p.name, s.id, s.name,
+			COALESCE((SELECT c.old_cost FROM product_costs c WHERE c.product_id = p.id AND c.created_at >= $1 ORDER BY c.created_at, c.id LIMIT 1), p.cost),
+			COALESCE((SELECT h.old_price FROM product_prices h WHERE h.product_id = p.id AND h.created_at >= $1 ORDER BY h.created_at, h.id LIMIT 1), p.price)
+		FROM products p JOIN supplier s ON s.id = p.supplier_id
+		WHERE p.deleted_at IS NULL OR p.deleted_at >= $1
+		ORDER BY s.id, p.id`
+	rows, err := s.DB.QueryContext(ctx, query, asOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	inputs := []ValuationInput{}
+	byProduct := map[int64]int{}
+	for rows.Next() {
+		var input ValuationInput
+		if err := rows.Scan(&input.ProductID, &input.Name, &input.SupplierID, &input.SupplierName, &input.Cost, &input.Price); err != nil {
+			return nil, err
+		}
+		byProduct[input.ProductID] = len(inputs)
+		inputs = append(inputs, input)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query = `SELECT m.product_id, m.delta, r.unit_cost
+		FROM stock_movements m
+		LEFT JOIN purchase_order_receipts r ON r.id = m.receipt_id
+		WHERE m.created_at < $1
+		ORDER BY m.product_id, m.created_at, m.id`
+	movements, err := s.DB.QueryContext(ctx, query, asOf)
+	if err != nil {
+		return nil, err
+	}
+	defer movements.Close()
+
+	for movements.Next() {
+		var (
+			productID int64
+			movement  CostedMovement
+			unitCost  decimal.NullDecimal
+		)
+		if err := movements.Scan(&productID, &movement.Delta, &unitCost); err != nil {
+			return nil, err
+		}
+		if unitCost.Valid {
+			movement.UnitCost = &unitCost.Decimal
+		}
+		if i, ok := byProduct[productID]; ok {
+			inputs[i].Movements = append(inputs[i].Movements, movement)
+		}
+	}
+	return inputs, movements.Err()
+}
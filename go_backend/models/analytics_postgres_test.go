@@ -0,0 +1,77 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresListProductActivity(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	since := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	moved := time.Date(2024, 8, 20, 12, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "name", "id", "name", "stock", "net_change", "units_sold", "revenue", "last_movement"}).
+		AddRow(1, "testproduct", 1, "testsupplier", 4, -6, 6, "15.00", moved).
+		AddRow(2, "otherproduct", 1, "testsupplier", 0, 0, 0, "0", nil)
+	mock.ExpectQuery("FROM products p JOIN supplier s ON s.id = p.supplier_id\\s+WHERE p.deleted_at IS NULL\\s+ORDER BY s.id, p.id").WithArgs(since).WillReturnRows(rows)
+
+	store := &PostgresAnalyticsStore{DB: db}
+	activity, err := store.ListProductActivity(context.Background(), since)
+	assert.NoError(t, err)
+	assert.Len(t, activity, 2)
+	assert.Equal(t, 10, activity[0].OpeningStock())
+	assert.True(t, decimal.NewFromInt(15).Equal(activity[0].Revenue))
+	assert.True(t, moved.Equal(activity[0].LastMovementAt))
+	assert.True(t, activity[1].LastMovementAt.IsZero())
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresListValuationInputs(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// cost and price come from their history at asOf, receipt costs through the movement's receipt
+	asOf := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+	products := sqlmock.NewRows([]string{"id", "name", "id", "name", "cost", "price"}).
+		AddRow(1, "testproduct", 1, "testsupplier", "2.00", "5.00")
+	mock.ExpectQuery("SELECT p.id, p.name, s.id, s.name,\\s+COALESCE\\(\\(SELECT c.old_cost FROM product_costs c (.+)\\), p.cost\\),\\s+" +
+		"COALESCE\\(\\(SELECT h.old_price FROM product_prices h (.+)\\), p.price\\)\\s+FROM products p JOIN supplier s ON s.id = p.supplier_id\\s+WHERE p.deleted_at IS NULL OR p.deleted_at >= \\$1").
+		WithArgs(asOf).WillReturnRows(products)
+	movements := sqlmock.NewRows([]string{"product_id", "delta", "unit_cost"}).
+		AddRow(1, 3, nil).
+		AddRow(1, 4, "2.50")
+	mock.ExpectQuery("FROM stock_movements m\\s+LEFT JOIN purchase_order_receipts r ON r.id = m.receipt_id").WithArgs(asOf).WillReturnRows(movements)
+
+	store := &PostgresAnalyticsStore{DB: db}
+	inputs, err := store.ListValuationInputs(context.Background(), asOf)
+	assert.NoError(t, err)
+	assert.Len(t, inputs, 1)
+	assert.Len(t, inputs[0].Movements, 2)
+	assert.Nil(t, inputs[0].Movements[0].UnitCost)
+	assert.True(t, decimal.RequireFromString("2.50").Equal(*inputs[0].Movements[1].UnitCost))
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
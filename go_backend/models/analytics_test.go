@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyzeProducts(t *testing.T) {
+	t.Parallel()
+
+	activity := []ProductActivity{
+		// 20 sold out of 30 at the start, 10 left: turnover 20 / 20, 10 days of cover
+		{ProductID: 1, Name: "fastmover", SupplierID: 1, Stock: 10, NetChange: -20, UnitsSold: 20, Revenue: decimal.NewFromInt(850)},
+		{ProductID: 2, Name: "midmover", SupplierID: 1, Stock: 5, NetChange: -1, UnitsSold: 1, Revenue: decimal.NewFromInt(100)},
+		{ProductID: 3, Name: "tail", SupplierID: 2, Stock: 4, NetChange: -1, UnitsSold: 1, Revenue: decimal.NewFromInt(50)},
+		// nothing to turn over and nothing sold
+		{ProductID: 4, Name: "empty", SupplierID: 2, Revenue: decimal.Zero},
+	}
+
+	products := AnalyzeProducts(activity, 10)
+
+	assert.Equal(t, 1.0, *products[0].Turnover)
+	assert.Equal(t, 5.0, *products[0].DaysOfCover)
+	assert.Equal(t, 85.0, products[0].RevenueShare)
+	assert.Equal(t, ClassA, products[0].Class)
+	assert.Equal(t, ClassB, products[1].Class)
+	assert.Equal(t, ClassC, products[2].Class)
+	assert.Equal(t, ClassC, products[3].Class)
+	assert.Nil(t, products[3].Turnover)
+	assert.Nil(t, products[3].DaysOfCover)
+
+	suppliers := SummarizeSuppliers(activity, products, 10)
+	assert.Len(t, suppliers, 2)
+	assert.Equal(t, 2, suppliers[0].Products)
+	assert.Equal(t, 21, suppliers[0].UnitsSold)
+	assert.True(t, decimal.NewFromInt(950).Equal(suppliers[0].Revenue))
+	assert.Equal(t, 95.0, suppliers[0].RevenueShare)
+	assert.Equal(t, 1, suppliers[0].ClassA)
+	assert.Equal(t, 1, suppliers[0].ClassB)
+	assert.Equal(t, 2, suppliers[1].ClassC)
+}
+
+func TestFindDeadStock(t *testing.T) {
+	t.Parallel()
+
+	cutoff := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	activity := []ProductActivity{
+		{ProductID: 1, Name: "moved", Stock: 3, LastMovementAt: cutoff.AddDate(0, 0, 5)},
+		{ProductID: 2, Name: "dusty", Stock: 3, LastMovementAt: cutoff.AddDate(0, -2, 0)},
+		{ProductID: 3, Name: "never", Stock: 1},
+		{ProductID: 4, Name: "soldout", LastMovementAt: cutoff.AddDate(-1, 0, 0)},
+	}
+
+	items := FindDeadStock(activity, cutoff)
+
+	assert.Len(t, items, 2)
+	assert.Equal(t, "2024-04-01T00:00:00Z", items[0].LastMovementAt)
+	assert.EqualValues(t, 3, items[1].ProductID)
+	assert.Empty(t, items[1].LastMovementAt)
+}
@@ -0,0 +1,32 @@
+package models
+
+// NormalizeBarcode checks the digits and check digit of an EAN-13 or UPC-A barcode.
+// It returns the code as 13 digits, a UPC-A code is an EAN-13 code with a leading zero.
+func NormalizeBarcode(code string) (string, bool) {
+	if len(code) == 12 {
+		code = "0" + code
+	}
+	if len(code) != 13 {
+		return "", false
+	}
+
+	sum := 0
+	for i, r := range code {
+		if r < '0' || r > '9' {
+			return "", false
+		}
+		digit := int(r - '0')
+		if i == 12 {
+			if (10-sum%10)%10 != digit {
+				return "", false
+			}
+			break
+		}
+		// the first digit counts once, the second three times and so on
+		if i%2 == 1 {
+			digit *= 3
+		}
+		sum += digit
+	}
+	return code, true
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeBarcode(t *testing.T) {
+	t.Parallel()
+
+	code, ok := NormalizeBarcode("4006381333931")
+	assert.True(t, ok)
+	assert.Equal(t, "4006381333931", code)
+
+	// UPC-A gets the leading zero of its EAN-13 form
+	code, ok = NormalizeBarcode("036000291452")
+	assert.True(t, ok)
+	assert.Equal(t, "0036000291452", code)
+
+	for _, invalid := range []string{"4006381333932", "036000291453", "400638133393", "40063813339310", "40063813339a1", ""} {
+		_, ok := NormalizeBarcode(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "context"
+
+type Customer struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Email     string `json:"email"`
+	Phone     string `json:"phone"`
+	Address   string `json:"address"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// zero values match everything
+type CustomerFilter struct {
+	Name string // case insensitive, matches anywhere in the name
+}
+
+// CustomerStore persists the customers sales orders are placed for
+type CustomerStore interface {
+	// CreateCustomer inserts the customer and sets its ID
+	CreateCustomer(ctx context.Context, customer *Customer) error
+	GetCustomer(ctx context.Context, id int64) (Customer, error)
+	ListCustomers(ctx context.Context, filter CustomerFilter) ([]Customer, error)
+	UpdateCustomerEmail(ctx context.Context, id int64, email string) error
+	UpdateCustomerPhone(ctx context.Context, id int64, phone string) error
+	UpdateCustomerAddress(ctx context.Context, id int64, address string) error
+	// DeleteCustomer returns ErrInUse while sales orders point at the customer
+	DeleteCustomer(ctx context.Context, id int64) error
+}
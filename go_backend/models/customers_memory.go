@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// caller holds the lock
+func (m *MemoryStore) checkCustomer(customer Customer) error {
+	for _, existing := range m.customers {
+		// empty emails are stored as NULL, which never collide
+		if existing.ID != customer.ID && customer.Email != "" && existing.Email == customer.Email {
+			return fmt.Errorf("%w: Key (email)=(%s) already exists.", ErrDuplicate, customer.Email)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateCustomer(ctx context.Context, customer *Customer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkCustomer(*customer); err != nil {
+		return err
+	}
+
+	m.lastCustomerID++
+	customer.ID = m.lastCustomerID
+	customer.CreatedAt = memoryNow()
+	customer.UpdatedAt = customer.CreatedAt
+	m.customers[customer.ID] = *customer
+	return nil
+}
+
+func (m *MemoryStore) GetCustomer(ctx context.Context, id int64) (Customer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	customer, ok := m.customers[id]
+	if !ok {
+		return Customer{}, ErrNotFound
+	}
+	return customer, nil
+}
+
+func (m *MemoryStore) ListCustomers(ctx context.Context, filter CustomerFilter) ([]Customer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	customers := []Customer{}
+	for _, customer := range sortedByID(m.customers) {
+		if filter.Name != "" && !strings.Contains(strings.ToLower(customer.Name), strings.ToLower(filter.Name)) {
+			continue
+		}
+		customers = append(customers, customer)
+	}
+	return customers, nil
+}
+
+// apply change to the customer under the write lock, re-checking uniqueness
+func (m *MemoryStore) updateCustomer(id int64, change func(customer *Customer)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	customer, ok := m.customers[id]
+	if !ok {
+		return ErrNotFound
+	}
+	change(&customer)
+	if err := m.checkCustomer(customer); err != nil {
+		return err
+	}
+	customer.UpdatedAt = memoryNow()
+	m.customers[id] = customer
+	return nil
+}
+
+func (m *MemoryStore) UpdateCustomerEmail(ctx context.Context, id int64, email string) error {
+	return m.updateCustomer(id, func(customer *Customer) { customer.Email = email })
+}
+
+func (m *MemoryStore) UpdateCustomerPhone(ctx context.Context, id int64, phone string) error {
+	return m.updateCustomer(id, func(customer *Customer) { customer.Phone = phone })
+}
+
+func (m *MemoryStore) UpdateCustomerAddress(ctx context.Context, id int64, address string) error {
+	return m.updateCustomer(id, func(customer *Customer) { customer.Address = address })
+}
+
+func (m *MemoryStore) DeleteCustomer(ctx context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.customers[id]; !ok {
+		return ErrNotFound
+	}
+	// fk_customer on sales_orders
+	for _, order := range m.salesOrders {
+		if order.CustomerID == id {
+			return fmt.Errorf("%w: Key (id)=(%d) is still referenced from table \"sales_orders\".", ErrInUse, id)
+		}
+	}
+	delete(m.customers, id)
+	return nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// PostgresCustomerStore implements CustomerStore on the customers table
+type PostgresCustomerStore struct {
+	DB *sql.DB
+}
+
+const customerColumns = "id, name, COALESCE(email, ''), COALESCE(phone, ''), COALESCE(address, ''), created_at, updated_at"
+
+func scanCustomer(row scanner) (Customer, error) {
+	var customer Customer
+	err := row.Scan(&customer.ID, &customer.Name, &customer.Email, &customer.Phone, &customer.Address, &customer.CreatedAt, &customer.UpdatedAt)
+	return customer, err
+}
+
+func (s *PostgresCustomerStore) CreateCustomer(ctx context.Context, customer *Customer) error {
+	// empty email stored as NULL so the unique email only applies to real addresses
+	query := "INSERT INTO customers (name, email, phone, address) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, '')) RETURNING " + customerColumns
+	created, err := scanCustomer(s.DB.QueryRowContext(ctx, query, customer.Name, customer.Email, customer.Phone, customer.Address))
+	if err != nil {
+		return mapError(err)
+	}
+	*customer = created
+	return nil
+}
+
+func (s *PostgresCustomerStore) GetCustomer(ctx context.Context, id int64) (Customer, error) {
+	query := "SELECT " + customerColumns + " FROM customers WHERE id = $1"
+	customer, err := scanCustomer(s.DB.QueryRowContext(ctx, query, id))
+	return customer, mapError(err)
+}
+
+// escapes the LIKE wildcards so search terms are matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *PostgresCustomerStore) ListCustomers(ctx context.Context, filter CustomerFilter) ([]Customer, error) {
+	query := "SELECT " + customerColumns + " FROM customers"
+	args := []any{}
+	if filter.Name != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Name)+"%")
+		query += " WHERE name ILIKE $1"
+	}
+	query += " ORDER BY id"
+
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	customers := []Customer{}
+	for rows.Next() {
+		customer, err := scanCustomer(rows)
+		if err != nil {
+			return nil, err
+		}
+		customers = append(customers, customer)
+	}
+	return customers, rows.Err()
+}
+
+// set one of the optional columns, empty values are stored as NULL
+func (s *PostgresCustomerStore) updateCustomerColumn(ctx context.Context, id int64, column string, value string) error {
+	query := "UPDATE customers SET " + column + " = NULLIF($1, ''), updated_at = NOW() WHERE id = $2"
+	result, err := s.DB.ExecContext(ctx, query, value, id)
+	if err != nil {
+		return mapError(err)
+	}
+	return expectOneRow(result)
+}
+
+func (s *PostgresCustomerStore) UpdateCustomerEmail(ctx context.Context, id int64, email string) error {
+	return s.updateCustomerColumn(ctx, id, "email", email)
+}
+
+func (s *PostgresCustomerStore) UpdateCustomerPhone(ctx context.Context, id int64, phone string) error {
+	return s.updateCustomerColumn(ctx, id, "phone", phone)
+}
+
+func (s *PostgresCustomerStore) UpdateCustomerAddress(ctx context.Context, id int64, address string) error {
+	return s.updateCustomerColumn(ctx, id, "address", address)
+}
+
+func (s *PostgresCustomerStore) DeleteCustomer(ctx context.Context, id int64) error {
+	result, err := s.DB.ExecContext(ctx, "DELETE FROM customers WHERE id = $1", id)
+	if err != nil {
+		return mapDeleteError(err)
+	}
+	return expectOneRow(result)
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresUpdateCustomerEmailDuplicate(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE customers SET email = NULLIF\\(\\$1, ''\\), updated_at = NOW\\(\\) WHERE id = \\$2").WithArgs("taken@example.com", 2).
+		WillReturnError(&pq.Error{Code: "23505", Detail: "Key (email)=(taken@example.com) already exists."})
+
+	store := &PostgresCustomerStore{DB: db}
+	err = store.UpdateCustomerEmail(context.Background(), 2, "taken@example.com")
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresDeleteCustomerMissing(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("DELETE FROM customers WHERE id = \\$1").WithArgs(9).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	store := &PostgresCustomerStore{DB: db}
+	assert.ErrorIs(t, store.DeleteCustomer(context.Background(), 9), ErrNotFound)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresDeleteCustomerWithOrders(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// sales orders still point at the customer
+	mock.ExpectExec("DELETE FROM customers WHERE id = \\$1").WithArgs(1).
+		WillReturnError(&pq.Error{Code: "23503", Detail: "Key (id)=(1) is still referenced from table \"sales_orders\"."})
+
+	store := &PostgresCustomerStore{DB: db}
+	assert.ErrorIs(t, store.DeleteCustomer(context.Background(), 1), ErrInUse)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -3,99 +3,108 @@ package models
 import (
 	"context"
 	"database/sql"
-	"log"
+	"fmt"
 	"os"
-	"os/signal"
+	"strconv"
 	"time"
 
 	_ "github.com/lib/pq"
 	//avoid import error with generic database/sql
 )
 
-var pool *sql.DB
+// pool settings, each can be overridden from the environment
+type PoolConfig struct {
+	MaxOpenConns    int           // DB_MAX_OPEN_CONNS
+	MaxIdleConns    int           // DB_MAX_IDLE_CONNS
+	ConnMaxLifetime time.Duration // DB_CONN_MAX_LIFETIME, e.g. "30m"
+	ConnMaxIdleTime time.Duration // DB_CONN_MAX_IDLE_TIME, e.g. "5m"
+}
 
-func ConnectToDB() (ctx context.Context) {
-	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
-	if err != nil {
-		panic(err)
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns:    10,
+		MaxIdleConns:    5,
+		ConnMaxLifetime: 30 * time.Minute,
+		ConnMaxIdleTime: 5 * time.Minute,
 	}
-
-	defer pool.Close()
-
-	pool.SetConnMaxLifetime(0)
-	pool.SetMaxIdleConns(3)
-	pool.SetMaxOpenConns(3)
-
-	ctx, stop := context.WithCancel(context.Background())
-	defer stop()
-
-	appSignal := make(chan os.Signal, 3)
-	signal.Notify(appSignal, os.Interrupt)
-
-	go func() {
-		<-appSignal
-		stop()
-	}()
-
-	PingDatabase(ctx)
-
-	return ctx
 }
 
-// verify that database credentials are valid
-func PingDatabase(ctx context.Context) {
-	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
-	defer cancel()
+// read pool settings from env, falling back to the defaults
+func PoolConfigFromEnv() (PoolConfig, error) {
+	config := DefaultPoolConfig()
 
-	err := pool.PingContext(ctx)
-	if err != nil {
-		log.Fatalf("Unable to connect to database %v", err)
+	if err := envInt("DB_MAX_OPEN_CONNS", &config.MaxOpenConns); err != nil {
+		return config, err
+	}
+	if err := envInt("DB_MAX_IDLE_CONNS", &config.MaxIdleConns); err != nil {
+		return config, err
+	}
+	if err := envDuration("DB_CONN_MAX_LIFETIME", &config.ConnMaxLifetime); err != nil {
+		return config, err
+	}
+	if err := envDuration("DB_CONN_MAX_IDLE_TIME", &config.ConnMaxIdleTime); err != nil {
+		return config, err
 	}
 
+	return config, nil
 }
 
-// func ConnectToDB() {
-// 	var err error
-// 	pool, err = sql.Open("postgres", os.Getenv("DATABASE_URL"))
-
-// 	if err != nil {
-// 		log.Fatal("Unable to retrieve database credentials.")
-// 	}
-// 	fmt.Println("Retrieving Database Credentials...")
-// 	defer pool.Close()
-
-// 	pool.SetConnMaxLifetime(0) //how many times a connection can be used
-// 	pool.SetMaxIdleConns(3)    //max number of connections in idle pool
-// 	pool.SetMaxOpenConns(3)    // max number of open connections in a database
+func envInt(key string, target *int) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%s must be a non-negative integer, got %q", key, value)
+	}
+	*target = n
+	return nil
+}
 
-// 	ctx, stop := context.WithCancel(context.Background())
-// 	defer stop()
+func envDuration(key string, target *time.Duration) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return fmt.Errorf("%s must be a non-negative duration like 30m, got %q", key, value)
+	}
+	*target = d
+	return nil
+}
 
-// 	appSignal := make(chan os.Signal, 3)
-// 	signal.Notify(appSignal, os.Interrupt)
+// ConnectToDB opens the application wide pool and checks the database is reachable.
+// The caller owns the pool and should Close it on shutdown.
+func ConnectToDB() (*sql.DB, error) {
+	config, err := PoolConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
 
-// 	go func() {
-// 		<-appSignal
-// 		stop()
-// 	}()
+	pool, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		return nil, err
+	}
 
-// 	//ping database
+	pool.SetMaxOpenConns(config.MaxOpenConns)
+	pool.SetMaxIdleConns(config.MaxIdleConns)
+	pool.SetConnMaxLifetime(config.ConnMaxLifetime)
+	pool.SetConnMaxIdleTime(config.ConnMaxIdleTime)
 
-// 	PingDatabase(ctx)
-// 	fmt.Println("Connecting to Database...")
-// 	fmt.Println("Connected to Database!")
+	if err := PingDatabase(context.Background(), pool); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("unable to connect to database: %w", err)
+	}
 
-// }
+	return pool, nil
+}
 
-// // If the query fails exit the program with an error.
-// func Query(ctx context.Context, id int64) {
-// 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-// 	defer cancel()
+// verify that database credentials are valid
+func PingDatabase(ctx context.Context, pool *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
 
-// 	var name string
-// 	err := pool.QueryRowContext(ctx, "select p.name from people as p where p.id = :id;", sql.Named("id", id)).Scan(&name)
-// 	if err != nil {
-// 		log.Fatal("unable to execute search query", err)
-// 	}
-// 	log.Println("name=", name)
-// }
+	return pool.PingContext(ctx)
+}
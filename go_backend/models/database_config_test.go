@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolConfigFromEnv(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "20")
+	t.Setenv("DB_MAX_IDLE_CONNS", "")
+	t.Setenv("DB_CONN_MAX_LIFETIME", "1h")
+	t.Setenv("DB_CONN_MAX_IDLE_TIME", "")
+
+	config, err := PoolConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, 20, config.MaxOpenConns)
+	assert.Equal(t, DefaultPoolConfig().MaxIdleConns, config.MaxIdleConns)
+	assert.Equal(t, time.Hour, config.ConnMaxLifetime)
+	assert.Equal(t, DefaultPoolConfig().ConnMaxIdleTime, config.ConnMaxIdleTime)
+}
+
+func TestPoolConfigFromEnvInvalid(t *testing.T) {
+	t.Setenv("DB_MAX_OPEN_CONNS", "lots")
+
+	_, err := PoolConfigFromEnv()
+	assert.Error(t, err)
+}
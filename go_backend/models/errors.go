@@ -0,0 +1,77 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// errors returned by every store implementation, handlers map them to status codes
+var (
+	ErrNotFound          = errors.New("record not found")
+	ErrDuplicate         = errors.New("record already exists")
+	ErrInvalidReference  = errors.New("referenced record does not exist")
+	ErrInsufficientStock = errors.New("not enough stock")
+	ErrInvalidState      = errors.New("not allowed in the current state")
+	ErrVersionMismatch   = errors.New("record was changed since it was read")
+	ErrInUse             = errors.New("record is still in use")
+	ErrInvalidValue      = errors.New("value does not fit the column")
+
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+// postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqCheckViolation      = "23514"
+	pqStringTooLong       = "22001"
+	pqNumericOutOfRange   = "22003"
+)
+
+// translate driver errors into the store errors above
+func mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case pqUniqueViolation:
+			return fmt.Errorf("%w: %s", ErrDuplicate, pqErr.Detail)
+		case pqForeignKeyViolation:
+			return fmt.Errorf("%w: %s", ErrInvalidReference, pqErr.Detail)
+		case pqCheckViolation, pqStringTooLong, pqNumericOutOfRange:
+			return fmt.Errorf("%w: %s", ErrInvalidValue, pqErr.Message)
+		}
+	}
+	return err
+}
+
+// mapError for a DELETE, where a foreign key violation means other rows still refer to the deleted one
+func mapDeleteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqForeignKeyViolation {
+		return fmt.Errorf("%w: %s", ErrInUse, pqErr.Detail)
+	}
+	return mapError(err)
+}
+
+// exactly one row must have been touched, otherwise ErrNotFound
+func expectOneRow(result sql.Result) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != 1 {
+		return ErrNotFound
+	}
+	return nil
+}
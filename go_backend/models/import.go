@@ -0,0 +1,118 @@
+package models
+
+import "errors"
+
+// ProductImport is one row of a product import. The product is matched by SKU when the row has one,
+// then by name, and patched; without a match a new product is created from the row.
+// A row with a known name and a new SKU so changes the SKU of that product.
+type ProductImport struct {
+	Row   int          // line of the file, reported with errors
+	Patch ProductPatch // Name is always set
+	Stock int          // opening stock of a new product, existing stock only changes through the ledger
+}
+
+// SupplierImport is one row of a supplier import, matched by name
+type SupplierImport struct {
+	Row   int
+	Patch SupplierPatch // Name is always set
+}
+
+// ImportError is why one row of an import cannot be written
+type ImportError struct {
+	Row     int    `json:"row"`
+	Message string `json:"message"`
+}
+
+// ImportResult says what an import wrote, or would have written on a dry run.
+// Nothing is written unless every row succeeds.
+type ImportResult struct {
+	Created   int           `json:"created"`
+	Updated   int           `json:"updated"`
+	Errors    []ImportError `json:"errors"`
+	DryRun    bool          `json:"dry_run"`
+	Committed bool          `json:"committed"`
+}
+
+func newImportResult(dryRun bool) ImportResult {
+	return ImportResult{Errors: []ImportError{}, DryRun: dryRun}
+}
+
+// count a row that went through, or keep its error when the row itself is at fault.
+// Other errors end the import and are returned.
+func (r *ImportResult) add(row int, created bool, err error) error {
+	switch {
+	case err == nil && created:
+		r.Created++
+	case err == nil:
+		r.Updated++
+	case errors.Is(err, ErrDuplicate), errors.Is(err, ErrInvalidReference), errors.Is(err, ErrNotFound), errors.Is(err, ErrInvalidState),
+		errors.Is(err, ErrInvalidValue):
+		r.Errors = append(r.Errors, ImportError{Row: row, Message: err.Error()})
+	default:
+		return err
+	}
+	return nil
+}
+
+// whether the rows should be written
+func (r *ImportResult) commit() bool {
+	r.Committed = !r.DryRun && len(r.Errors) == 0
+	return r.Committed
+}
+
+// a column and value a product row is matched by
+type importKey struct {
+	column string
+	value  string
+}
+
+// the keys a product row is matched by, tried in order
+func (row ProductImport) keys() []importKey {
+	keys := []importKey{}
+	if row.Patch.SKU != nil && *row.Patch.SKU != "" {
+		keys = append(keys, importKey{"sku", *row.Patch.SKU})
+	}
+	return append(keys, importKey{"name", *row.Patch.Name})
+}
+
+// the product a row creates when nothing matches
+func (row ProductImport) product() Product {
+	product := Product{Name: *row.Patch.Name, Stock: row.Stock}
+	if row.Patch.SKU != nil {
+		product.SKU = *row.Patch.SKU
+	}
+	if row.Patch.Barcode != nil {
+		product.Barcode = *row.Patch.Barcode
+	}
+	if row.Patch.Description != nil {
+		product.Description = *row.Patch.Description
+	}
+	if row.Patch.SupplierID != nil {
+		product.SupplierID = *row.Patch.SupplierID
+	}
+	if row.Patch.Price != nil {
+		product.Price = *row.Patch.Price
+	}
+	if row.Patch.Cost != nil {
+		product.Cost = *row.Patch.Cost
+	}
+	if row.Patch.MinimumStock != nil {
+		product.MinimumStock = *row.Patch.MinimumStock
+	}
+	return product
+}
+
+// the supplier a row creates when nothing matches
+func (row SupplierImport) supplier() Supplier {
+	supplier := Supplier{Name: *row.Patch.Name, LeadTimeDays: DefaultLeadTimeDays}
+	if row.Patch.ContactEmail != nil {
+		supplier.ContactEmail = *row.Patch.ContactEmail
+	}
+	if row.Patch.Phone != nil {
+		supplier.Phone = *row.Patch.Phone
+	}
+	if row.Patch.LeadTimeDays != nil {
+		supplier.LeadTimeDays = *row.Patch.LeadTimeDays
+	}
+	return supplier
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"maps"
+)
+
+// what an import can change, so it can be undone like a rolled back transaction
+type memorySnapshot struct {
+	products     map[int64]Product
+	suppliers    map[int64]Supplier
+	movements    int
+	priceHistory int
+	costHistory  int
+
+	lastProductID     int64
+	lastSupplierID    int64
+	lastMovementID    int64
+	lastPriceChangeID int64
+}
+
+// caller holds the write lock
+func (m *MemoryStore) snapshotLocked() memorySnapshot {
+	return memorySnapshot{
+		products:          maps.Clone(m.products),
+		suppliers:         maps.Clone(m.suppliers),
+		movements:         len(m.movements),
+		priceHistory:      len(m.priceHistory),
+		costHistory:       len(m.costHistory),
+		lastProductID:     m.lastProductID,
+		lastSupplierID:    m.lastSupplierID,
+		lastMovementID:    m.lastMovementID,
+		lastPriceChangeID: m.lastPriceChangeID,
+	}
+}
+
+// caller holds the write lock
+func (m *MemoryStore) restoreLocked(snapshot memorySnapshot) {
+	m.products = snapshot.products
+	m.suppliers = snapshot.suppliers
+	m.movements = m.movements[:snapshot.movements]
+	m.priceHistory = m.priceHistory[:snapshot.priceHistory]
+	m.costHistory = m.costHistory[:snapshot.costHistory]
+	m.lastProductID = snapshot.lastProductID
+	m.lastSupplierID = snapshot.lastSupplierID
+	m.lastMovementID = snapshot.lastMovementID
+	m.lastPriceChangeID = snapshot.lastPriceChangeID
+}
+
+func (m *MemoryStore) ImportProducts(ctx context.Context, rows []ProductImport, dryRun bool, userID int64) (ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := newImportResult(dryRun)
+	snapshot := m.snapshotLocked()
+	for _, row := range rows {
+		// a failed row writes nothing, like a rolled back savepoint
+		created, err := m.importProductLocked(row, userID)
+		if err := result.add(row.Row, created, err); err != nil {
+			m.restoreLocked(snapshot)
+			return result, err
+		}
+	}
+	if !result.commit() {
+		m.restoreLocked(snapshot)
+	}
+	return result, nil
+}
+
+// caller holds the write lock, reports whether the product was created
+func (m *MemoryStore) importProductLocked(row ProductImport, userID int64) (bool, error) {
+	for _, key := range row.keys() {
+		for _, product := range sortedByID(m.products) {
+			if (key.column != "sku" || product.SKU != key.value) && (key.column != "name" || product.Name != key.value) {
+				continue
+			}
+			// deleted products keep their name and SKU, the row cannot create another
+			if product.DeletedAt != "" {
+				return false, fmt.Errorf("%w: product %d is deleted, restore it first", ErrInvalidState, product.ID)
+			}
+			return false, m.updateProductLocked(product.ID, AnyVersion, m.patchProductChange(row.Patch, userID))
+		}
+	}
+	product := row.product()
+	return true, m.createProductLocked(&product)
+}
+
+func (m *MemoryStore) ImportSuppliers(ctx context.Context, rows []SupplierImport, dryRun bool) (ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := newImportResult(dryRun)
+	snapshot := m.snapshotLocked()
+	for _, row := range rows {
+		created, err := m.importSupplierLocked(row)
+		if err := result.add(row.Row, created, err); err != nil {
+			m.restoreLocked(snapshot)
+			return result, err
+		}
+	}
+	if !result.commit() {
+		m.restoreLocked(snapshot)
+	}
+	return result, nil
+}
+
+// caller holds the write lock, reports whether the supplier was created
+func (m *MemoryStore) importSupplierLocked(row SupplierImport) (bool, error) {
+	for _, supplier := range sortedByID(m.suppliers) {
+		if supplier.Name != *row.Patch.Name {
+			continue
+		}
+		if supplier.DeletedAt != "" {
+			return false, fmt.Errorf("%w: supplier %d is deleted, restore it first", ErrInvalidState, supplier.ID)
+		}
+		return false, m.updateSupplierLocked(supplier.ID, AnyVersion, patchSupplierChange(row.Patch))
+	}
+	supplier := row.supplier()
+	return true, m.createSupplierLocked(&supplier)
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps everything in process memory, for demos and tests.
+// It implements every store interface and enforces the same constraints
+// as the migrations (unique names/emails, supplier foreign key).
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	products      map[int64]Product
+	suppliers     map[int64]Supplier
+	users         map[int64]User
+	refreshTokens map[string]*memoryRefreshToken // by token hash
+	movements     []StockMovement                // append only, oldest first
+
+	purchaseOrders map[int64]PurchaseOrder // with lines and receipts
+
+	customers   map[int64]Customer
+	salesOrders map[int64]SalesOrder // with lines
+	reserved    map[int64]int        // by product id, products.reserved in postgres
+
+	priceHistory    []PriceChange // append only, oldest first
+	costHistory     []costChange  // product_costs in postgres, oldest first
+	scheduledPrices map[int64]ScheduledPrice
+
+	lastProductID  int64
+	lastSupplierID int64
+	lastUserID     int64
+	lastMovementID int64
+
+	lastPurchaseOrderID     int64
+	lastPurchaseOrderLineID int64
+	lastReceiptID           int64
+
+	lastCustomerID       int64
+	lastSalesOrderID     int64
+	lastSalesOrderLineID int64
+
+	lastPriceChangeID    int64
+	lastScheduledPriceID int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		products:      map[int64]Product{},
+		suppliers:     map[int64]Supplier{},
+		users:         map[int64]User{},
+		refreshTokens: map[string]*memoryRefreshToken{},
+
+		purchaseOrders: map[int64]PurchaseOrder{},
+
+		customers:   map[int64]Customer{},
+		salesOrders: map[int64]SalesOrder{},
+		reserved:    map[int64]int{},
+
+		scheduledPrices: map[int64]ScheduledPrice{},
+	}
+}
+
+// NewMemoryStores backs every store with one shared MemoryStore
+func NewMemoryStores() Stores {
+	store := NewMemoryStore()
+	return Stores{
+		Products:       store,
+		Suppliers:      store,
+		Users:          store,
+		Stocks:         store,
+		PurchaseOrders: store,
+		Customers:      store,
+		SalesOrders:    store,
+		Analytics:      store,
+		Prices:         store,
+		Health:         store,
+	}
+}
+
+// memory is always reachable
+func (m *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// timestamp in the same format postgres timestamps scan into
+func memoryNow() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
+
+// map values sorted by id, like ORDER BY id
+func sortedByID[T any](items map[int64]T) []T {
+	ids := make([]int64, 0, len(items))
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	sorted := make([]T, 0, len(ids))
+	for _, id := range ids {
+		sorted = append(sorted, items[id])
+	}
+	return sorted
+}
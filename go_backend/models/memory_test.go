@@ -0,0 +1,1074 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryProductConstraints(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// supplier_id must reference an existing supplier
+	err := store.CreateProduct(ctx, &Product{Name: "testproduct", SupplierID: 1, Price: decimal.NewFromInt(5)})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Price: decimal.NewFromInt(5)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+	assert.EqualValues(t, 1, product.ID)
+
+	// product names are unique
+	err = store.CreateProduct(ctx, &Product{Name: "testproduct", SupplierID: supplier.ID})
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	// a referenced supplier cannot be deleted
+	assert.ErrorIs(t, store.DeleteSupplier(ctx, supplier.ID, AnyVersion, SupplierDeletion{}), ErrInUse)
+
+	_, err = store.SetStock(ctx, product.ID, 10, StockChange{Reason: ReasonAdjustment})
+	assert.NoError(t, err)
+	got, err := store.GetProduct(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, got.Stock)
+
+	assert.NoError(t, store.DeleteProduct(ctx, product.ID, AnyVersion))
+	assert.ErrorIs(t, store.DeleteProduct(ctx, product.ID, AnyVersion), ErrNotFound)
+	assert.NoError(t, store.DeleteSupplier(ctx, supplier.ID, AnyVersion, SupplierDeletion{}))
+}
+
+func TestMemorySupplierConstraints(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	first := Supplier{Name: "first", ContactEmail: "first@example.com"}
+	assert.NoError(t, store.CreateSupplier(ctx, &first))
+
+	// name and contact_email are unique, empty emails never collide
+	assert.ErrorIs(t, store.CreateSupplier(ctx, &Supplier{Name: "first"}), ErrDuplicate)
+	assert.ErrorIs(t, store.CreateSupplier(ctx, &Supplier{Name: "second", ContactEmail: "first@example.com"}), ErrDuplicate)
+	assert.NoError(t, store.CreateSupplier(ctx, &Supplier{Name: "second"}))
+	assert.NoError(t, store.CreateSupplier(ctx, &Supplier{Name: "third"}))
+
+	// updates are checked too
+	assert.ErrorIs(t, store.UpdateSupplierEmail(ctx, 2, AnyVersion, "first@example.com"), ErrDuplicate)
+	assert.ErrorIs(t, store.UpdateSupplierPhone(ctx, 99, AnyVersion, "0123456789"), ErrNotFound)
+
+	suppliers, total, err := store.ListSuppliers(ctx, SupplierFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, suppliers, 3)
+	assert.Equal(t, 3, total)
+	assert.EqualValues(t, 1, suppliers[0].ID)
+
+	// the second page of suppliers with an "i", newest first
+	suppliers, total, err = store.ListSuppliers(ctx, SupplierFilter{Name: "I", Sort: Sort{Key: "name", Descending: true}, Page: Page{Limit: 1, Offset: 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, suppliers, 1)
+	assert.Equal(t, "first", suppliers[0].Name)
+}
+
+func TestMemoryPatchProduct(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	first := Supplier{Name: "first"}
+	assert.NoError(t, store.CreateSupplier(ctx, &first))
+	second := Supplier{Name: "second"}
+	assert.NoError(t, store.CreateSupplier(ctx, &second))
+	product := Product{Name: "testproduct", Description: "old", SupplierID: first.ID, Price: decimal.NewFromInt(5), Stock: 3}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+	assert.NoError(t, store.CreateProduct(ctx, &Product{Name: "otherproduct", SupplierID: first.ID}))
+
+	description, minimum := "", 4
+	patched, err := store.PatchProduct(ctx, product.ID, AnyVersion, ProductPatch{Description: &description, SupplierID: &second.ID, MinimumStock: &minimum}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "testproduct", patched.Name)
+	assert.Equal(t, "", patched.Description)
+	assert.Equal(t, second.ID, patched.SupplierID)
+	assert.Equal(t, 4, patched.MinimumStock)
+	assert.Equal(t, 3, patched.Stock)
+
+	// a failed patch changes nothing
+	name, missing := "otherproduct", int64(99)
+	_, err = store.PatchProduct(ctx, product.ID, AnyVersion, ProductPatch{Name: &name}, 0)
+	assert.ErrorIs(t, err, ErrDuplicate)
+	_, err = store.PatchProduct(ctx, product.ID, AnyVersion, ProductPatch{SupplierID: &missing, MinimumStock: &minimum}, 0)
+	assert.ErrorIs(t, err, ErrInvalidReference)
+	got, err := store.GetProduct(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, second.ID, got.SupplierID)
+
+	email := "second@example.com"
+	supplier, err := store.PatchSupplier(ctx, second.ID, AnyVersion, SupplierPatch{ContactEmail: &email})
+	assert.NoError(t, err)
+	assert.Equal(t, email, supplier.ContactEmail)
+	_, err = store.PatchSupplier(ctx, first.ID, AnyVersion, SupplierPatch{ContactEmail: &email})
+	assert.ErrorIs(t, err, ErrDuplicate)
+}
+
+func TestMemoryVersions(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	assert.EqualValues(t, 1, supplier.Version)
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Price: decimal.NewFromInt(10), Stock: 5}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	// every change bumps the version, also ones without a version check
+	assert.NoError(t, store.UpdateProductCost(ctx, product.ID, 1, decimal.NewFromInt(4)))
+	_, err := store.AdjustStock(ctx, product.ID, -1, StockChange{Reason: ReasonSale})
+	assert.NoError(t, err)
+	got, _ := store.GetProduct(ctx, product.ID)
+	assert.EqualValues(t, 3, got.Version)
+
+	assert.ErrorIs(t, store.UpdateProductPrice(ctx, product.ID, 2, decimal.NewFromInt(12), 0), ErrVersionMismatch)
+	assert.ErrorIs(t, store.DeleteProduct(ctx, product.ID, 2), ErrVersionMismatch)
+	patched, err := store.PatchProduct(ctx, product.ID, 3, ProductPatch{Price: &got.Price}, 0)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, patched.Version)
+
+	assert.ErrorIs(t, store.UpdateSupplierPhone(ctx, supplier.ID, 2, "0123456789"), ErrVersionMismatch)
+	assert.NoError(t, store.UpdateSupplierPhone(ctx, supplier.ID, 1, "0123456789"))
+	assert.ErrorIs(t, store.UpdateSupplierPhone(ctx, 99, 1, "0123456789"), ErrNotFound)
+}
+
+func TestMemorySoftDelete(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Price: decimal.NewFromInt(5)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	// only a deleted product can be restored or purged
+	_, err := store.RestoreProduct(ctx, product.ID)
+	assert.ErrorIs(t, err, ErrInvalidState)
+	assert.ErrorIs(t, store.PurgeProduct(ctx, product.ID, AnyVersion), ErrInvalidState)
+
+	// a deleted product keeps its name and can still be read, but not changed
+	assert.NoError(t, store.DeleteProduct(ctx, product.ID, 1))
+	got, err := store.GetProduct(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, got.DeletedAt)
+	assert.EqualValues(t, 2, got.Version)
+	assert.ErrorIs(t, store.CreateProduct(ctx, &Product{Name: "testproduct", SupplierID: supplier.ID}), ErrDuplicate)
+	assert.ErrorIs(t, store.UpdateProductCost(ctx, product.ID, AnyVersion, decimal.NewFromInt(1)), ErrNotFound)
+
+	// listings and stock levels skip it unless asked
+	products, _, err := store.ListProducts(ctx, ProductFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, products)
+	products, _, err = store.ListProducts(ctx, ProductFilter{IncludeDeleted: true})
+	assert.NoError(t, err)
+	assert.Len(t, products, 1)
+	levels, err := store.ListStockLevels(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, levels)
+
+	// nor can its stock be read or changed
+	_, err = store.GetStockLevel(ctx, product.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.SetStock(ctx, product.ID, 3, StockChange{Reason: ReasonAdjustment})
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = store.AdjustStock(ctx, product.ID, 1, StockChange{Reason: ReasonAdjustment})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// the supplier goes once its products are gone, and comes back first
+	assert.NoError(t, store.DeleteSupplier(ctx, supplier.ID, AnyVersion, SupplierDeletion{}))
+	assert.ErrorIs(t, store.CreateProduct(ctx, &Product{Name: "other", SupplierID: supplier.ID}), ErrInvalidReference)
+	_, err = store.RestoreProduct(ctx, product.ID)
+	assert.ErrorIs(t, err, ErrInvalidReference)
+	_, err = store.RestoreSupplier(ctx, supplier.ID)
+	assert.NoError(t, err)
+	got, err = store.RestoreProduct(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, got.DeletedAt)
+	assert.EqualValues(t, 3, got.Version)
+
+	// purging needs the version of the deleted row
+	assert.NoError(t, store.DeleteProduct(ctx, product.ID, AnyVersion))
+	assert.ErrorIs(t, store.PurgeProduct(ctx, product.ID, 3), ErrVersionMismatch)
+	assert.NoError(t, store.PurgeProduct(ctx, product.ID, 4))
+	_, err = store.GetProduct(ctx, product.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryDeleteSupplierWithProducts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	first, second := Supplier{Name: "first"}, Supplier{Name: "second"}
+	assert.NoError(t, store.CreateSupplier(ctx, &first))
+	assert.NoError(t, store.CreateSupplier(ctx, &second))
+	kept := Product{Name: "kept", SupplierID: first.ID}
+	assert.NoError(t, store.CreateProduct(ctx, &kept))
+	gone := Product{Name: "gone", SupplierID: first.ID}
+	assert.NoError(t, store.CreateProduct(ctx, &gone))
+	assert.NoError(t, store.DeleteProduct(ctx, gone.ID, AnyVersion))
+
+	// a missing or the same supplier cannot take the products, and nothing moves
+	assert.ErrorIs(t, store.DeleteSupplier(ctx, first.ID, AnyVersion, SupplierDeletion{ReassignTo: 9}), ErrInvalidReference)
+	assert.ErrorIs(t, store.DeleteSupplier(ctx, first.ID, AnyVersion, SupplierDeletion{ReassignTo: first.ID}), ErrInvalidReference)
+	got, err := store.GetProduct(ctx, kept.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, got.SupplierID)
+
+	// reassigning moves deleted products too
+	assert.NoError(t, store.DeleteSupplier(ctx, first.ID, AnyVersion, SupplierDeletion{ReassignTo: second.ID}))
+	for _, id := range []int64{kept.ID, gone.ID} {
+		got, err = store.GetProduct(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, second.ID, got.SupplierID)
+	}
+
+	// goods still due keep the products from being cascaded
+	order := PurchaseOrder{SupplierID: second.ID, Lines: []PurchaseOrderLine{{ProductID: kept.ID, QuantityOrdered: 2}}}
+	assert.NoError(t, store.CreatePurchaseOrder(ctx, &order))
+	assert.ErrorIs(t, store.DeleteSupplier(ctx, second.ID, AnyVersion, SupplierDeletion{Cascade: true}), ErrInUse)
+	_, err = store.CancelPurchaseOrder(ctx, order.ID)
+	assert.NoError(t, err)
+
+	// cascading deletes the products that are left
+	assert.NoError(t, store.DeleteSupplier(ctx, second.ID, AnyVersion, SupplierDeletion{Cascade: true}))
+	got, err = store.GetProduct(ctx, kept.ID)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, got.DeletedAt)
+	assert.EqualValues(t, 3, got.Version)
+
+	// restoring the supplier brings back only the products deleted with it
+	_, err = store.RestoreSupplier(ctx, second.ID)
+	assert.NoError(t, err)
+	got, err = store.GetProduct(ctx, kept.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, got.DeletedAt)
+	got, err = store.GetProduct(ctx, gone.ID)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, got.DeletedAt)
+}
+
+func TestMemoryProductCodes(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	first := Product{Name: "first", SupplierID: supplier.ID, SKU: "SKU-1", Barcode: "4006381333931"}
+	assert.NoError(t, store.CreateProduct(ctx, &first))
+	second := Product{Name: "second", SupplierID: supplier.ID}
+	assert.NoError(t, store.CreateProduct(ctx, &second))
+
+	found, err := store.GetProductByBarcode(ctx, "4006381333931")
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, found.ID)
+	_, err = store.GetProductByBarcode(ctx, "0036000291452")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// codes are unique, but many products may have none
+	assert.ErrorIs(t, store.UpdateProductSKU(ctx, second.ID, AnyVersion, "SKU-1"), ErrDuplicate)
+	assert.ErrorIs(t, store.UpdateProductBarcode(ctx, second.ID, AnyVersion, "4006381333931"), ErrDuplicate)
+	assert.NoError(t, store.CreateProduct(ctx, &Product{Name: "third", SupplierID: supplier.ID}))
+	assert.NoError(t, store.UpdateProductBarcode(ctx, first.ID, AnyVersion, ""))
+	assert.NoError(t, store.UpdateProductBarcode(ctx, second.ID, AnyVersion, "4006381333931"))
+}
+
+func TestMemorySearchProducts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	for _, product := range []Product{
+		{Name: "Red Hammer", SupplierID: supplier.ID, Description: "claw hammer, steel"},
+		{Name: "Screwdriver set", SupplierID: supplier.ID, Description: "for red screws"},
+		{Name: "Blue paint", SupplierID: supplier.ID},
+	} {
+		assert.NoError(t, store.CreateProduct(ctx, &product))
+	}
+
+	// a name match ranks above a description match
+	matches, err := store.SearchProducts(ctx, "red", 10)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, "Red Hammer", matches[0].Name)
+	assert.Greater(t, matches[0].Rank, matches[1].Rank)
+
+	// prefixes of every word, and typos
+	matches, err = store.SearchProducts(ctx, "scre SET", 10)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	matches, err = store.SearchProducts(ctx, "hamer", 10)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+
+	matches, err = store.SearchProducts(ctx, "red", 1)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	matches, err = store.SearchProducts(ctx, "green", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestMemoryListProducts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	first := Supplier{Name: "first"}
+	assert.NoError(t, store.CreateSupplier(ctx, &first))
+	second := Supplier{Name: "second"}
+	assert.NoError(t, store.CreateSupplier(ctx, &second))
+	for _, product := range []Product{
+		{Name: "a", SupplierID: first.ID, Price: decimal.NewFromInt(5), Stock: 1, MinimumStock: 2},
+		{Name: "b", SupplierID: first.ID, Price: decimal.NewFromInt(5), Stock: 9, MinimumStock: 2},
+		{Name: "c", SupplierID: first.ID, Price: decimal.NewFromInt(20), Stock: 2, MinimumStock: 2},
+		{Name: "d", SupplierID: second.ID, Price: decimal.NewFromInt(1)},
+	} {
+		assert.NoError(t, store.CreateProduct(ctx, &product))
+	}
+
+	filter := ProductFilter{SupplierID: first.ID, MaxPrice: decimal.NewNullDecimal(decimal.NewFromInt(10))}
+	products, total, err := store.ListProducts(ctx, filter)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, "a", products[0].Name)
+
+	// equal prices keep descending id order
+	products, _, err = store.ListProducts(ctx, ProductFilter{Sort: Sort{Key: "price", Descending: true}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "b", "a", "d"}, []string{products[0].Name, products[1].Name, products[2].Name, products[3].Name})
+
+	products, total, err = store.ListProducts(ctx, ProductFilter{BelowMinimum: true, Page: Page{Limit: 1}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, products, 1)
+
+	// past the end is an empty page
+	products, total, err = store.ListProducts(ctx, ProductFilter{Page: Page{Limit: 10, Offset: 10}})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Empty(t, products)
+}
+
+func TestMemoryConcurrentCreates(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// only one of many racing inserts with the same name may win
+	var wg sync.WaitGroup
+	results := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- store.CreateSupplier(ctx, &Supplier{Name: "racer"})
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded++
+		}
+	}
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestMemoryAdjustStockNeverNegative(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 10}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	// 15 clerks each take 1, only 10 can succeed
+	var wg sync.WaitGroup
+	results := make(chan error, 15)
+	for i := 0; i < 15; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.AdjustStock(ctx, product.ID, -1, StockChange{Reason: ReasonSale})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	failed := 0
+	for err := range results {
+		if err != nil {
+			assert.ErrorIs(t, err, ErrInsufficientStock)
+			failed++
+		}
+	}
+	assert.Equal(t, 5, failed)
+
+	level, err := store.GetStockLevel(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, level.Stock)
+
+	// opening balance plus the 10 successful sales, failed ones leave no trace
+	movements, err := store.ListMovements(ctx, product.ID, MovementFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, movements, 11)
+}
+
+func TestMemoryStockLedger(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 4}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	_, err := store.AdjustStock(ctx, product.ID, 6, StockChange{Reason: ReasonReceipt, UserID: 7, Reference: "PO-1"})
+	assert.NoError(t, err)
+	_, err = store.SetStock(ctx, product.ID, 8, StockChange{Reason: ReasonDamage})
+	assert.NoError(t, err)
+	// counting what is already there leaves no movement
+	_, err = store.SetStock(ctx, product.ID, 8, StockChange{Reason: ReasonAdjustment})
+	assert.NoError(t, err)
+
+	movements, err := store.ListMovements(ctx, product.ID, MovementFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, movements, 3)
+
+	// the ledger adds up to the current stock
+	total := 0
+	for _, movement := range movements {
+		total += movement.Delta
+	}
+	assert.Equal(t, 8, total)
+	assert.Equal(t, -2, movements[2].Delta)
+	assert.EqualValues(t, 7, *movements[1].UserID)
+	assert.Nil(t, movements[0].UserID)
+
+	// everything happened before now + 1h
+	movements, err = store.ListMovements(ctx, product.ID, MovementFilter{From: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Empty(t, movements)
+
+	_, err = store.ListMovements(ctx, 99, MovementFilter{})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryListLowStock(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier", Phone: "555-0100"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	assert.NoError(t, store.CreateProduct(ctx, &Product{Name: "plenty", SupplierID: supplier.ID, Stock: 9, MinimumStock: 2}))
+	assert.NoError(t, store.CreateProduct(ctx, &Product{Name: "atminimum", SupplierID: supplier.ID, Stock: 2, MinimumStock: 2}))
+
+	items, err := store.ListLowStock(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "atminimum", items[0].Name)
+	assert.Equal(t, "555-0100", items[0].SupplierPhone)
+}
+
+func TestMemoryRefreshTokenReuse(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	user := User{Email: "test@example.com", Password: "hashedpassword"}
+	assert.NoError(t, store.CreateUser(ctx, &user))
+	assert.Equal(t, RoleReadOnly, user.Role)
+	assert.ErrorIs(t, store.CreateUser(ctx, &User{Email: "test@example.com"}), ErrDuplicate)
+
+	expires := time.Now().Add(time.Hour)
+	assert.NoError(t, store.CreateSession(ctx, user.ID, "session1", "hash1", expires))
+
+	_, sessionID, err := store.RotateRefreshToken(ctx, "hash1", "hash2", expires)
+	assert.NoError(t, err)
+	assert.Equal(t, "session1", sessionID)
+
+	_, err = store.GetSessionUser(ctx, user.ID, "session1")
+	assert.NoError(t, err)
+
+	// presenting the rotated token again kills the session
+	_, _, err = store.RotateRefreshToken(ctx, "hash1", "hash3", expires)
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	_, err = store.GetSessionUser(ctx, user.ID, "session1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// an expired token is not known at all
+	assert.NoError(t, store.CreateSession(ctx, user.ID, "session2", "hash4", time.Now().Add(-time.Minute)))
+	_, _, err = store.RotateRefreshToken(ctx, "hash4", "hash5", expires)
+	assert.ErrorIs(t, err, ErrRefreshTokenInvalid)
+}
+
+func TestMemoryPurchaseOrderReceiving(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	other := Supplier{Name: "othersupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &other))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 1}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+	foreign := Product{Name: "foreignproduct", SupplierID: other.ID}
+	assert.NoError(t, store.CreateProduct(ctx, &foreign))
+
+	// products must come from the order's supplier
+	err := store.CreatePurchaseOrder(ctx, &PurchaseOrder{SupplierID: supplier.ID, Lines: []PurchaseOrderLine{{ProductID: foreign.ID, QuantityOrdered: 1}}})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	order := PurchaseOrder{SupplierID: supplier.ID, Lines: []PurchaseOrderLine{{ProductID: product.ID, QuantityOrdered: 10, UnitCost: decimal.NewFromInt(3)}}}
+	assert.NoError(t, store.CreatePurchaseOrder(ctx, &order))
+	assert.Equal(t, POStatusDraft, order.Status)
+
+	// drafts are not received
+	_, _, err = store.ReceivePurchaseOrder(ctx, order.ID, nil, 0)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	_, err = store.SubmitPurchaseOrder(ctx, order.ID)
+	assert.NoError(t, err)
+	_, err = store.SubmitPurchaseOrder(ctx, order.ID)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// goods still due keep the product from being deleted
+	assert.ErrorIs(t, store.DeleteProduct(ctx, product.ID, AnyVersion), ErrInUse)
+
+	// part arrives at a different price
+	cost := decimal.RequireFromString("3.50")
+	got, levels, err := store.ReceivePurchaseOrder(ctx, order.ID, []ReceiptLine{{ProductID: product.ID, Quantity: 4, UnitCost: &cost}}, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, POStatusPartiallyReceived, got.Status)
+	assert.Equal(t, 5, levels[0].Stock)
+	assert.True(t, cost.Equal(got.Receipts[0].UnitCost))
+
+	_, _, err = store.ReceivePurchaseOrder(ctx, order.ID, []ReceiptLine{{ProductID: product.ID, Quantity: 7}}, 7)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// the rest, at the ordered cost
+	got, levels, err = store.ReceivePurchaseOrder(ctx, order.ID, nil, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, POStatusReceived, got.Status)
+	assert.NotEmpty(t, got.ReceivedAt)
+	assert.Equal(t, 11, levels[0].Stock)
+	assert.Equal(t, 6, got.Receipts[1].Quantity)
+	assert.True(t, decimal.NewFromInt(3).Equal(got.Receipts[1].UnitCost))
+
+	movements, err := store.ListMovements(ctx, product.ID, MovementFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, movements, 3)
+	assert.Equal(t, ReasonReceipt, movements[2].Reason)
+	assert.Equal(t, "PO-1", movements[2].Reference)
+
+	_, err = store.CancelPurchaseOrder(ctx, order.ID)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// ordered products and suppliers are only deleted softly
+	assert.NoError(t, store.DeleteProduct(ctx, product.ID, AnyVersion))
+	assert.ErrorIs(t, store.PurgeProduct(ctx, product.ID, AnyVersion), ErrInUse)
+	assert.NoError(t, store.DeleteProduct(ctx, foreign.ID, AnyVersion))
+	assert.NoError(t, store.PurgeProduct(ctx, foreign.ID, AnyVersion))
+	assert.NoError(t, store.DeleteSupplier(ctx, other.ID, AnyVersion, SupplierDeletion{}))
+	assert.NoError(t, store.PurgeSupplier(ctx, other.ID, AnyVersion))
+}
+
+func TestMemoryCustomers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	acme := Customer{Name: "Acme Ltd", Email: "acme@example.com"}
+	assert.NoError(t, store.CreateCustomer(ctx, &acme))
+	other := Customer{Name: "Other"}
+	assert.NoError(t, store.CreateCustomer(ctx, &other))
+
+	// customers without an email never collide
+	assert.NoError(t, store.CreateCustomer(ctx, &Customer{Name: "Third"}))
+	assert.ErrorIs(t, store.UpdateCustomerEmail(ctx, other.ID, "acme@example.com"), ErrDuplicate)
+	assert.ErrorIs(t, store.UpdateCustomerPhone(ctx, 99, "555"), ErrNotFound)
+
+	assert.NoError(t, store.UpdateCustomerAddress(ctx, acme.ID, "1 Main St"))
+	found, err := store.ListCustomers(ctx, CustomerFilter{Name: "acme"})
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, "1 Main St", found[0].Address)
+
+	// customers with sales orders are kept
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+	assert.NoError(t, store.CreateSalesOrder(ctx, &SalesOrder{CustomerID: acme.ID, Lines: []SalesOrderLine{{ProductID: product.ID, Quantity: 1}}}))
+	assert.ErrorIs(t, store.DeleteCustomer(ctx, acme.ID), ErrInUse)
+	assert.NoError(t, store.DeleteCustomer(ctx, other.ID))
+}
+
+func TestMemorySalesOrderFlow(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 5, Price: decimal.RequireFromString("2.50")}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+	customer := Customer{Name: "testcustomer", Email: "customer@example.com"}
+	assert.NoError(t, store.CreateCustomer(ctx, &customer))
+	assert.ErrorIs(t, store.CreateCustomer(ctx, &Customer{Name: "copy", Email: "customer@example.com"}), ErrDuplicate)
+
+	err := store.CreateSalesOrder(ctx, &SalesOrder{CustomerID: 99, Lines: []SalesOrderLine{{ProductID: product.ID, Quantity: 1}}})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	order := SalesOrder{CustomerID: customer.ID, Lines: []SalesOrderLine{{ProductID: product.ID, Quantity: 3}}}
+	assert.NoError(t, store.CreateSalesOrder(ctx, &order))
+	assert.Equal(t, SOStatusDraft, order.Status)
+
+	// later price changes do not touch the order
+	assert.NoError(t, store.UpdateProductPrice(ctx, product.ID, AnyVersion, decimal.NewFromInt(9), 0))
+	got, err := store.GetSalesOrder(ctx, order.ID)
+	assert.NoError(t, err)
+	assert.True(t, decimal.RequireFromString("7.50").Equal(got.Total()))
+
+	// drafts reserve nothing and cannot be fulfilled
+	_, _, err = store.FulfilSalesOrder(ctx, order.ID, 0)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	_, err = store.ConfirmSalesOrder(ctx, order.ID)
+	assert.NoError(t, err)
+	level, err := store.GetStockLevel(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, level.Stock)
+	assert.Equal(t, 3, level.Reserved)
+
+	// nor can stock reserved for a confirmed order
+	assert.ErrorIs(t, store.DeleteProduct(ctx, product.ID, AnyVersion), ErrInUse)
+
+	// reserved stock cannot be taken by hand, nor counted away
+	_, err = store.AdjustStock(ctx, product.ID, -3, StockChange{Reason: ReasonDamage})
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+	_, err = store.SetStock(ctx, product.ID, 2, StockChange{Reason: ReasonAdjustment})
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+
+	got, levels, err := store.FulfilSalesOrder(ctx, order.ID, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, SOStatusFulfilled, got.Status)
+	assert.NotEmpty(t, got.FulfilledAt)
+	assert.Equal(t, 2, levels[0].Stock)
+	assert.Equal(t, 0, levels[0].Reserved)
+
+	movements, err := store.ListMovements(ctx, product.ID, MovementFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, movements, 2)
+	assert.Equal(t, ReasonSale, movements[1].Reason)
+	assert.Equal(t, -3, movements[1].Delta)
+	assert.Equal(t, "SO-1", movements[1].Reference)
+
+	_, err = store.CancelSalesOrder(ctx, order.ID)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// cancelling a confirmed order gives the reservation back
+	other := SalesOrder{CustomerID: customer.ID, Lines: []SalesOrderLine{{ProductID: product.ID, Quantity: 2}}}
+	assert.NoError(t, store.CreateSalesOrder(ctx, &other))
+	_, err = store.ConfirmSalesOrder(ctx, other.ID)
+	assert.NoError(t, err)
+	_, err = store.CancelSalesOrder(ctx, other.ID)
+	assert.NoError(t, err)
+	level, err = store.GetStockLevel(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, level.Reserved)
+
+	// sold products cannot be purged
+	assert.NoError(t, store.DeleteProduct(ctx, product.ID, AnyVersion))
+	assert.ErrorIs(t, store.PurgeProduct(ctx, product.ID, AnyVersion), ErrInUse)
+}
+
+func TestMemoryConfirmSalesOrdersNeverOversell(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 10}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+	customer := Customer{Name: "testcustomer"}
+	assert.NoError(t, store.CreateCustomer(ctx, &customer))
+
+	// 8 orders of 3 race for 10 in stock, only 3 can be confirmed
+	ids := []int64{}
+	for i := 0; i < 8; i++ {
+		order := SalesOrder{CustomerID: customer.ID, Lines: []SalesOrderLine{{ProductID: product.ID, Quantity: 3}}}
+		assert.NoError(t, store.CreateSalesOrder(ctx, &order))
+		ids = append(ids, order.ID)
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan error, len(ids))
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.ConfirmSalesOrder(ctx, id)
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	confirmed := 0
+	for err := range results {
+		if err == nil {
+			confirmed++
+		} else {
+			assert.ErrorIs(t, err, ErrInsufficientStock)
+		}
+	}
+	assert.Equal(t, 3, confirmed)
+
+	level, err := store.GetStockLevel(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, level.Reserved)
+}
+
+func TestMemoryListProductActivity(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 10, Price: decimal.NewFromInt(2)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+	customer := Customer{Name: "testcustomer"}
+	assert.NoError(t, store.CreateCustomer(ctx, &customer))
+
+	// 3 sold through an order at 2.00, then the price goes up and 1 is sold by hand
+	order := SalesOrder{CustomerID: customer.ID, Lines: []SalesOrderLine{{ProductID: product.ID, Quantity: 3}}}
+	assert.NoError(t, store.CreateSalesOrder(ctx, &order))
+	_, err := store.ConfirmSalesOrder(ctx, order.ID)
+	assert.NoError(t, err)
+	_, _, err = store.FulfilSalesOrder(ctx, order.ID, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, store.UpdateProductPrice(ctx, product.ID, AnyVersion, decimal.NewFromInt(5), 0))
+	_, err = store.AdjustStock(ctx, product.ID, -1, StockChange{Reason: ReasonSale})
+	assert.NoError(t, err)
+	_, err = store.AdjustStock(ctx, product.ID, -1, StockChange{Reason: ReasonDamage})
+	assert.NoError(t, err)
+
+	activity, err := store.ListProductActivity(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, activity, 1)
+	assert.Equal(t, 4, activity[0].UnitsSold)
+	assert.True(t, decimal.NewFromInt(11).Equal(activity[0].Revenue))
+	assert.Equal(t, 0, activity[0].OpeningStock())
+	assert.False(t, activity[0].LastMovementAt.IsZero())
+
+	// a window starting now sees no movements
+	activity, err = store.ListProductActivity(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, activity[0].UnitsSold)
+	assert.Equal(t, 5, activity[0].OpeningStock())
+}
+
+func TestMemoryListValuationInputs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 2, Cost: decimal.NewFromInt(1)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	// two deliveries on one order at different costs
+	order := PurchaseOrder{SupplierID: supplier.ID, Lines: []PurchaseOrderLine{{ProductID: product.ID, QuantityOrdered: 5, UnitCost: decimal.NewFromInt(3)}}}
+	assert.NoError(t, store.CreatePurchaseOrder(ctx, &order))
+	_, err := store.SubmitPurchaseOrder(ctx, order.ID)
+	assert.NoError(t, err)
+	cost := decimal.NewFromInt(4)
+	_, _, err = store.ReceivePurchaseOrder(ctx, order.ID, []ReceiptLine{{ProductID: product.ID, Quantity: 2, UnitCost: &cost}}, 0)
+	assert.NoError(t, err)
+	_, _, err = store.ReceivePurchaseOrder(ctx, order.ID, nil, 0)
+	assert.NoError(t, err)
+
+	inputs, err := store.ListValuationInputs(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, inputs[0].Movements, 3)
+	assert.Nil(t, inputs[0].Movements[0].UnitCost)
+	assert.True(t, cost.Equal(*inputs[0].Movements[1].UnitCost))
+	assert.True(t, decimal.NewFromInt(3).Equal(*inputs[0].Movements[2].UnitCost))
+
+	// before anything happened there is nothing to value
+	inputs, err = store.ListValuationInputs(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, inputs[0].Movements)
+}
+
+func TestMemoryListValuationInputsAsOf(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 2, Cost: decimal.NewFromInt(1), Price: decimal.NewFromInt(5)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	asOf := time.Now()
+	assert.NoError(t, store.UpdateProductCost(ctx, product.ID, AnyVersion, decimal.NewFromInt(2)))
+	assert.NoError(t, store.UpdateProductPrice(ctx, product.ID, AnyVersion, decimal.NewFromInt(6), 0))
+	cost := decimal.NewFromInt(3)
+	_, err := store.PatchProduct(ctx, product.ID, AnyVersion, ProductPatch{Cost: &cost}, 0)
+	assert.NoError(t, err)
+
+	// the cost and price of that time, not today's
+	inputs, err := store.ListValuationInputs(ctx, asOf)
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(1).Equal(inputs[0].Cost))
+	assert.True(t, decimal.NewFromInt(5).Equal(inputs[0].Price))
+
+	inputs, err = store.ListValuationInputs(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(3).Equal(inputs[0].Cost))
+	assert.True(t, decimal.NewFromInt(6).Equal(inputs[0].Price))
+}
+
+func TestMemoryAnalyticsSkipDeletedProducts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	kept := Product{Name: "keptproduct", SupplierID: supplier.ID, Stock: 2, Cost: decimal.NewFromInt(1)}
+	assert.NoError(t, store.CreateProduct(ctx, &kept))
+	deleted := Product{Name: "deletedproduct", SupplierID: supplier.ID, Stock: 3, Cost: decimal.NewFromInt(1)}
+	assert.NoError(t, store.CreateProduct(ctx, &deleted))
+	before := time.Now()
+	assert.NoError(t, store.DeleteProduct(ctx, deleted.ID, AnyVersion))
+
+	// deleted products are neither analysed nor valued
+	activity, err := store.ListProductActivity(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, activity, 1) {
+		assert.Equal(t, kept.ID, activity[0].ProductID)
+	}
+	inputs, err := store.ListValuationInputs(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, inputs, 1) {
+		assert.Equal(t, kept.ID, inputs[0].ProductID)
+	}
+
+	// a report from before the deletion stays as it was
+	inputs, err = store.ListValuationInputs(ctx, before)
+	assert.NoError(t, err)
+	assert.Len(t, inputs, 2)
+}
+
+func TestMemoryListReorderCandidates(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier", LeadTimeDays: 5}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Stock: 20, MinimumStock: 5, Cost: decimal.NewFromInt(3)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+	plenty := Product{Name: "plenty", SupplierID: supplier.ID, Stock: 20, MinimumStock: 5}
+	assert.NoError(t, store.CreateProduct(ctx, &plenty))
+
+	// sales and damage count as consumption, adjustments do not
+	for _, change := range []struct {
+		delta  int
+		reason string
+	}{{-10, ReasonSale}, {-3, ReasonDamage}, {-4, ReasonAdjustment}} {
+		_, err := store.AdjustStock(ctx, product.ID, change.delta, StockChange{Reason: change.reason})
+		assert.NoError(t, err)
+	}
+
+	order := PurchaseOrder{SupplierID: supplier.ID, Lines: []PurchaseOrderLine{{ProductID: product.ID, QuantityOrdered: 6, UnitCost: decimal.NewFromInt(2)}}}
+	assert.NoError(t, store.CreatePurchaseOrder(ctx, &order))
+
+	candidates, err := store.ListReorderCandidates(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, candidates, 1)
+	assert.Equal(t, 13, candidates[0].Consumed)
+	assert.Equal(t, 6, candidates[0].OnOrder)
+	assert.Equal(t, 5, candidates[0].LeadTimeDays)
+	// nothing received yet, the product's cost stands in
+	assert.True(t, decimal.NewFromInt(3).Equal(candidates[0].LastUnitCost))
+
+	// consumption before the window is ignored
+	candidates, err = store.ListReorderCandidates(ctx, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, candidates[0].Consumed)
+
+	// stock reserved for a confirmed sales order does not count
+	customer := Customer{Name: "testcustomer"}
+	assert.NoError(t, store.CreateCustomer(ctx, &customer))
+	sale := SalesOrder{CustomerID: customer.ID, Lines: []SalesOrderLine{{ProductID: plenty.ID, Quantity: 16}}}
+	assert.NoError(t, store.CreateSalesOrder(ctx, &sale))
+	_, err = store.ConfirmSalesOrder(ctx, sale.ID)
+	assert.NoError(t, err)
+	candidates, err = store.ListReorderCandidates(ctx, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, candidates, 2)
+	assert.Equal(t, 4, candidates[1].Stock)
+}
+
+func TestMemoryCreatePurchaseOrdersAllOrNothing(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	// the second order names a missing product, so the first one is not created either
+	orders := []PurchaseOrder{
+		{SupplierID: supplier.ID, Lines: []PurchaseOrderLine{{ProductID: product.ID, QuantityOrdered: 1}}},
+		{SupplierID: supplier.ID, Lines: []PurchaseOrderLine{{ProductID: 9, QuantityOrdered: 1}}},
+	}
+	assert.ErrorIs(t, store.CreatePurchaseOrders(ctx, orders), ErrInvalidReference)
+	created, err := store.ListPurchaseOrders(ctx, PurchaseOrderFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, created)
+
+	assert.NoError(t, store.CreatePurchaseOrders(ctx, orders[:1]))
+	assert.EqualValues(t, 1, orders[0].ID)
+	assert.Equal(t, POStatusDraft, orders[0].Status)
+}
+
+func TestMemoryImportProducts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SKU: "TP-1", SupplierID: supplier.ID, Price: decimal.NewFromInt(5)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	renamed, sku, price := "renamed", "TP-1", decimal.NewFromInt(6)
+	name, supplierID := "newproduct", supplier.ID
+	rows := []ProductImport{
+		{Row: 2, Patch: ProductPatch{Name: &renamed, SKU: &sku, Price: &price}},
+		{Row: 3, Patch: ProductPatch{Name: &name, SupplierID: &supplierID}, Stock: 4},
+	}
+
+	// a dry run counts the rows but writes nothing
+	result, err := store.ImportProducts(ctx, rows, true, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportResult{Created: 1, Updated: 1, Errors: []ImportError{}, DryRun: true}, result)
+	_, total, err := store.ListProducts(ctx, ProductFilter{Page: Page{Limit: MaxPageSize}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	history, err := store.ListPriceHistory(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	// one bad row and no row is written
+	missing := int64(9)
+	bad := append(rows, ProductImport{Row: 4, Patch: ProductPatch{Name: &name, SupplierID: &missing}})
+	result, err = store.ImportProducts(ctx, bad, false, 0)
+	assert.NoError(t, err)
+	assert.False(t, result.Committed)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, 4, result.Errors[0].Row)
+	}
+	got, err := store.GetProduct(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "testproduct", got.Name)
+
+	result, err = store.ImportProducts(ctx, rows, false, 0)
+	assert.NoError(t, err)
+	assert.True(t, result.Committed)
+	got, err = store.GetProduct(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", got.Name)
+	assert.True(t, price.Equal(got.Price))
+	level, err := store.GetStockLevel(ctx, product.ID+1)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, level.Stock)
+
+	// a known name with a new SKU updates that product instead of creating another
+	newSKU := "TP-9"
+	result, err = store.ImportProducts(ctx, []ProductImport{{Row: 2, Patch: ProductPatch{Name: &renamed, SKU: &newSKU}}}, false, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportResult{Updated: 1, Errors: []ImportError{}, Committed: true}, result)
+	got, err = store.GetProduct(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "TP-9", got.SKU)
+}
+
+func TestMemoryImportSuppliers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier", LeadTimeDays: 7}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+
+	existing, days := "testsupplier", 3
+	name, email := "newsupplier", "new@example.com"
+	result, err := store.ImportSuppliers(ctx, []SupplierImport{
+		{Row: 2, Patch: SupplierPatch{Name: &existing, LeadTimeDays: &days}},
+		{Row: 3, Patch: SupplierPatch{Name: &name, ContactEmail: &email}},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportResult{Created: 1, Updated: 1, Errors: []ImportError{}, Committed: true}, result)
+
+	got, err := store.GetSupplier(ctx, supplier.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got.LeadTimeDays)
+	created, err := store.GetSupplier(ctx, supplier.ID+1)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultLeadTimeDays, created.LeadTimeDays)
+
+	// the same email twice is a duplicate
+	other := "othersupplier"
+	result, err = store.ImportSuppliers(ctx, []SupplierImport{{Row: 2, Patch: SupplierPatch{Name: &other, ContactEmail: &email}}}, false)
+	assert.NoError(t, err)
+	assert.Len(t, result.Errors, 1)
+	_, total, err := store.ListSuppliers(ctx, SupplierFilter{Page: Page{Limit: MaxPageSize}})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+
+	// a deleted supplier keeps its name, the row asks for a restore
+	assert.NoError(t, store.DeleteSupplier(ctx, created.ID, AnyVersion, SupplierDeletion{}))
+	result, err = store.ImportSuppliers(ctx, []SupplierImport{{Row: 2, Patch: SupplierPatch{Name: &name}}}, false)
+	assert.NoError(t, err)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Contains(t, result.Errors[0].Message, "is deleted, restore it first")
+	}
+}
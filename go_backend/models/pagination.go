@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+)
+
+// page sizes of the list endpoints
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// Page selects Limit rows after skipping Offset rows of a sorted list
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// Sort orders a list by one of its sort keys, ties are broken by id, an empty Key sorts by id only
+type Sort struct {
+	Key        string
+	Descending bool
+}
+
+// keys the product and supplier lists can be sorted by, they are also the column names
+var (
+	ProductSortKeys  = []string{"name", "price", "stock", "created_at"}
+	SupplierSortKeys = []string{"name", "lead_time_days", "created_at"}
+)
+
+// SQL for the ORDER BY clause, keys not in keys fall back to id
+func (s Sort) orderBy(keys []string) string {
+	direction := ""
+	if s.Descending {
+		direction = " DESC"
+	}
+	if !slices.Contains(keys, s.Key) {
+		return "id" + direction
+	}
+	return s.Key + direction + ", id" + direction
+}
+
+// the rows of the page, items are already sorted
+func paginate[T any](items []T, page Page) []T {
+	start := min(page.Offset, len(items))
+	end := len(items)
+	if page.Limit > 0 {
+		end = min(start+page.Limit, end)
+	}
+	return items[start:end]
+}
+
+// SQL for the LIMIT and OFFSET clauses, their values are appended to args
+func (p Page) limitOffset(args []any) (string, []any) {
+	clause := ""
+	if p.Limit > 0 {
+		args = append(args, p.Limit)
+		clause += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if p.Offset > 0 {
+		args = append(args, p.Offset)
+		clause += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+	return clause, args
+}
+
+// order items like Sort does in SQL, items must already be sorted by id and compare looks at the sort key only
+func sortItems[T any](items []T, sort Sort, compare func(a, b T) int) {
+	if !sort.Descending {
+		slices.SortStableFunc(items, compare)
+		return
+	}
+	// reversed first so ties stay in descending id order
+	slices.Reverse(items)
+	slices.SortStableFunc(items, func(a, b T) int { return compare(b, a) })
+}
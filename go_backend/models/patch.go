@@ -0,0 +1,32 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// the SET clause of a partial update, every value becomes a numbered parameter
+type setClause struct {
+	assignments []string
+	args        []any
+}
+
+func (s *setClause) set(column string, value any) {
+	s.args = append(s.args, value)
+	s.assignments = append(s.assignments, fmt.Sprintf("%s = $%d", column, len(s.args)))
+}
+
+// for nullable text columns, an empty value is stored as NULL
+func (s *setClause) setOptional(column string, value string) {
+	s.args = append(s.args, value)
+	s.assignments = append(s.assignments, fmt.Sprintf("%s = NULLIF($%d, '')", column, len(s.args)))
+}
+
+// UPDATE statement for the row with the given id at version, bumping updated_at and returning columns
+func (s *setClause) update(table string, id int64, version int64, columns string) (string, []any) {
+	args := append(s.args, id)
+	where := fmt.Sprintf("id = $%d", len(args))
+	condition, args := versionCondition(version, args)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s%s RETURNING %s", table, strings.Join(append(s.assignments, "updated_at = NOW()"), ", "), where, condition, columns)
+	return query, args
+}
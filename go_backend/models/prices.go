@@ -0,0 +1,69 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// why a price changed, recorded with every row of the price history
+const (
+	PriceReasonManual       = "manual"        // through the API
+	PriceReasonScheduled    = "scheduled"     // a scheduled price took effect
+	PriceReasonPromotion    = "promotion"     // a promotion started
+	PriceReasonPromotionEnd = "promotion_end" // a promotion ended and the old price is back
+)
+
+// scheduled price lifecycle: pending -> done, or pending -> active -> done for
+// promotions. Only pending prices can be cancelled.
+const (
+	ScheduledPricePending   = "pending"
+	ScheduledPriceActive    = "active"
+	ScheduledPriceDone      = "done"
+	ScheduledPriceCancelled = "cancelled"
+)
+
+// PriceChange is one row of a product's price history
+type PriceChange struct {
+	ID               int64           `json:"id"`
+	ProductID        int64           `json:"product_id"`
+	OldPrice         decimal.Decimal `json:"old_price"`
+	NewPrice         decimal.Decimal `json:"new_price"`
+	Reason           string          `json:"reason"`
+	UserID           *int64          `json:"user_id"`            // nil when a schedule made the change
+	ScheduledPriceID *int64          `json:"scheduled_price_id"` // the schedule behind the change, if any
+	CreatedAt        string          `json:"created_at"`
+}
+
+// ScheduledPrice sets the price at StartsAt. With EndsAt it is a promotion,
+// at EndsAt the price goes back to what it was before, unless it was changed
+// again in the meantime.
+type ScheduledPrice struct {
+	ID          int64               `json:"id"`
+	ProductID   int64               `json:"product_id"`
+	Price       decimal.Decimal     `json:"price"`
+	StartsAt    time.Time           `json:"starts_at"`
+	EndsAt      *time.Time          `json:"ends_at"`
+	Status      string              `json:"status"`
+	RevertPrice decimal.NullDecimal `json:"revert_price"` // the price before a promotion started
+	CreatedBy   *int64              `json:"created_by"`
+	CreatedAt   string              `json:"created_at"`
+}
+
+// PriceStore keeps the price history and applies scheduled prices
+type PriceStore interface {
+	// ListPriceHistory returns ErrNotFound for a missing product, oldest first
+	ListPriceHistory(ctx context.Context, productID int64) ([]PriceChange, error)
+	// SchedulePrice inserts a pending schedule and sets its ID. Returns
+	// ErrInvalidState when a promotion overlaps another one of the product.
+	SchedulePrice(ctx context.Context, scheduled *ScheduledPrice) error
+	// ListScheduledPrices returns ErrNotFound for a missing product, by start time
+	ListScheduledPrices(ctx context.Context, productID int64) ([]ScheduledPrice, error)
+	// CancelScheduledPrice returns ErrInvalidState once the price took effect
+	CancelScheduledPrice(ctx context.Context, productID int64, id int64) (ScheduledPrice, error)
+	// ApplyDuePrices starts every schedule due at now and ends the promotions
+	// that ran out, returning the changes made. A schedule that fails is left for the
+	// next run without holding up the others, its error is returned along with the changes.
+	ApplyDuePrices(ctx context.Context, now time.Time) ([]PriceChange, error)
+}
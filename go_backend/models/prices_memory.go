@@ -0,0 +1,181 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// set the price of product, which the caller saves, and write the history row.
+// Nothing is written when the price stays the same, the change then has ID 0. Caller holds the lock.
+func (m *MemoryStore) setPriceLocked(product *Product, price decimal.Decimal, reason string, userID int64, scheduledID int64) PriceChange {
+	change := PriceChange{ProductID: product.ID, OldPrice: product.Price, NewPrice: price, Reason: reason}
+	if product.Price.Equal(price) {
+		return change
+	}
+	product.Price = price
+
+	m.lastPriceChangeID++
+	change.ID = m.lastPriceChangeID
+	if userID != 0 {
+		change.UserID = &userID
+	}
+	if scheduledID != 0 {
+		change.ScheduledPriceID = &scheduledID
+	}
+	change.CreatedAt = memoryNow()
+	m.priceHistory = append(m.priceHistory, change)
+	return change
+}
+
+func (m *MemoryStore) ListPriceHistory(ctx context.Context, productID int64) ([]PriceChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.products[productID]; !ok {
+		return nil, ErrNotFound
+	}
+	changes := []PriceChange{}
+	for _, change := range m.priceHistory {
+		if change.ProductID == productID {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (m *MemoryStore) SchedulePrice(ctx context.Context, scheduled *ScheduledPrice) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if product, ok := m.products[scheduled.ProductID]; !ok || product.DeletedAt != "" {
+		return ErrNotFound
+	}
+	if scheduled.EndsAt != nil {
+		for _, existing := range m.scheduledPrices {
+			open := existing.Status == ScheduledPricePending || existing.Status == ScheduledPriceActive
+			if existing.ProductID == scheduled.ProductID && open && existing.EndsAt != nil &&
+				existing.StartsAt.Before(*scheduled.EndsAt) && existing.EndsAt.After(scheduled.StartsAt) {
+				return fmt.Errorf("%w: product %d already has a promotion in that time", ErrInvalidState, scheduled.ProductID)
+			}
+		}
+	}
+
+	m.lastScheduledPriceID++
+	scheduled.ID = m.lastScheduledPriceID
+	scheduled.Status = ScheduledPricePending
+	scheduled.RevertPrice = decimal.NullDecimal{}
+	scheduled.CreatedAt = memoryNow()
+	m.scheduledPrices[scheduled.ID] = *scheduled
+	return nil
+}
+
+func (m *MemoryStore) ListScheduledPrices(ctx context.Context, productID int64) ([]ScheduledPrice, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.products[productID]; !ok {
+		return nil, ErrNotFound
+	}
+	return m.schedulesLocked(func(scheduled ScheduledPrice) bool { return scheduled.ProductID == productID }, byStart), nil
+}
+
+func byStart(a, b ScheduledPrice) int { return a.StartsAt.Compare(b.StartsAt) }
+
+func byEnd(a, b ScheduledPrice) int { return a.EndsAt.Compare(*b.EndsAt) }
+
+// the schedules keep matches, sorted by order and then id. Caller holds the lock.
+func (m *MemoryStore) schedulesLocked(keep func(ScheduledPrice) bool, order func(a, b ScheduledPrice) int) []ScheduledPrice {
+	schedules := []ScheduledPrice{}
+	for _, scheduled := range sortedByID(m.scheduledPrices) {
+		if keep(scheduled) {
+			schedules = append(schedules, scheduled)
+		}
+	}
+	slices.SortStableFunc(schedules, order)
+	return schedules
+}
+
+func (m *MemoryStore) CancelScheduledPrice(ctx context.Context, productID int64, id int64) (ScheduledPrice, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	scheduled, ok := m.scheduledPrices[id]
+	if !ok || scheduled.ProductID != productID {
+		return ScheduledPrice{}, ErrNotFound
+	}
+	if scheduled.Status != ScheduledPricePending {
+		return ScheduledPrice{}, fmt.Errorf("%w: scheduled price %d is %s", ErrInvalidState, id, scheduled.Status)
+	}
+	scheduled.Status = ScheduledPriceCancelled
+	m.scheduledPrices[id] = scheduled
+	return scheduled, nil
+}
+
+func (m *MemoryStore) ApplyDuePrices(ctx context.Context, now time.Time) ([]PriceChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	changes := []PriceChange{}
+	starting := m.schedulesLocked(func(scheduled ScheduledPrice) bool {
+		return scheduled.Status == ScheduledPricePending && !scheduled.StartsAt.After(now)
+	}, byStart)
+	for _, scheduled := range starting {
+		if m.cancelOfDeletedLocked(scheduled) {
+			continue
+		}
+		reason, status := PriceReasonScheduled, ScheduledPriceDone
+		if scheduled.EndsAt != nil {
+			reason, status = PriceReasonPromotion, ScheduledPriceActive
+			scheduled.RevertPrice = decimal.NewNullDecimal(m.products[scheduled.ProductID].Price)
+		}
+		if change := m.setPriceOf(scheduled.ProductID, scheduled.Price, reason, scheduled.ID); change.ID != 0 {
+			changes = append(changes, change)
+		}
+		scheduled.Status = status
+		m.scheduledPrices[scheduled.ID] = scheduled
+	}
+
+	ending := m.schedulesLocked(func(scheduled ScheduledPrice) bool {
+		return scheduled.Status == ScheduledPriceActive && !scheduled.EndsAt.After(now)
+	}, byEnd)
+	for _, scheduled := range ending {
+		if m.cancelOfDeletedLocked(scheduled) {
+			continue
+		}
+		// a price set during the promotion wins over the old one
+		if m.products[scheduled.ProductID].Price.Equal(scheduled.Price) && scheduled.RevertPrice.Valid {
+			if change := m.setPriceOf(scheduled.ProductID, scheduled.RevertPrice.Decimal, PriceReasonPromotionEnd, scheduled.ID); change.ID != 0 {
+				changes = append(changes, change)
+			}
+		}
+		scheduled.Status = ScheduledPriceDone
+		m.scheduledPrices[scheduled.ID] = scheduled
+	}
+	return changes, nil
+}
+
+// the memory version of cancelScheduleTx, reports whether the product of scheduled is deleted. Caller holds the lock.
+func (m *MemoryStore) cancelOfDeletedLocked(scheduled ScheduledPrice) bool {
+	if m.products[scheduled.ProductID].DeletedAt == "" {
+		return false
+	}
+	scheduled.Status = ScheduledPriceCancelled
+	m.scheduledPrices[scheduled.ID] = scheduled
+	return true
+}
+
+// setPriceLocked for a schedule, saving the product. Caller holds the lock.
+func (m *MemoryStore) setPriceOf(productID int64, price decimal.Decimal, reason string, scheduledID int64) PriceChange {
+	product := m.products[productID]
+	change := m.setPriceLocked(&product, price, reason, 0, scheduledID)
+	if change.ID != 0 {
+		product.UpdatedAt = memoryNow()
+		product.Version++
+		m.products[productID] = product
+	}
+	return change
+}
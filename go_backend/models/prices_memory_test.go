@@ -0,0 +1,124 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryScheduledPrices(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Price: decimal.NewFromInt(10)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	// a manual change is recorded with the user
+	assert.NoError(t, store.UpdateProductPrice(ctx, product.ID, AnyVersion, decimal.NewFromInt(12), 7))
+	assert.NoError(t, store.UpdateProductPrice(ctx, product.ID, AnyVersion, decimal.NewFromInt(12), 7))
+
+	now := time.Now()
+	endsAt := now.Add(2 * time.Hour)
+	promotion := ScheduledPrice{ProductID: product.ID, Price: decimal.NewFromInt(8), StartsAt: now.Add(time.Hour), EndsAt: &endsAt}
+	assert.NoError(t, store.SchedulePrice(ctx, &promotion))
+	overlapping := ScheduledPrice{ProductID: product.ID, Price: decimal.NewFromInt(7), StartsAt: now, EndsAt: &endsAt}
+	assert.ErrorIs(t, store.SchedulePrice(ctx, &overlapping), ErrInvalidState)
+	later := ScheduledPrice{ProductID: product.ID, Price: decimal.NewFromInt(15), StartsAt: now.Add(3 * time.Hour)}
+	assert.NoError(t, store.SchedulePrice(ctx, &later))
+
+	// nothing is due yet
+	changes, err := store.ApplyDuePrices(ctx, now)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	changes, err = store.ApplyDuePrices(ctx, now.Add(90*time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	got, _ := store.GetProduct(ctx, product.ID)
+	assert.True(t, decimal.NewFromInt(8).Equal(got.Price))
+	_, err = store.CancelScheduledPrice(ctx, product.ID, promotion.ID)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// the new price replaced the promotion price, so the old price does not come back
+	changes, err = store.ApplyDuePrices(ctx, now.Add(4*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, PriceReasonScheduled, changes[0].Reason)
+	got, _ = store.GetProduct(ctx, product.ID)
+	assert.True(t, decimal.NewFromInt(15).Equal(got.Price))
+
+	history, err := store.ListPriceHistory(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.EqualValues(t, 7, *history[0].UserID)
+	assert.Nil(t, history[1].UserID)
+
+	schedules, err := store.ListScheduledPrices(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Len(t, schedules, 2)
+	assert.Equal(t, ScheduledPriceDone, schedules[0].Status)
+
+	_, err = store.ListPriceHistory(ctx, 99)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMemoryPromotionEnd(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Price: decimal.NewFromInt(10)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	now := time.Now()
+	endsAt := now.Add(time.Hour)
+	promotion := ScheduledPrice{ProductID: product.ID, Price: decimal.NewFromInt(8), StartsAt: now, EndsAt: &endsAt}
+	assert.NoError(t, store.SchedulePrice(ctx, &promotion))
+
+	_, err := store.ApplyDuePrices(ctx, now)
+	assert.NoError(t, err)
+	changes, err := store.ApplyDuePrices(ctx, endsAt)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, PriceReasonPromotionEnd, changes[0].Reason)
+	got, _ := store.GetProduct(ctx, product.ID)
+	assert.True(t, decimal.NewFromInt(10).Equal(got.Price))
+}
+
+func TestMemoryApplyDuePricesCancelsDeletedProduct(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	supplier := Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := Product{Name: "testproduct", SupplierID: supplier.ID, Price: decimal.NewFromInt(10)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	now := time.Now()
+	scheduled := ScheduledPrice{ProductID: product.ID, Price: decimal.NewFromInt(8), StartsAt: now}
+	assert.NoError(t, store.SchedulePrice(ctx, &scheduled))
+	assert.NoError(t, store.DeleteProduct(ctx, product.ID, AnyVersion))
+
+	// the schedule is cancelled, the price and its history stay as they were
+	changes, err := store.ApplyDuePrices(ctx, now)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+	schedules, err := store.ListScheduledPrices(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, ScheduledPriceCancelled, schedules[0].Status)
+	history, err := store.ListPriceHistory(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	// deleted products get no new schedules
+	assert.ErrorIs(t, store.SchedulePrice(ctx, &ScheduledPrice{ProductID: product.ID, Price: decimal.NewFromInt(7), StartsAt: now}), ErrNotFound)
+}
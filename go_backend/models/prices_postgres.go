@@ -0,0 +1,290 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PostgresPriceStore implements PriceStore on the product_prices and scheduled_prices tables
+type PostgresPriceStore struct {
+	DB *sql.DB
+}
+
+const priceChangeColumns = "id, product_id, old_price, new_price, reason, changed_by, scheduled_price_id, created_at"
+
+const scheduledPriceColumns = "id, product_id, price, starts_at, ends_at, status, revert_price, created_by, created_at"
+
+func scanPriceChange(row scanner) (PriceChange, error) {
+	var change PriceChange
+	err := row.Scan(&change.ID, &change.ProductID, &change.OldPrice, &change.NewPrice, &change.Reason, &change.UserID, &change.ScheduledPriceID, &change.CreatedAt)
+	return change, err
+}
+
+func scanScheduledPrice(row scanner) (ScheduledPrice, error) {
+	var scheduled ScheduledPrice
+	err := row.Scan(&scheduled.ID, &scheduled.ProductID, &scheduled.Price, &scheduled.StartsAt, &scheduled.EndsAt, &scheduled.Status, &scheduled.RevertPrice, &scheduled.CreatedBy, &scheduled.CreatedAt)
+	return scheduled, err
+}
+
+// lock the product row for the rest of tx and read its price, ErrNotFound once the product is deleted
+func lockPrice(ctx context.Context, tx *sql.Tx, productID int64) (decimal.Decimal, error) {
+	var price decimal.Decimal
+	err := tx.QueryRowContext(ctx, "SELECT price FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", productID).Scan(&price)
+	return price, mapError(err)
+}
+
+// set products.price inside tx and write the history row, shared by every flow that changes a price.
+// Nothing is written when the price stays the same, the change then has ID 0.
+func setPriceTx(ctx context.Context, tx *sql.Tx, productID int64, price decimal.Decimal, reason string, userID int64, scheduledID int64) (PriceChange, error) {
+	old, err := lockPrice(ctx, tx, productID)
+	if err != nil {
+		return PriceChange{}, err
+	}
+	return writePriceTx(ctx, tx, productID, old, price, reason, userID, scheduledID)
+}
+
+// like setPriceTx for a product already locked with lockPrice
+func writePriceTx(ctx context.Context, tx *sql.Tx, productID int64, old decimal.Decimal, price decimal.Decimal, reason string, userID int64, scheduledID int64) (PriceChange, error) {
+	change := PriceChange{ProductID: productID, OldPrice: old, NewPrice: price, Reason: reason}
+	if old.Equal(price) {
+		return change, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET price = $1, updated_at = NOW() WHERE id = $2", price, productID); err != nil {
+		return PriceChange{}, mapError(err)
+	}
+	query := `INSERT INTO product_prices (product_id, old_price, new_price, reason, changed_by, scheduled_price_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0))
+		RETURNING ` + priceChangeColumns
+	change, err := scanPriceChange(tx.QueryRowContext(ctx, query, productID, old, price, reason, userID, scheduledID))
+	return change, mapError(err)
+}
+
+func (s *PostgresPriceStore) ListPriceHistory(ctx context.Context, productID int64) ([]PriceChange, error) {
+	exists, err := productExists(ctx, s.DB, productID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	query := "SELECT " + priceChangeColumns + " FROM product_prices WHERE product_id = $1 ORDER BY created_at, id"
+	rows, err := s.DB.QueryContext(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []PriceChange{}
+	for rows.Next() {
+		change, err := scanPriceChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (s *PostgresPriceStore) SchedulePrice(ctx context.Context, scheduled *ScheduledPrice) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the product lock keeps two overlapping promotions from being scheduled at once
+	if _, err := lockPrice(ctx, tx, scheduled.ProductID); err != nil {
+		return err
+	}
+	if scheduled.EndsAt != nil {
+		var overlaps bool
+		query := `SELECT EXISTS (SELECT 1 FROM scheduled_prices
+			WHERE product_id = $1 AND status IN ('pending', 'active') AND ends_at IS NOT NULL AND starts_at < $3 AND ends_at > $2)`
+		if err := tx.QueryRowContext(ctx, query, scheduled.ProductID, scheduled.StartsAt, *scheduled.EndsAt).Scan(&overlaps); err != nil {
+			return err
+		}
+		if overlaps {
+			return fmt.Errorf("%w: product %d already has a promotion in that time", ErrInvalidState, scheduled.ProductID)
+		}
+	}
+
+	query := "INSERT INTO scheduled_prices (product_id, price, starts_at, ends_at, created_by) VALUES ($1, $2, $3, $4, $5) RETURNING " + scheduledPriceColumns
+	inserted, err := scanScheduledPrice(tx.QueryRowContext(ctx, query, scheduled.ProductID, scheduled.Price, scheduled.StartsAt, scheduled.EndsAt, scheduled.CreatedBy))
+	if err != nil {
+		return mapError(err)
+	}
+	*scheduled = inserted
+	return tx.Commit()
+}
+
+func (s *PostgresPriceStore) ListScheduledPrices(ctx context.Context, productID int64) ([]ScheduledPrice, error) {
+	exists, err := productExists(ctx, s.DB, productID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return queryScheduledPrices(ctx, s.DB, "SELECT "+scheduledPriceColumns+" FROM scheduled_prices WHERE product_id = $1 ORDER BY starts_at, id", productID)
+}
+
+func queryScheduledPrices(ctx context.Context, q querier, query string, args ...any) ([]ScheduledPrice, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules := []ScheduledPrice{}
+	for rows.Next() {
+		scheduled, err := scanScheduledPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, scheduled)
+	}
+	return schedules, rows.Err()
+}
+
+func (s *PostgresPriceStore) CancelScheduledPrice(ctx context.Context, productID int64, id int64) (ScheduledPrice, error) {
+	query := "UPDATE scheduled_prices SET status = $1, updated_at = NOW() WHERE id = $2 AND product_id = $3 AND status = $4 RETURNING " + scheduledPriceColumns
+	scheduled, err := scanScheduledPrice(s.DB.QueryRowContext(ctx, query, ScheduledPriceCancelled, id, productID, ScheduledPricePending))
+	if err != sql.ErrNoRows {
+		return scheduled, mapError(err)
+	}
+
+	// missing, or already applied
+	var status string
+	err = s.DB.QueryRowContext(ctx, "SELECT status FROM scheduled_prices WHERE id = $1 AND product_id = $2", id, productID).Scan(&status)
+	if err != nil {
+		return ScheduledPrice{}, mapError(err)
+	}
+	return ScheduledPrice{}, fmt.Errorf("%w: scheduled price %d is %s", ErrInvalidState, id, status)
+}
+
+// every schedule runs in a savepoint, so one that fails is undone and left for the next run
+// while the others still apply
+func (s *PostgresPriceStore) ApplyDuePrices(ctx context.Context, now time.Time) ([]PriceChange, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets several instances run the scheduler without applying a schedule twice
+	due := dueSchedules{tx: tx, changes: []PriceChange{}}
+	starting, err := queryScheduledPrices(ctx, tx, "SELECT "+scheduledPriceColumns+" FROM scheduled_prices WHERE status = $1 AND starts_at <= $2 ORDER BY starts_at, id FOR UPDATE SKIP LOCKED", ScheduledPricePending, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, scheduled := range starting {
+		if err := due.apply(ctx, scheduled, startScheduleTx); err != nil {
+			return nil, err
+		}
+	}
+
+	ending, err := queryScheduledPrices(ctx, tx, "SELECT "+scheduledPriceColumns+" FROM scheduled_prices WHERE status = $1 AND ends_at <= $2 ORDER BY ends_at, id FOR UPDATE SKIP LOCKED", ScheduledPriceActive, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, scheduled := range ending {
+		if err := due.apply(ctx, scheduled, endScheduleTx); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return due.changes, errors.Join(due.failed...)
+}
+
+// the changes of one ApplyDuePrices run and the schedules that failed
+type dueSchedules struct {
+	tx      *sql.Tx
+	changes []PriceChange
+	failed  []error
+}
+
+// run one schedule in a savepoint. A failed schedule is rolled back to it and kept in failed,
+// only an error that breaks the transaction is returned.
+func (d *dueSchedules) apply(ctx context.Context, scheduled ScheduledPrice, run func(context.Context, *sql.Tx, ScheduledPrice) (PriceChange, error)) error {
+	if _, err := d.tx.ExecContext(ctx, "SAVEPOINT scheduled_price"); err != nil {
+		return err
+	}
+	change, err := run(ctx, d.tx, scheduled)
+	statement := "RELEASE SAVEPOINT scheduled_price"
+	switch {
+	case err != nil:
+		statement = "ROLLBACK TO SAVEPOINT scheduled_price"
+		d.failed = append(d.failed, fmt.Errorf("scheduled price %d of product %d: %w", scheduled.ID, scheduled.ProductID, err))
+	case change.ID != 0:
+		d.changes = append(d.changes, change)
+	}
+	_, err = d.tx.ExecContext(ctx, statement)
+	return err
+}
+
+// set the price of a due schedule, a promotion stays active until it ends
+func startScheduleTx(ctx context.Context, tx *sql.Tx, scheduled ScheduledPrice) (PriceChange, error) {
+	reason, status := PriceReasonScheduled, ScheduledPriceDone
+	if scheduled.EndsAt != nil {
+		reason, status = PriceReasonPromotion, ScheduledPriceActive
+	}
+	change, err := setPriceTx(ctx, tx, scheduled.ProductID, scheduled.Price, reason, 0, scheduled.ID)
+	if errors.Is(err, ErrNotFound) {
+		return cancelScheduleTx(ctx, tx, scheduled)
+	}
+	if err != nil {
+		return PriceChange{}, err
+	}
+
+	revert := decimal.NullDecimal{}
+	if scheduled.EndsAt != nil {
+		revert = decimal.NewNullDecimal(change.OldPrice)
+	}
+	query := "UPDATE scheduled_prices SET status = $1, revert_price = $2, updated_at = NOW() WHERE id = $3"
+	if _, err := tx.ExecContext(ctx, query, status, revert, scheduled.ID); err != nil {
+		return PriceChange{}, err
+	}
+	return change, nil
+}
+
+// bring back the price from before a promotion that ran out
+func endScheduleTx(ctx context.Context, tx *sql.Tx, scheduled ScheduledPrice) (PriceChange, error) {
+	current, err := lockPrice(ctx, tx, scheduled.ProductID)
+	if errors.Is(err, ErrNotFound) {
+		return cancelScheduleTx(ctx, tx, scheduled)
+	}
+	if err != nil {
+		return PriceChange{}, err
+	}
+	// a price set during the promotion wins over the old one
+	var change PriceChange
+	if current.Equal(scheduled.Price) && scheduled.RevertPrice.Valid {
+		change, err = writePriceTx(ctx, tx, scheduled.ProductID, current, scheduled.RevertPrice.Decimal, PriceReasonPromotionEnd, 0, scheduled.ID)
+		if err != nil {
+			return PriceChange{}, err
+		}
+	}
+	query := "UPDATE scheduled_prices SET status = $1, updated_at = NOW() WHERE id = $2"
+	if _, err := tx.ExecContext(ctx, query, ScheduledPriceDone, scheduled.ID); err != nil {
+		return PriceChange{}, err
+	}
+	return change, nil
+}
+
+// purging a product takes its schedules along, so a product that is gone was deleted.
+// Its price is left alone and the schedule cancelled, nothing is written to the price history.
+func cancelScheduleTx(ctx context.Context, tx *sql.Tx, scheduled ScheduledPrice) (PriceChange, error) {
+	query := "UPDATE scheduled_prices SET status = $1, updated_at = NOW() WHERE id = $2"
+	_, err := tx.ExecContext(ctx, query, ScheduledPriceCancelled, scheduled.ID)
+	return PriceChange{}, err
+}
@@ -0,0 +1,236 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var priceChangeRowColumns = []string{"id", "product_id", "old_price", "new_price", "reason", "changed_by", "scheduled_price_id", "created_at"}
+
+var scheduledPriceRowColumns = []string{"id", "product_id", "price", "starts_at", "ends_at", "status", "revert_price", "created_by", "created_at"}
+
+func TestPostgresListPriceHistory(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	rows := sqlmock.NewRows(priceChangeRowColumns).
+		AddRow(1, 1, "10.00", "12.00", PriceReasonManual, 7, nil, "2024-09-01").
+		AddRow(2, 1, "12.00", "9.00", PriceReasonPromotion, nil, 3, "2024-09-02")
+	mock.ExpectQuery("SELECT (.+) FROM product_prices WHERE product_id = \\$1 ORDER BY created_at, id").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	store := &PostgresPriceStore{DB: db}
+	changes, err := store.ListPriceHistory(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.EqualValues(t, 7, *changes[0].UserID)
+	assert.Nil(t, changes[1].UserID)
+	assert.EqualValues(t, 3, *changes[1].ScheduledPriceID)
+
+	_, err = store.ListPriceHistory(context.Background(), 9)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresSchedulePromotionOverlap(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	startsAt := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(48 * time.Hour)
+
+	//mock queries
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("10.00"))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM scheduled_prices").WithArgs(1, startsAt, endsAt).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	store := &PostgresPriceStore{DB: db}
+	scheduled := ScheduledPrice{ProductID: 1, Price: decimal.NewFromInt(8), StartsAt: startsAt, EndsAt: &endsAt}
+	assert.ErrorIs(t, store.SchedulePrice(context.Background(), &scheduled), ErrInvalidState)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresCancelScheduledPrice(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, an active promotion cannot be cancelled
+	mock.ExpectQuery("UPDATE scheduled_prices SET status = \\$1").WithArgs(ScheduledPriceCancelled, 3, 1, ScheduledPricePending).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns))
+	mock.ExpectQuery("SELECT status FROM scheduled_prices").WithArgs(3, 1).WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(ScheduledPriceActive))
+
+	store := &PostgresPriceStore{DB: db}
+	_, err = store.CancelScheduledPrice(context.Background(), 1, 3)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresApplyDuePrices(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+	endsAt := now.Add(time.Hour)
+
+	//mock queries: a promotion starts on product 1, one ends on product 2
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM scheduled_prices WHERE status = \\$1 AND starts_at <= \\$2 (.+) FOR UPDATE SKIP LOCKED").WithArgs(ScheduledPricePending, now).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns).AddRow(3, 1, "8.00", now, endsAt, ScheduledPricePending, nil, 7, "2024-11-01"))
+	mock.ExpectExec("SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("10.00"))
+	mock.ExpectExec("UPDATE products SET price = \\$1").WithArgs(decimal.RequireFromString("8.00"), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO product_prices").WithArgs(1, decimal.RequireFromString("10.00"), decimal.RequireFromString("8.00"), PriceReasonPromotion, 0, 3).
+		WillReturnRows(sqlmock.NewRows(priceChangeRowColumns).AddRow(10, 1, "10.00", "8.00", PriceReasonPromotion, nil, 3, "2024-12-01"))
+	mock.ExpectExec("UPDATE scheduled_prices SET status = \\$1, revert_price = \\$2").
+		WithArgs(ScheduledPriceActive, decimal.NewNullDecimal(decimal.RequireFromString("10.00")), 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	mock.ExpectQuery("FROM scheduled_prices WHERE status = \\$1 AND ends_at <= \\$2 (.+) FOR UPDATE SKIP LOCKED").WithArgs(ScheduledPriceActive, now).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns).AddRow(2, 2, "4.00", now.Add(-time.Hour), now, ScheduledPriceActive, "5.00", nil, "2024-11-01"))
+	mock.ExpectExec("SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("4.00"))
+	mock.ExpectExec("UPDATE products SET price = \\$1").WithArgs(decimal.RequireFromString("5.00"), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO product_prices").WithArgs(2, decimal.RequireFromString("4.00"), decimal.RequireFromString("5.00"), PriceReasonPromotionEnd, 0, 2).
+		WillReturnRows(sqlmock.NewRows(priceChangeRowColumns).AddRow(11, 2, "4.00", "5.00", PriceReasonPromotionEnd, nil, 2, "2024-12-01"))
+	mock.ExpectExec("UPDATE scheduled_prices SET status = \\$1, updated_at").WithArgs(ScheduledPriceDone, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	store := &PostgresPriceStore{DB: db}
+	changes, err := store.ApplyDuePrices(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, PriceReasonPromotion, changes[0].Reason)
+	assert.Equal(t, PriceReasonPromotionEnd, changes[1].Reason)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresApplyDuePricesSkipsFailedSchedule(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	//mock queries: the schedule of product 1 fails and is rolled back, product 2 still gets its price
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM scheduled_prices WHERE status = \\$1 AND starts_at <= \\$2").WithArgs(ScheduledPricePending, now).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns).
+			AddRow(3, 1, "8.00", now, nil, ScheduledPricePending, nil, 7, "2024-11-01").
+			AddRow(4, 2, "6.00", now, nil, ScheduledPricePending, nil, 7, "2024-11-01"))
+	mock.ExpectExec("SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("10.00"))
+	mock.ExpectExec("UPDATE products SET price = \\$1").WithArgs(decimal.RequireFromString("8.00"), 1).WillReturnError(errors.New("canceling statement due to lock timeout"))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("5.00"))
+	mock.ExpectExec("UPDATE products SET price = \\$1").WithArgs(decimal.RequireFromString("6.00"), 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO product_prices").WithArgs(2, decimal.RequireFromString("5.00"), decimal.RequireFromString("6.00"), PriceReasonScheduled, 0, 4).
+		WillReturnRows(sqlmock.NewRows(priceChangeRowColumns).AddRow(10, 2, "5.00", "6.00", PriceReasonScheduled, nil, 4, "2024-12-01"))
+	mock.ExpectExec("UPDATE scheduled_prices SET status = \\$1, revert_price = \\$2").
+		WithArgs(ScheduledPriceDone, decimal.NullDecimal{}, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM scheduled_prices WHERE status = \\$1 AND ends_at <= \\$2").WithArgs(ScheduledPriceActive, now).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns))
+	mock.ExpectCommit()
+
+	store := &PostgresPriceStore{DB: db}
+	changes, err := store.ApplyDuePrices(context.Background(), now)
+	assert.ErrorContains(t, err, "scheduled price 3 of product 1")
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, int64(2), changes[0].ProductID)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresApplyDuePricesCancelsDeletedProduct(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	//mock queries: product 1 was deleted, its schedules are cancelled and its price left alone
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM scheduled_prices WHERE status = \\$1 AND starts_at <= \\$2").WithArgs(ScheduledPricePending, now).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns).AddRow(3, 1, "8.00", now, nil, ScheduledPricePending, nil, 7, "2024-11-01"))
+	mock.ExpectExec("SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("UPDATE scheduled_prices SET status = \\$1, updated_at").WithArgs(ScheduledPriceCancelled, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM scheduled_prices WHERE status = \\$1 AND ends_at <= \\$2").WithArgs(ScheduledPriceActive, now).
+		WillReturnRows(sqlmock.NewRows(scheduledPriceRowColumns).AddRow(2, 1, "4.00", now.Add(-time.Hour), now, ScheduledPriceActive, "5.00", nil, "2024-11-01"))
+	mock.ExpectExec("SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("UPDATE scheduled_prices SET status = \\$1, updated_at").WithArgs(ScheduledPriceCancelled, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT scheduled_price").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	store := &PostgresPriceStore{DB: db}
+	changes, err := store.ApplyDuePrices(context.Background(), now)
+	assert.NoError(t, err)
+	assert.Empty(t, changes)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// lower case words of a search, punctuation only separates them
+func searchWords(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tsquery matching every word as a prefix, e.g. "red ham" becomes "red:* & ham:*"
+func prefixQuery(words []string) string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = word + ":*"
+	}
+	return strings.Join(terms, " & ")
+}
+
+// the trigrams pg_trgm makes of a word, padded with two spaces in front and one behind
+func trigrams(word string) map[string]bool {
+	runes := []rune("  " + word + " ")
+	set := map[string]bool{}
+	for i := 0; i+3 <= len(runes); i++ {
+		set[string(runes[i:i+3])] = true
+	}
+	return set
+}
+
+// share of trigrams two words have in common, like pg_trgm's similarity
+func trigramSimilarity(a, b string) float64 {
+	first, second := trigrams(a), trigrams(b)
+	shared := 0
+	for trigram := range first {
+		if second[trigram] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(first)+len(second)-shared)
+}
+
+// how close two words must be to count as a typo of each other
+const typoSimilarity = 0.4
+
+// rank a product for the search words the way the Postgres search roughly does.
+// Every word has to start a word of the name (1) or description (0.5), or be a typo of a name word (its similarity).
+func rankProduct(product Product, words []string) (float64, bool) {
+	names, descriptions := searchWords(product.Name), searchWords(product.Description)
+	rank := 0.0
+	for _, word := range words {
+		best := 0.0
+		for _, name := range names {
+			if strings.HasPrefix(name, word) {
+				best = 1
+				break
+			}
+			if similarity := trigramSimilarity(word, name); similarity >= typoSimilarity {
+				best = max(best, similarity)
+			}
+		}
+		for _, description := range descriptions {
+			if strings.HasPrefix(description, word) {
+				best = max(best, 0.5)
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		rank += best
+	}
+	return rank / float64(len(words)), true
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrigramSimilarity(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, 1.0, trigramSimilarity("hammer", "hammer"))
+	assert.Equal(t, 0.0, trigramSimilarity("hammer", "paint"))
+	// 5 of the 8 different trigrams are shared
+	assert.Equal(t, 0.625, trigramSimilarity("hamer", "hammer"))
+}
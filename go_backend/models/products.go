@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+)
+
+type Product struct {
+	ID           int64           `json:"id"`
+	Name         string          `json:"name"`
+	SKU          string          `json:"sku"`     // our own stock keeping unit, optional
+	Barcode      string          `json:"barcode"` // EAN-13 digits, optional, see NormalizeBarcode
+	Description  string          `json:"description"`
+	SupplierID   int64           `json:"supplier_id"`
+	Price        decimal.Decimal `json:"price"`
+	Cost         decimal.Decimal `json:"cost"` // what one unit costs us, used to value the stock
+	Stock        int             `json:"stock"`
+	MinimumStock int             `json:"minimum_stock"`
+	CreatedAt    string          `json:"created_at"`
+	UpdatedAt    string          `json:"updated_at"`
+	Version      int64           `json:"version"`    // bumped by every change, the ETag of the API
+	DeletedAt    string          `json:"deleted_at"` // empty unless deleted, see RestoreProduct and PurgeProduct
+}
+
+// ProductFilter narrows, sorts and pages ListProducts, zero values do not filter
+type ProductFilter struct {
+	SupplierID     int64
+	MinPrice       decimal.NullDecimal
+	MaxPrice       decimal.NullDecimal
+	BelowMinimum   bool // only products at or below their minimum stock
+	IncludeDeleted bool
+	Sort           Sort
+	Page           Page
+}
+
+// ProductPatch changes the fields that are not nil, an empty SKU, Barcode or Description removes it.
+// The stock is left to the StockStore so every change reaches the ledger.
+type ProductPatch struct {
+	Name         *string
+	SKU          *string
+	Barcode      *string
+	Description  *string
+	SupplierID   *int64
+	Price        *decimal.Decimal
+	Cost         *decimal.Decimal
+	MinimumStock *int
+}
+
+// ProductMatch is a product found by SearchProducts, better matches have a higher Rank
+type ProductMatch struct {
+	Product
+	Rank float64 `json:"rank"`
+}
+
+// ProductStore persists products
+type ProductStore interface {
+	// CreateProduct inserts the product and sets its ID
+	CreateProduct(ctx context.Context, product *Product) error
+	// GetProduct and GetProductByBarcode also return deleted products, check DeletedAt
+	GetProduct(ctx context.Context, id int64) (Product, error)
+	// GetProductByBarcode takes the 13 digits returned by NormalizeBarcode
+	GetProductByBarcode(ctx context.Context, barcode string) (Product, error)
+	// ListProducts returns one page of the matching products and how many match in total
+	ListProducts(ctx context.Context, filter ProductFilter) ([]Product, int, error)
+	// SearchProducts finds up to limit products by words or parts of words in their name and description, best first.
+	// Deleted products are never found.
+	SearchProducts(ctx context.Context, query string, limit int) ([]ProductMatch, error)
+	// The changes below return ErrVersionMismatch when the product is no longer at version, unless it is AnyVersion,
+	// and ErrNotFound for deleted products.
+	// PatchProduct applies the patch and returns the updated product, a new price goes into the price history
+	PatchProduct(ctx context.Context, id int64, version int64, patch ProductPatch, userID int64) (Product, error)
+	// UpdateProductPrice records the change in the price history
+	UpdateProductPrice(ctx context.Context, id int64, version int64, price decimal.Decimal, userID int64) error
+	UpdateProductCost(ctx context.Context, id int64, version int64, cost decimal.Decimal) error
+	// UpdateProductSKU and UpdateProductBarcode remove the code when it is empty
+	UpdateProductSKU(ctx context.Context, id int64, version int64, sku string) error
+	UpdateProductBarcode(ctx context.Context, id int64, version int64, barcode string) error
+	// DeleteProduct hides the product, which keeps its name, SKU and barcode until it is purged.
+	// ErrInUse while stock is reserved for confirmed sales orders or still due on open purchase orders.
+	DeleteProduct(ctx context.Context, id int64, version int64) error
+	// RestoreProduct undoes DeleteProduct, ErrInvalidState if the product is not deleted
+	RestoreProduct(ctx context.Context, id int64) (Product, error)
+	// PurgeProduct removes a deleted product for good, ErrInvalidState if it is not deleted and ErrInUse while orders refer to it
+	PurgeProduct(ctx context.Context, id int64, version int64) error
+	// ImportProducts creates or patches the product of every row in one transaction, which is
+	// only committed when no row fails and it is no dry run. Failed rows are in the result.
+	ImportProducts(ctx context.Context, rows []ProductImport, dryRun bool, userID int64) (ImportResult, error)
+}
@@ -0,0 +1,344 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/shopspring/decimal"
+)
+
+// caller holds the lock
+func (m *MemoryStore) checkProduct(product Product) error {
+	for _, existing := range m.products {
+		if existing.ID == product.ID {
+			continue
+		}
+		if existing.Name == product.Name {
+			return fmt.Errorf("%w: Key (name)=(%s) already exists.", ErrDuplicate, product.Name)
+		}
+		if product.SKU != "" && existing.SKU == product.SKU {
+			return fmt.Errorf("%w: Key (sku)=(%s) already exists.", ErrDuplicate, product.SKU)
+		}
+		if product.Barcode != "" && existing.Barcode == product.Barcode {
+			return fmt.Errorf("%w: Key (barcode)=(%s) already exists.", ErrDuplicate, product.Barcode)
+		}
+	}
+	supplier, ok := m.suppliers[product.SupplierID]
+	if !ok {
+		return fmt.Errorf("%w: Key (supplier_id)=(%d) is not present in table \"supplier\".", ErrInvalidReference, product.SupplierID)
+	}
+	if supplier.DeletedAt != "" {
+		return fmt.Errorf("%w: supplier %d does not exist or is deleted", ErrInvalidReference, product.SupplierID)
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateProduct(ctx context.Context, product *Product) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createProductLocked(product)
+}
+
+// caller holds the write lock
+func (m *MemoryStore) createProductLocked(product *Product) error {
+	if err := m.checkProduct(*product); err != nil {
+		return err
+	}
+
+	m.lastProductID++
+	product.ID = m.lastProductID
+	product.CreatedAt = memoryNow()
+	product.UpdatedAt = product.CreatedAt
+	product.Version = 1
+	m.products[product.ID] = *product
+	if product.Stock != 0 {
+		m.recordMovement(product.ID, product.Stock, openingBalance)
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetProduct(ctx context.Context, id int64) (Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	product, ok := m.products[id]
+	if !ok {
+		return Product{}, ErrNotFound
+	}
+	return product, nil
+}
+
+func (m *MemoryStore) GetProductByBarcode(ctx context.Context, barcode string) (Product, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, product := range m.products {
+		if product.Barcode != "" && product.Barcode == barcode {
+			return product, nil
+		}
+	}
+	return Product{}, ErrNotFound
+}
+
+func (m *MemoryStore) ListProducts(ctx context.Context, filter ProductFilter) ([]Product, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	products := []Product{}
+	for _, product := range sortedByID(m.products) {
+		if product.DeletedAt != "" && !filter.IncludeDeleted {
+			continue
+		}
+		if filter.SupplierID != 0 && product.SupplierID != filter.SupplierID {
+			continue
+		}
+		if filter.MinPrice.Valid && product.Price.LessThan(filter.MinPrice.Decimal) {
+			continue
+		}
+		if filter.MaxPrice.Valid && product.Price.GreaterThan(filter.MaxPrice.Decimal) {
+			continue
+		}
+		if filter.BelowMinimum && product.Stock > product.MinimumStock {
+			continue
+		}
+		products = append(products, product)
+	}
+
+	sortItems(products, filter.Sort, func(a, b Product) int {
+		switch filter.Sort.Key {
+		case "name":
+			return cmp.Compare(a.Name, b.Name)
+		case "price":
+			return a.Price.Cmp(b.Price)
+		case "stock":
+			return cmp.Compare(a.Stock, b.Stock)
+		case "created_at":
+			return cmp.Compare(a.CreatedAt, b.CreatedAt)
+		}
+		return 0
+	})
+	return paginate(products, filter.Page), len(products), nil
+}
+
+func (m *MemoryStore) SearchProducts(ctx context.Context, query string, limit int) ([]ProductMatch, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	matches := []ProductMatch{}
+	words := searchWords(query)
+	if len(words) == 0 {
+		return matches, nil
+	}
+	for _, product := range sortedByID(m.products) {
+		if product.DeletedAt != "" {
+			continue
+		}
+		if rank, ok := rankProduct(product, words); ok {
+			matches = append(matches, ProductMatch{Product: product, Rank: rank})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b ProductMatch) int { return cmp.Compare(b.Rank, a.Rank) })
+	return matches[:min(limit, len(matches))], nil
+}
+
+// apply change to the product at version under the write lock, nothing is saved if change fails
+func (m *MemoryStore) updateProduct(id int64, version int64, change func(product *Product) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateProductLocked(id, version, change)
+}
+
+// a change of products.cost, kept to value stock on an earlier day
+type costChange struct {
+	ProductID int64
+	OldCost   decimal.Decimal
+	NewCost   decimal.Decimal
+	CreatedAt string
+}
+
+// caller holds the write lock
+func (m *MemoryStore) updateProductLocked(id int64, version int64, change func(product *Product) error) error {
+	product, ok := m.products[id]
+	if !ok || product.DeletedAt != "" {
+		return ErrNotFound
+	}
+	if err := checkVersion("products", id, product.Version, version); err != nil {
+		return err
+	}
+	old := product.Cost
+	if err := change(&product); err != nil {
+		return err
+	}
+	// trg_products_cost in postgres
+	if !old.Equal(product.Cost) {
+		m.costHistory = append(m.costHistory, costChange{ProductID: id, OldCost: old, NewCost: product.Cost, CreatedAt: memoryNow()})
+	}
+	product.UpdatedAt = memoryNow()
+	product.Version++
+	m.products[id] = product
+	return nil
+}
+
+func (m *MemoryStore) PatchProduct(ctx context.Context, id int64, version int64, patch ProductPatch, userID int64) (Product, error) {
+	err := m.updateProduct(id, version, m.patchProductChange(patch, userID))
+	if err != nil {
+		return Product{}, err
+	}
+	return m.GetProduct(ctx, id)
+}
+
+// the change of updateProduct that applies patch
+func (m *MemoryStore) patchProductChange(patch ProductPatch, userID int64) func(product *Product) error {
+	return func(product *Product) error {
+		if patch.Name != nil {
+			product.Name = *patch.Name
+		}
+		if patch.SKU != nil {
+			product.SKU = *patch.SKU
+		}
+		if patch.Barcode != nil {
+			product.Barcode = *patch.Barcode
+		}
+		if patch.Description != nil {
+			product.Description = *patch.Description
+		}
+		if patch.SupplierID != nil {
+			product.SupplierID = *patch.SupplierID
+		}
+		if patch.Cost != nil {
+			product.Cost = *patch.Cost
+		}
+		if patch.MinimumStock != nil {
+			product.MinimumStock = *patch.MinimumStock
+		}
+		if err := m.checkProduct(*product); err != nil {
+			return err
+		}
+		if patch.Price != nil {
+			m.setPriceLocked(product, *patch.Price, PriceReasonManual, userID, 0)
+		}
+		return nil
+	}
+}
+
+func (m *MemoryStore) UpdateProductPrice(ctx context.Context, id int64, version int64, price decimal.Decimal, userID int64) error {
+	return m.updateProduct(id, version, func(product *Product) error {
+		m.setPriceLocked(product, price, PriceReasonManual, userID, 0)
+		return nil
+	})
+}
+
+func (m *MemoryStore) UpdateProductCost(ctx context.Context, id int64, version int64, cost decimal.Decimal) error {
+	return m.updateProduct(id, version, func(product *Product) error {
+		product.Cost = cost
+		return nil
+	})
+}
+
+func (m *MemoryStore) UpdateProductSKU(ctx context.Context, id int64, version int64, sku string) error {
+	return m.updateProduct(id, version, func(product *Product) error {
+		product.SKU = sku
+		return m.checkProduct(*product)
+	})
+}
+
+func (m *MemoryStore) UpdateProductBarcode(ctx context.Context, id int64, version int64, barcode string) error {
+	return m.updateProduct(id, version, func(product *Product) error {
+		product.Barcode = barcode
+		return m.checkProduct(*product)
+	})
+}
+
+func (m *MemoryStore) DeleteProduct(ctx context.Context, id int64, version int64) error {
+	return m.updateProduct(id, version, func(product *Product) error {
+		if m.onOpenOrdersLocked(product.ID) {
+			return fmt.Errorf("%w: product %d has stock reserved or on open purchase orders", ErrInUse, product.ID)
+		}
+		product.DeletedAt = memoryNow()
+		return nil
+	})
+}
+
+// the memory version of checkNoOpenOrders, caller holds the lock
+func (m *MemoryStore) onOpenOrdersLocked(productID int64) bool {
+	if m.reserved[productID] > 0 {
+		return true
+	}
+	for _, order := range m.purchaseOrders {
+		if order.Status != POStatusDraft && order.Status != POStatusSubmitted && order.Status != POStatusPartiallyReceived {
+			continue
+		}
+		for _, line := range order.Lines {
+			if line.ProductID == productID && line.QuantityReceived < line.QuantityOrdered {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *MemoryStore) RestoreProduct(ctx context.Context, id int64) (Product, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.products[id]
+	if !ok {
+		return Product{}, ErrNotFound
+	}
+	if err := checkDeleted("products", id, product.DeletedAt, product.Version, AnyVersion); err != nil {
+		return Product{}, err
+	}
+	// a product of a deleted supplier comes back after its supplier
+	if err := m.checkProduct(product); err != nil {
+		return Product{}, err
+	}
+	product.DeletedAt = ""
+	product.UpdatedAt = memoryNow()
+	product.Version++
+	m.products[id] = product
+	return product, nil
+}
+
+func (m *MemoryStore) PurgeProduct(ctx context.Context, id int64, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.products[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := checkDeleted("products", id, product.DeletedAt, product.Version, version); err != nil {
+		return err
+	}
+	// fk_product on purchase_order_lines
+	for _, order := range m.purchaseOrders {
+		for _, line := range order.Lines {
+			if line.ProductID == id {
+				return fmt.Errorf("%w: Key (id)=(%d) is still referenced from table \"purchase_order_lines\".", ErrInUse, id)
+			}
+		}
+	}
+	// fk_product on sales_order_lines
+	for _, order := range m.salesOrders {
+		for _, line := range order.Lines {
+			if line.ProductID == id {
+				return fmt.Errorf("%w: Key (id)=(%d) is still referenced from table \"sales_order_lines\".", ErrInUse, id)
+			}
+		}
+	}
+	delete(m.products, id)
+	delete(m.reserved, id)
+	// ON DELETE CASCADE in postgres
+	m.priceHistory = slices.DeleteFunc(m.priceHistory, func(change PriceChange) bool { return change.ProductID == id })
+	m.costHistory = slices.DeleteFunc(m.costHistory, func(change costChange) bool { return change.ProductID == id })
+	maps.DeleteFunc(m.scheduledPrices, func(_ int64, scheduled ScheduledPrice) bool { return scheduled.ProductID == id })
+	m.movements = slices.DeleteFunc(m.movements, func(movement StockMovement) bool {
+		return movement.ProductID == id
+	})
+	return nil
+}
@@ -0,0 +1,391 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// PostgresProductStore implements ProductStore on the products table
+type PostgresProductStore struct {
+	DB *sql.DB
+}
+
+const productColumns = "id, name, COALESCE(sku, ''), COALESCE(barcode, ''), COALESCE(description, ''), supplier_id, price, cost, stock, minimum_stock, created_at, updated_at, version, deleted_at"
+
+// anything with Scan, *sql.Row or *sql.Rows
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// extra receives columns selected after productColumns
+func scanProduct(row scanner, extra ...any) (Product, error) {
+	var (
+		product   Product
+		deletedAt sql.NullString
+	)
+	dest := []any{&product.ID, &product.Name, &product.SKU, &product.Barcode, &product.Description, &product.SupplierID, &product.Price, &product.Cost, &product.Stock, &product.MinimumStock, &product.CreatedAt, &product.UpdatedAt, &product.Version, &deletedAt}
+	err := row.Scan(append(dest, extra...)...)
+	product.DeletedAt = deletedAt.String
+	return product, err
+}
+
+// ErrInUse for the first product matching condition that confirmed sales orders or open purchase orders
+// still wait for, once deleted its stock could no longer be fulfilled or received
+func checkNoOpenOrders(ctx context.Context, tx *sql.Tx, condition string, arg any) error {
+	query := `SELECT p.id FROM products p
+		WHERE ` + condition + ` AND p.deleted_at IS NULL AND (p.reserved > 0 OR EXISTS (
+			SELECT 1 FROM purchase_order_lines l JOIN purchase_orders o ON o.id = l.purchase_order_id
+			WHERE l.product_id = p.id AND o.status IN ('draft', 'submitted', 'partially_received') AND l.quantity_received < l.quantity_ordered))
+		ORDER BY p.id LIMIT 1`
+	var id int64
+	err := tx.QueryRowContext(ctx, query, arg).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: product %d has stock reserved or on open purchase orders", ErrInUse, id)
+}
+
+// the supplier of a product must not be deleted, the lock keeps it that way until tx ends
+func lockActiveSupplier(ctx context.Context, tx *sql.Tx, supplierID int64) error {
+	var deleted bool
+	err := tx.QueryRowContext(ctx, "SELECT deleted_at IS NOT NULL FROM supplier WHERE id = $1 FOR SHARE", supplierID).Scan(&deleted)
+	if errors.Is(err, sql.ErrNoRows) || deleted {
+		return fmt.Errorf("%w: supplier %d does not exist or is deleted", ErrInvalidReference, supplierID)
+	}
+	return err
+}
+
+func (s *PostgresProductStore) CreateProduct(ctx context.Context, product *Product) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createProductTx(ctx, tx, product); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func createProductTx(ctx context.Context, tx *sql.Tx, product *Product) error {
+	if err := lockActiveSupplier(ctx, tx, product.SupplierID); err != nil {
+		return err
+	}
+	query := "INSERT INTO products (name, sku, barcode, description, supplier_id, price, cost, stock, minimum_stock) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5, $6, $7, $8, $9) RETURNING id"
+	err := tx.QueryRowContext(ctx, query, product.Name, product.SKU, product.Barcode, product.Description, product.SupplierID, product.Price, product.Cost, product.Stock, product.MinimumStock).Scan(&product.ID)
+	if err != nil {
+		return mapError(err)
+	}
+
+	// the starting stock is the first ledger entry
+	if product.Stock != 0 {
+		return insertMovement(ctx, tx, product.ID, product.Stock, openingBalance)
+	}
+	return nil
+}
+
+func (s *PostgresProductStore) GetProduct(ctx context.Context, id int64) (Product, error) {
+	query := "SELECT " + productColumns + " FROM products WHERE id = $1"
+	product, err := scanProduct(s.DB.QueryRowContext(ctx, query, id))
+	return product, mapError(err)
+}
+
+func (s *PostgresProductStore) GetProductByBarcode(ctx context.Context, barcode string) (Product, error) {
+	query := "SELECT " + productColumns + " FROM products WHERE barcode = $1"
+	product, err := scanProduct(s.DB.QueryRowContext(ctx, query, barcode))
+	return product, mapError(err)
+}
+
+func (s *PostgresProductStore) ListProducts(ctx context.Context, filter ProductFilter) ([]Product, int, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	if filter.IncludeDeleted {
+		conditions = []string{"TRUE"}
+	}
+	args := []any{}
+	if filter.SupplierID != 0 {
+		args = append(args, filter.SupplierID)
+		conditions = append(conditions, fmt.Sprintf("supplier_id = $%d", len(args)))
+	}
+	if filter.MinPrice.Valid {
+		args = append(args, filter.MinPrice.Decimal)
+		conditions = append(conditions, fmt.Sprintf("price >= $%d", len(args)))
+	}
+	if filter.MaxPrice.Valid {
+		args = append(args, filter.MaxPrice.Decimal)
+		conditions = append(conditions, fmt.Sprintf("price <= $%d", len(args)))
+	}
+	if filter.BelowMinimum {
+		conditions = append(conditions, "stock <= minimum_stock")
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM products"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page, args := filter.Page.limitOffset(args)
+	query := "SELECT " + productColumns + " FROM products" + where + " ORDER BY " + filter.Sort.orderBy(ProductSortKeys) + page
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	products := []Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		products = append(products, product)
+	}
+	return products, total, rows.Err()
+}
+
+// full text matches on the search_vector column, names with a typo are found by trigram similarity
+func (s *PostgresProductStore) SearchProducts(ctx context.Context, query string, limit int) ([]ProductMatch, error) {
+	matches := []ProductMatch{}
+	words := searchWords(query)
+	if len(words) == 0 {
+		return matches, nil
+	}
+
+	search := `SELECT ` + productColumns + `, ts_rank(search_vector, query) + word_similarity($2, name) AS rank
+		FROM products, to_tsquery('english', $1) AS query
+		WHERE (search_vector @@ query OR $2 <% name) AND deleted_at IS NULL
+		ORDER BY rank DESC, id
+		LIMIT $3`
+	rows, err := s.DB.QueryContext(ctx, search, prefixQuery(words), strings.Join(words, " "), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var match ProductMatch
+		if match.Product, err = scanProduct(rows, &match.Rank); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+func (s *PostgresProductStore) PatchProduct(ctx context.Context, id int64, version int64, patch ProductPatch, userID int64) (Product, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Product{}, err
+	}
+	defer tx.Rollback()
+
+	product, err := patchProductTx(ctx, tx, id, version, patch, userID)
+	if err != nil {
+		return Product{}, err
+	}
+	return product, tx.Commit()
+}
+
+func patchProductTx(ctx context.Context, tx *sql.Tx, id int64, version int64, patch ProductPatch, userID int64) (Product, error) {
+	// checked up front, a new price bumps the version before the UPDATE below
+	if err := lockVersion(ctx, tx, "products", id, version); err != nil {
+		return Product{}, err
+	}
+	if patch.SupplierID != nil {
+		if err := lockActiveSupplier(ctx, tx, *patch.SupplierID); err != nil {
+			return Product{}, err
+		}
+	}
+
+	// a new price goes through the price history, the rest is one UPDATE
+	if patch.Price != nil {
+		if _, err := setPriceTx(ctx, tx, id, *patch.Price, PriceReasonManual, userID, 0); err != nil {
+			return Product{}, err
+		}
+	}
+
+	var set setClause
+	if patch.Name != nil {
+		set.set("name", *patch.Name)
+	}
+	if patch.SKU != nil {
+		set.setOptional("sku", *patch.SKU)
+	}
+	if patch.Barcode != nil {
+		set.setOptional("barcode", *patch.Barcode)
+	}
+	if patch.Description != nil {
+		set.setOptional("description", *patch.Description)
+	}
+	if patch.SupplierID != nil {
+		set.set("supplier_id", *patch.SupplierID)
+	}
+	if patch.Cost != nil {
+		set.set("cost", *patch.Cost)
+	}
+	if patch.MinimumStock != nil {
+		set.set("minimum_stock", *patch.MinimumStock)
+	}
+
+	query, args := set.update("products", id, AnyVersion, productColumns)
+	product, err := scanProduct(tx.QueryRowContext(ctx, query, args...))
+	return product, mapError(err)
+}
+
+func (s *PostgresProductStore) UpdateProductPrice(ctx context.Context, id int64, version int64, price decimal.Decimal, userID int64) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockVersion(ctx, tx, "products", id, version); err != nil {
+		return err
+	}
+
+	if _, err := setPriceTx(ctx, tx, id, price, PriceReasonManual, userID, 0); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresProductStore) UpdateProductCost(ctx context.Context, id int64, version int64, cost decimal.Decimal) error {
+	return execVersioned(ctx, s.DB, "products", id, version, "UPDATE products SET cost = $1, updated_at = NOW() WHERE id = $2", cost)
+}
+
+func (s *PostgresProductStore) UpdateProductSKU(ctx context.Context, id int64, version int64, sku string) error {
+	return s.updateProductCode(ctx, id, version, "sku", sku)
+}
+
+func (s *PostgresProductStore) UpdateProductBarcode(ctx context.Context, id int64, version int64, barcode string) error {
+	return s.updateProductCode(ctx, id, version, "barcode", barcode)
+}
+
+// set one of the optional code columns, empty values are stored as NULL
+func (s *PostgresProductStore) updateProductCode(ctx context.Context, id int64, version int64, column string, value string) error {
+	query := "UPDATE products SET " + column + " = NULLIF($1, ''), updated_at = NOW() WHERE id = $2"
+	return execVersioned(ctx, s.DB, "products", id, version, query, value)
+}
+
+func (s *PostgresProductStore) DeleteProduct(ctx context.Context, id int64, version int64) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the row lock keeps new reservations away until commit
+	if err := lockVersion(ctx, tx, "products", id, version); err != nil {
+		return err
+	}
+	if err := checkNoOpenOrders(ctx, tx, "p.id = $1", id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE products SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1", id); err != nil {
+		return mapError(err)
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresProductStore) RestoreProduct(ctx context.Context, id int64) (Product, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Product{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockDeleted(ctx, tx, "products", id, AnyVersion); err != nil {
+		return Product{}, err
+	}
+	query := "UPDATE products SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 RETURNING " + productColumns
+	product, err := scanProduct(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return Product{}, mapError(err)
+	}
+	// a product of a deleted supplier comes back after its supplier
+	if err := lockActiveSupplier(ctx, tx, product.SupplierID); err != nil {
+		return Product{}, err
+	}
+	return product, tx.Commit()
+}
+
+func (s *PostgresProductStore) PurgeProduct(ctx context.Context, id int64, version int64) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockDeleted(ctx, tx, "products", id, version); err != nil {
+		return err
+	}
+	// still fails for products on purchase or sales orders
+	if _, err := tx.ExecContext(ctx, "DELETE FROM products WHERE id = $1", id); err != nil {
+		return mapDeleteError(err)
+	}
+	return tx.Commit()
+}
+
+// every row runs in a savepoint, so a failed row is undone and the rows after it still get checked
+func (s *PostgresProductStore) ImportProducts(ctx context.Context, rows []ProductImport, dryRun bool, userID int64) (ImportResult, error) {
+	result := newImportResult(dryRun)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return result, err
+		}
+		created, err := importProductTx(ctx, tx, row, userID)
+		if err := result.add(row.Row, created, err); err != nil {
+			return result, err
+		}
+		statement := "RELEASE SAVEPOINT import_row"
+		if err != nil {
+			statement = "ROLLBACK TO SAVEPOINT import_row"
+		}
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return result, err
+		}
+	}
+
+	if !result.commit() {
+		return result, nil
+	}
+	return result, tx.Commit()
+}
+
+// create or patch the product of one row, reports whether it was created
+func importProductTx(ctx context.Context, tx *sql.Tx, row ProductImport, userID int64) (bool, error) {
+	for _, key := range row.keys() {
+		var id int64
+		var deleted bool
+		err := tx.QueryRowContext(ctx, "SELECT id, deleted_at IS NOT NULL FROM products WHERE "+key.column+" = $1 FOR UPDATE", key.value).Scan(&id, &deleted)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		// deleted products keep their name and SKU, the row cannot create another
+		if deleted {
+			return false, fmt.Errorf("%w: product %d is deleted, restore it first", ErrInvalidState, id)
+		}
+		_, err = patchProductTx(ctx, tx, id, AnyVersion, row.Patch, userID)
+		return false, err
+	}
+	product := row.product()
+	return true, createProductTx(ctx, tx, &product)
+}
@@ -0,0 +1,542 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var productRowColumns = []string{"id", "name", "sku", "barcode", "description", "supplier_id", "price", "cost", "stock", "minimum_stock", "created_at", "updated_at", "version", "deleted_at"}
+
+func TestPostgresCreateProduct(t *testing.T) {
+	t.Parallel()
+
+	// Create a new mock database connection and sqlmock instance
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// Define the query we expect to be executed
+	// the supplier must not be deleted
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier WHERE id = \\$1 FOR SHARE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	query := "INSERT INTO products \\(name, sku, barcode, description, supplier_id, price, cost, stock, minimum_stock\\) VALUES \\(\\$1, NULLIF\\(\\$2, ''\\), NULLIF\\(\\$3, ''\\), \\$4, \\$5, \\$6, \\$7, \\$8, \\$9\\) RETURNING id"
+	mock.ExpectQuery(query).WithArgs("testproduct", "TP-1", "4006381333931", "testdescription", 1, decimal.NewFromFloat(123.99), decimal.NewFromFloat(80.5), 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1)) //new row inserted with id 1
+	// starting stock goes into the ledger
+	mock.ExpectExec("INSERT INTO stock_movements").WithArgs(1, 2, ReasonAdjustment, 0, "opening balance", 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	store := &PostgresProductStore{DB: db}
+	product := Product{Name: "testproduct", SKU: "TP-1", Barcode: "4006381333931", Description: "testdescription", SupplierID: 1, Price: decimal.NewFromFloat(123.99), Cost: decimal.NewFromFloat(80.5), Stock: 2, MinimumStock: 3}
+	err = store.CreateProduct(context.Background(), &product)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, product.ID)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresCreateProductDuplicateName(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO products").WillReturnError(&pq.Error{Code: "23505", Detail: "Key (name)=(testproduct) already exists."})
+	mock.ExpectRollback()
+
+	store := &PostgresProductStore{DB: db}
+	err = store.CreateProduct(context.Background(), &Product{Name: "testproduct", SupplierID: 1})
+	assert.ErrorIs(t, err, ErrDuplicate)
+}
+
+func TestPostgresCreateProductDeletedSupplier(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(true))
+	mock.ExpectRollback()
+
+	store := &PostgresProductStore{DB: db}
+	err = store.CreateProduct(context.Background(), &Product{Name: "testproduct", SupplierID: 1})
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresGetProduct(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock query
+	query := "SELECT id, name, COALESCE\\(sku, ''\\), COALESCE\\(barcode, ''\\), COALESCE\\(description, ''\\), supplier_id, price, cost, stock, minimum_stock, created_at, updated_at, version, deleted_at FROM products WHERE id = \\$1"
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(1, "testproduct", "", "", "testdescription", 1, "999", "0", 2, 3, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery(query).WithArgs(1).WillReturnRows(rows)
+
+	store := &PostgresProductStore{DB: db}
+	product, err := store.GetProduct(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, product.ID)
+	assert.Equal(t, "testproduct", product.Name)
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresGetProductNotFound(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE id = \\$1").WithArgs(5).WillReturnRows(sqlmock.NewRows(productRowColumns))
+
+	store := &PostgresProductStore{DB: db}
+	_, err = store.GetProduct(context.Background(), 5)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPostgresListProducts(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(1, "testproduct", "", "", "testdescription", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-08-01", 1, nil).
+		AddRow(2, "otherproduct", "", "", "", 1, "1.50", "0", 0, 1, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM products WHERE deleted_at IS NULL AND supplier_id = \\$1 AND price >= \\$2 AND stock <= minimum_stock").
+		WithArgs(1, decimal.NewFromInt(1)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(7))
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE (.+) ORDER BY price DESC, id DESC LIMIT \\$3 OFFSET \\$4").
+		WithArgs(1, decimal.NewFromInt(1), 2, 4).
+		WillReturnRows(rows)
+
+	store := &PostgresProductStore{DB: db}
+	filter := ProductFilter{
+		SupplierID:   1,
+		MinPrice:     decimal.NewNullDecimal(decimal.NewFromInt(1)),
+		BelowMinimum: true,
+		Sort:         Sort{Key: "price", Descending: true},
+		Page:         Page{Limit: 2, Offset: 4},
+	}
+	products, total, err := store.ListProducts(context.Background(), filter)
+	assert.NoError(t, err)
+	assert.Len(t, products, 2)
+	assert.Equal(t, 7, total)
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresSearchProducts(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock query, punctuation cannot reach to_tsquery
+	rows := sqlmock.NewRows(append(productRowColumns, "rank")).
+		AddRow(1, "Red Hammer", "", "", "", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-08-01", 1, nil, 0.8)
+	mock.ExpectQuery("FROM products, to_tsquery\\('english', \\$1\\) AS query\\s+WHERE \\(search_vector @@ query OR \\$2 <% name\\) AND deleted_at IS NULL").
+		WithArgs("red:* & ham:*", "red ham", 5).
+		WillReturnRows(rows)
+
+	store := &PostgresProductStore{DB: db}
+	matches, err := store.SearchProducts(context.Background(), "red & ham!", 5)
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "Red Hammer", matches[0].Name)
+	assert.Equal(t, 0.8, matches[0].Rank)
+
+	// nothing to search for
+	matches, err = store.SearchProducts(context.Background(), " ! ", 5)
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresPatchProduct(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, the price goes through the history and a deleted product is not found
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier WHERE id = \\$1 FOR SHARE").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("9.99"))
+	mock.ExpectExec("UPDATE products SET price = \\$1").WithArgs(decimal.NewFromInt(3), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO product_prices").WithArgs(1, decimal.RequireFromString("9.99"), decimal.NewFromInt(3), PriceReasonManual, 7, 0).
+		WillReturnRows(sqlmock.NewRows(priceChangeRowColumns).AddRow(1, 1, "9.99", "3", PriceReasonManual, 7, nil, "2024-09-01"))
+	rows := sqlmock.NewRows(productRowColumns).
+		AddRow(1, "testproduct", "", "", "", 2, "3", "0", 2, 3, "2024-08-01", "2024-09-01", 1, nil)
+	mock.ExpectQuery("UPDATE products SET supplier_id = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2 AND deleted_at IS NULL RETURNING id, name").
+		WithArgs(2, 1).WillReturnRows(rows)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(5).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
+
+	store := &PostgresProductStore{DB: db}
+	supplierID, price := int64(2), decimal.NewFromInt(3)
+	product, err := store.PatchProduct(context.Background(), 1, AnyVersion, ProductPatch{SupplierID: &supplierID, Price: &price}, 7)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, product.SupplierID)
+
+	_, err = store.PatchProduct(context.Background(), 5, AnyVersion, ProductPatch{}, 7)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresDeleteProduct(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// mock queries, the row stays behind
+	lock := "SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE"
+	openOrders := "SELECT p.id FROM products p\\s+WHERE p.id = \\$1 AND p.deleted_at IS NULL AND \\(p.reserved > 0 OR EXISTS"
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery(openOrders).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec("UPDATE products SET deleted_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(2).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	// stock reserved for a confirmed sales order or due on an open purchase order
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery(openOrders).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectRollback()
+
+	store := &PostgresProductStore{DB: db}
+	assert.NoError(t, store.DeleteProduct(context.Background(), 1, AnyVersion))
+	assert.ErrorIs(t, store.DeleteProduct(context.Background(), 2, AnyVersion), ErrNotFound)
+	assert.ErrorIs(t, store.DeleteProduct(context.Background(), 3, AnyVersion), ErrInUse)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRestoreAndPurgeProduct(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, a product of a deleted supplier stays deleted
+	lock := "SELECT deleted_at IS NOT NULL, version FROM products WHERE id = \\$1 FOR UPDATE"
+	restore := "UPDATE products SET deleted_at = NULL, updated_at = NOW\\(\\) WHERE id = \\$1 RETURNING"
+	supplier := "SELECT deleted_at IS NOT NULL FROM supplier WHERE id = \\$1 FOR SHARE"
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 2))
+	mock.ExpectQuery(restore).WithArgs(1).WillReturnRows(sqlmock.NewRows(productRowColumns).
+		AddRow(1, "testproduct", "", "", "", 1, "9.99", "0", 2, 3, "2024-08-01", "2024-09-01", 3, nil))
+	mock.ExpectQuery(supplier).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 2))
+	mock.ExpectQuery(restore).WithArgs(2).WillReturnRows(sqlmock.NewRows(productRowColumns).
+		AddRow(2, "otherproduct", "", "", "", 4, "9.99", "0", 2, 3, "2024-08-01", "2024-09-01", 3, nil))
+	mock.ExpectQuery(supplier).WithArgs(4).WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(true))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(false, 1))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 5))
+	mock.ExpectExec("DELETE FROM products WHERE id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	store := &PostgresProductStore{DB: db}
+	product, err := store.RestoreProduct(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, product.Version)
+	_, err = store.RestoreProduct(context.Background(), 2)
+	assert.ErrorIs(t, err, ErrInvalidReference)
+	assert.ErrorIs(t, store.PurgeProduct(context.Background(), 3, AnyVersion), ErrInvalidState)
+	assert.NoError(t, store.PurgeProduct(context.Background(), 1, 5))
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresUpdateProductPrice(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, the old price goes into the history with the user
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("10.00"))
+	query := "UPDATE products SET price = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs(decimal.NewFromFloat(999.99), 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO product_prices").WithArgs(1, decimal.RequireFromString("10.00"), decimal.NewFromFloat(999.99), PriceReasonManual, 7, 0).
+		WillReturnRows(sqlmock.NewRows(priceChangeRowColumns).AddRow(1, 1, "10.00", "999.99", PriceReasonManual, 7, nil, "2024-09-01"))
+	mock.ExpectCommit()
+
+	// the same price again writes nothing
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("999.99"))
+	mock.ExpectCommit()
+
+	// a missing product
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
+
+	store := &PostgresProductStore{DB: db}
+	assert.NoError(t, store.UpdateProductPrice(context.Background(), 1, AnyVersion, decimal.NewFromFloat(999.99), 7))
+	assert.NoError(t, store.UpdateProductPrice(context.Background(), 1, AnyVersion, decimal.NewFromFloat(999.99), 7))
+	assert.ErrorIs(t, store.UpdateProductPrice(context.Background(), 9, AnyVersion, decimal.NewFromFloat(1), 7), ErrNotFound)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresUpdateProductCost(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	cost := decimal.RequireFromString("12.25")
+	mock.ExpectExec("UPDATE products SET cost = \\$1").WithArgs(cost, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := &PostgresProductStore{DB: db}
+	assert.NoError(t, store.UpdateProductCost(context.Background(), 1, AnyVersion, cost))
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresUpdateProductStaleVersion(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, the product is at version 4 by now
+	cost := decimal.RequireFromString("12.25")
+	mock.ExpectExec("UPDATE products SET cost = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2 AND version = \\$3").WithArgs(cost, 1, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("UPDATE products SET cost").WithArgs(cost, 9, 3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	mock.ExpectRollback()
+
+	store := &PostgresProductStore{DB: db}
+	assert.ErrorIs(t, store.UpdateProductCost(context.Background(), 1, 3, cost), ErrVersionMismatch)
+	assert.ErrorIs(t, store.UpdateProductCost(context.Background(), 9, 3, cost), ErrNotFound)
+	assert.ErrorIs(t, store.UpdateProductPrice(context.Background(), 1, 3, decimal.NewFromInt(5), 7), ErrVersionMismatch)
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresImportProducts(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, a new product, an existing one by name and one by name with a new SKU, every row in a savepoint
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE sku = \\$1 FOR UPDATE").WithArgs("TP-1").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE name = \\$1 FOR UPDATE").WithArgs("newproduct").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier WHERE id = \\$1 FOR SHARE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	mock.ExpectQuery("INSERT INTO products").WithArgs("newproduct", "TP-1", "", "", 1, decimal.NewFromInt(5), decimal.Zero, 4, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec("INSERT INTO stock_movements").WithArgs(2, 4, ReasonAdjustment, 0, "opening balance", 0).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE name = \\$1 FOR UPDATE").WithArgs("testproduct").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}).AddRow(1, false))
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+	mock.ExpectQuery("UPDATE products SET name = \\$1, cost = \\$2, updated_at = NOW\\(\\) WHERE id = \\$3 AND deleted_at IS NULL").WithArgs("testproduct", decimal.NewFromInt(2), 1).
+		WillReturnRows(sqlmock.NewRows(productRowColumns).AddRow(1, "testproduct", "", "", "", 1, "3", "2", 0, 0, "2024-08-01", "2024-09-01", 4, nil))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE sku = \\$1 FOR UPDATE").WithArgs("TP-9").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE name = \\$1 FOR UPDATE").WithArgs("testproduct").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}).AddRow(1, false))
+	mock.ExpectQuery("SELECT version FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	mock.ExpectQuery("UPDATE products SET name = \\$1, sku = NULLIF\\(\\$2, ''\\), updated_at = NOW\\(\\) WHERE id = \\$3 AND deleted_at IS NULL").WithArgs("testproduct", "TP-9", 1).
+		WillReturnRows(sqlmock.NewRows(productRowColumns).AddRow(1, "testproduct", "TP-9", "", "", 1, "3", "2", 0, 0, "2024-08-01", "2024-09-01", 5, nil))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	store := &PostgresProductStore{DB: db}
+	name, sku, supplierID, price := "newproduct", "TP-1", int64(1), decimal.NewFromInt(5)
+	existing, cost, newSKU := "testproduct", decimal.NewFromInt(2), "TP-9"
+	rows := []ProductImport{
+		{Row: 2, Patch: ProductPatch{Name: &name, SKU: &sku, SupplierID: &supplierID, Price: &price}, Stock: 4},
+		{Row: 3, Patch: ProductPatch{Name: &existing, Cost: &cost}},
+		{Row: 4, Patch: ProductPatch{Name: &existing, SKU: &newSKU}},
+	}
+	result, err := store.ImportProducts(context.Background(), rows, false, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportResult{Created: 1, Updated: 2, Errors: []ImportError{}, Committed: true}, result)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresImportProductsRowError(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// the failed row is rolled back to its savepoint and nothing is committed
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE name = \\$1").WithArgs("newproduct").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier WHERE id = \\$1 FOR SHARE").WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"deleted"}))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	store := &PostgresProductStore{DB: db}
+	name, supplierID := "newproduct", int64(9)
+	result, err := store.ImportProducts(context.Background(), []ProductImport{{Row: 2, Patch: ProductPatch{Name: &name, SupplierID: &supplierID}}}, false, 7)
+	assert.NoError(t, err)
+	assert.False(t, result.Committed)
+	if assert.Len(t, result.Errors, 1) {
+		assert.Equal(t, 2, result.Errors[0].Row)
+	}
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresImportProductsDeleted(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// a deleted product keeps its SKU, the row asks for a restore instead of creating another
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM products WHERE sku = \\$1 FOR UPDATE").WithArgs("TP-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}).AddRow(3, true))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	store := &PostgresProductStore{DB: db}
+	name, sku := "testproduct", "TP-1"
+	result, err := store.ImportProducts(context.Background(), []ProductImport{{Row: 2, Patch: ProductPatch{Name: &name, SKU: &sku}}}, false, 7)
+	assert.NoError(t, err)
+	assert.Equal(t, []ImportError{{Row: 2, Message: "not allowed in the current state: product 3 is deleted, restore it first"}}, result.Errors)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
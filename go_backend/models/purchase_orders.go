@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// purchase order lifecycle: draft -> submitted -> partially_received -> received,
+// drafts and submitted orders with nothing received yet can be cancelled
+const (
+	POStatusDraft             = "draft"
+	POStatusSubmitted         = "submitted"
+	POStatusPartiallyReceived = "partially_received"
+	POStatusReceived          = "received"
+	POStatusCancelled         = "cancelled"
+)
+
+func ValidPOStatus(status string) bool {
+	switch status {
+	case POStatusDraft, POStatusSubmitted, POStatusPartiallyReceived, POStatusReceived, POStatusCancelled:
+		return true
+	}
+	return false
+}
+
+type PurchaseOrder struct {
+	ID          int64                  `json:"id"`
+	SupplierID  int64                  `json:"supplier_id"`
+	Status      string                 `json:"status"`
+	Notes       string                 `json:"notes"`
+	CreatedBy   *int64                 `json:"created_by"`
+	SubmittedAt string                 `json:"submitted_at"`
+	ReceivedAt  string                 `json:"received_at"`
+	CreatedAt   string                 `json:"created_at"`
+	UpdatedAt   string                 `json:"updated_at"`
+	Lines       []PurchaseOrderLine    `json:"lines,omitempty"`
+	Receipts    []PurchaseOrderReceipt `json:"receipts,omitempty"`
+}
+
+// one product on an order, at most one line per product
+type PurchaseOrderLine struct {
+	ID               int64           `json:"id"`
+	ProductID        int64           `json:"product_id"`
+	QuantityOrdered  int             `json:"quantity_ordered"`
+	QuantityReceived int             `json:"quantity_received"`
+	UnitCost         decimal.Decimal `json:"unit_cost"` // agreed when ordering
+}
+
+// goods that arrived against a line, with what they actually cost
+type PurchaseOrderReceipt struct {
+	ID         int64           `json:"id"`
+	LineID     int64           `json:"line_id"`
+	ProductID  int64           `json:"product_id"`
+	Quantity   int             `json:"quantity"`
+	UnitCost   decimal.Decimal `json:"unit_cost"`
+	ReceivedBy *int64          `json:"received_by"`
+	ReceivedAt string          `json:"received_at"`
+}
+
+// what arrived for one product, UnitCost nil means the ordered cost
+type ReceiptLine struct {
+	ProductID int64
+	Quantity  int
+	UnitCost  *decimal.Decimal
+}
+
+// zero values match everything
+type PurchaseOrderFilter struct {
+	Status     string
+	SupplierID int64
+}
+
+// PurchaseOrderStore persists purchase orders, receiving one moves stock
+type PurchaseOrderStore interface {
+	// CreatePurchaseOrder inserts a draft with its lines and sets the IDs.
+	// Every product must be supplied by the order's supplier.
+	CreatePurchaseOrder(ctx context.Context, order *PurchaseOrder) error
+	// CreatePurchaseOrders inserts several drafts like CreatePurchaseOrder in one transaction,
+	// none of them when one fails
+	CreatePurchaseOrders(ctx context.Context, orders []PurchaseOrder) error
+	// GetPurchaseOrder includes lines and receipts
+	GetPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error)
+	// ListPurchaseOrders leaves out lines and receipts, newest first
+	ListPurchaseOrders(ctx context.Context, filter PurchaseOrderFilter) ([]PurchaseOrder, error)
+	// SubmitPurchaseOrder and CancelPurchaseOrder return ErrInvalidState
+	// when the order is not in a status they apply to
+	SubmitPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error)
+	CancelPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error)
+	// ReceivePurchaseOrder books the lines into stock in one transaction, with a
+	// receipt movement per product. Nil lines receives everything outstanding.
+	// Returns the order and the new stock levels.
+	ReceivePurchaseOrder(ctx context.Context, id int64, lines []ReceiptLine, userID int64) (PurchaseOrder, []StockLevel, error)
+	// ListReorderCandidates returns every product at or below its minimum stock
+	// with consumption since the given time, sorted by supplier then product
+	ListReorderCandidates(ctx context.Context, since time.Time) ([]ReorderCandidate, error)
+}
@@ -0,0 +1,287 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// orders are stored with their lines and receipts, hand out copies so callers
+// never share slices with the store
+func clonePurchaseOrder(order PurchaseOrder) PurchaseOrder {
+	order.Lines = slices.Clone(order.Lines)
+	order.Receipts = slices.Clone(order.Receipts)
+	return order
+}
+
+func (m *MemoryStore) CreatePurchaseOrder(ctx context.Context, order *PurchaseOrder) error {
+	orders := []PurchaseOrder{*order}
+	if err := m.CreatePurchaseOrders(ctx, orders); err != nil {
+		return err
+	}
+	*order = orders[0]
+	return nil
+}
+
+func (m *MemoryStore) CreatePurchaseOrders(ctx context.Context, orders []PurchaseOrder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// nothing is written until every order is checked
+	for _, order := range orders {
+		if err := m.checkPurchaseOrderLocked(order); err != nil {
+			return err
+		}
+	}
+	for i := range orders {
+		m.insertPurchaseOrderLocked(&orders[i])
+	}
+	return nil
+}
+
+// caller holds the lock
+func (m *MemoryStore) checkPurchaseOrderLocked(order PurchaseOrder) error {
+	if _, ok := m.suppliers[order.SupplierID]; !ok {
+		return fmt.Errorf("%w: Key (supplier_id)=(%d) is not present in table \"supplier\".", ErrInvalidReference, order.SupplierID)
+	}
+
+	seen := map[int64]bool{}
+	for _, line := range order.Lines {
+		product, ok := m.products[line.ProductID]
+		if !ok || product.DeletedAt != "" {
+			return fmt.Errorf("%w: product %d does not exist", ErrInvalidReference, line.ProductID)
+		}
+		if product.SupplierID != order.SupplierID {
+			return fmt.Errorf("%w: product %d is not supplied by supplier %d", ErrInvalidReference, line.ProductID, order.SupplierID)
+		}
+		if seen[line.ProductID] {
+			return fmt.Errorf("%w: Key (purchase_order_id, product_id) already exists.", ErrDuplicate)
+		}
+		seen[line.ProductID] = true
+	}
+	return nil
+}
+
+// caller holds the write lock and has checked the order
+func (m *MemoryStore) insertPurchaseOrderLocked(order *PurchaseOrder) {
+	m.lastPurchaseOrderID++
+	order.ID = m.lastPurchaseOrderID
+	order.Status = POStatusDraft
+	order.SubmittedAt = ""
+	order.ReceivedAt = ""
+	order.CreatedAt = memoryNow()
+	order.UpdatedAt = order.CreatedAt
+	order.Receipts = nil
+	for i := range order.Lines {
+		m.lastPurchaseOrderLineID++
+		order.Lines[i].ID = m.lastPurchaseOrderLineID
+		order.Lines[i].QuantityReceived = 0
+	}
+
+	m.purchaseOrders[order.ID] = clonePurchaseOrder(*order)
+}
+
+func (m *MemoryStore) GetPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, ok := m.purchaseOrders[id]
+	if !ok {
+		return PurchaseOrder{}, ErrNotFound
+	}
+	order = clonePurchaseOrder(order)
+	if order.Lines == nil {
+		order.Lines = []PurchaseOrderLine{}
+	}
+	return order, nil
+}
+
+func (m *MemoryStore) ListPurchaseOrders(ctx context.Context, filter PurchaseOrderFilter) ([]PurchaseOrder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := []PurchaseOrder{}
+	all := sortedByID(m.purchaseOrders)
+	for i := len(all) - 1; i >= 0; i-- {
+		order := all[i]
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		if filter.SupplierID != 0 && order.SupplierID != filter.SupplierID {
+			continue
+		}
+		order.Lines, order.Receipts = nil, nil
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// caller holds the write lock
+func (m *MemoryStore) transitionPurchaseOrder(id int64, status string, from ...string) (PurchaseOrder, error) {
+	order, ok := m.purchaseOrders[id]
+	if !ok {
+		return PurchaseOrder{}, ErrNotFound
+	}
+	if !slices.Contains(from, order.Status) {
+		return PurchaseOrder{}, fmt.Errorf("%w: purchase order %d is %s", ErrInvalidState, id, order.Status)
+	}
+
+	order.Status = status
+	order.UpdatedAt = memoryNow()
+	switch status {
+	case POStatusSubmitted:
+		order.SubmittedAt = order.UpdatedAt
+	case POStatusReceived:
+		order.ReceivedAt = order.UpdatedAt
+	}
+	m.purchaseOrders[id] = order
+	return clonePurchaseOrder(order), nil
+}
+
+func (m *MemoryStore) SubmitPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.transitionPurchaseOrder(id, POStatusSubmitted, POStatusDraft)
+}
+
+func (m *MemoryStore) CancelPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.transitionPurchaseOrder(id, POStatusCancelled, POStatusDraft, POStatusSubmitted)
+}
+
+func (m *MemoryStore) ReceivePurchaseOrder(ctx context.Context, id int64, lines []ReceiptLine, userID int64) (PurchaseOrder, []StockLevel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.purchaseOrders[id]
+	if !ok {
+		return PurchaseOrder{}, nil, ErrNotFound
+	}
+	if order.Status != POStatusSubmitted && order.Status != POStatusPartiallyReceived {
+		return PurchaseOrder{}, nil, fmt.Errorf("%w: purchase order %d is %s", ErrInvalidState, id, order.Status)
+	}
+
+	receipts, err := planReceipts(order, lines)
+	if err != nil {
+		return PurchaseOrder{}, nil, err
+	}
+
+	// every product must still exist before anything is booked
+	for _, receipt := range receipts {
+		product, ok := m.products[receipt.ProductID]
+		if !ok {
+			return PurchaseOrder{}, nil, ErrNotFound
+		}
+		if product.DeletedAt != "" {
+			return PurchaseOrder{}, nil, fmt.Errorf("%w: product %d is deleted, restore it first", ErrInvalidState, receipt.ProductID)
+		}
+	}
+
+	order = clonePurchaseOrder(order)
+	done := fullyReceived(order, receipts)
+	change := StockChange{Reason: ReasonReceipt, UserID: userID, Reference: purchaseOrderReference(id)}
+	levels := []StockLevel{}
+	now := memoryNow()
+	for _, receipt := range receipts {
+		for i := range order.Lines {
+			if order.Lines[i].ID == receipt.LineID {
+				order.Lines[i].QuantityReceived += receipt.Quantity
+			}
+		}
+
+		m.lastReceiptID++
+		receipt.ID = m.lastReceiptID
+		receipt.ReceivedAt = now
+		if userID != 0 {
+			receivedBy := userID
+			receipt.ReceivedBy = &receivedBy
+		}
+		order.Receipts = append(order.Receipts, receipt)
+
+		change.ReceiptID = receipt.ID
+		level, err := m.adjustStockLocked(receipt.ProductID, receipt.Quantity, change)
+		if err != nil {
+			return PurchaseOrder{}, nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	order.Status = POStatusPartiallyReceived
+	if done {
+		order.Status = POStatusReceived
+		order.ReceivedAt = now
+	}
+	order.UpdatedAt = now
+	m.purchaseOrders[id] = order
+	return clonePurchaseOrder(order), levels, nil
+}
+
+func (m *MemoryStore) ListReorderCandidates(ctx context.Context, since time.Time) ([]ReorderCandidate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	candidates := []ReorderCandidate{}
+	for _, product := range sortedByID(m.products) {
+		supplier := m.suppliers[product.SupplierID]
+		// stock reserved for confirmed sales orders is already spoken for
+		available := product.Stock - m.reserved[product.ID]
+		if product.DeletedAt != "" || supplier.DeletedAt != "" || available > product.MinimumStock {
+			continue
+		}
+		candidate := ReorderCandidate{
+			ProductID:    product.ID,
+			Name:         product.Name,
+			Stock:        available,
+			MinimumStock: product.MinimumStock,
+			SupplierID:   supplier.ID,
+			SupplierName: supplier.Name,
+			LeadTimeDays: supplier.LeadTimeDays,
+			LastUnitCost: product.Cost,
+		}
+
+		for _, movement := range m.movements {
+			if movement.ProductID != product.ID || movement.Delta >= 0 {
+				continue
+			}
+			if movement.Reason != ReasonSale && movement.Reason != ReasonDamage {
+				continue
+			}
+			createdAt, err := time.Parse(time.RFC3339Nano, movement.CreatedAt)
+			if err != nil {
+				return nil, err
+			}
+			if !createdAt.Before(since) {
+				candidate.Consumed -= movement.Delta
+			}
+		}
+
+		// receipt ids only grow, the highest is the latest
+		var lastReceiptID int64
+		for _, order := range m.purchaseOrders {
+			for _, receipt := range order.Receipts {
+				if receipt.ProductID == product.ID && receipt.ID > lastReceiptID {
+					lastReceiptID = receipt.ID
+					candidate.LastUnitCost = receipt.UnitCost
+				}
+			}
+			if order.Status != POStatusDraft && order.Status != POStatusSubmitted && order.Status != POStatusPartiallyReceived {
+				continue
+			}
+			for _, line := range order.Lines {
+				if line.ProductID == product.ID {
+					candidate.OnOrder += line.QuantityOrdered - line.QuantityReceived
+				}
+			}
+		}
+
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].SupplierID < candidates[j].SupplierID })
+	return candidates, nil
+}
@@ -0,0 +1,376 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostgresPurchaseOrderStore implements PurchaseOrderStore on the purchase_orders,
+// purchase_order_lines and purchase_order_receipts tables
+type PostgresPurchaseOrderStore struct {
+	DB *sql.DB
+}
+
+const purchaseOrderColumns = "id, supplier_id, status, notes, created_by, submitted_at, received_at, created_at, updated_at"
+
+func scanPurchaseOrder(row scanner) (PurchaseOrder, error) {
+	var (
+		order       PurchaseOrder
+		createdBy   sql.NullInt64
+		submittedAt sql.NullString
+		receivedAt  sql.NullString
+	)
+	err := row.Scan(&order.ID, &order.SupplierID, &order.Status, &order.Notes, &createdBy, &submittedAt, &receivedAt, &order.CreatedAt, &order.UpdatedAt)
+	if createdBy.Valid {
+		order.CreatedBy = &createdBy.Int64
+	}
+	order.SubmittedAt = submittedAt.String
+	order.ReceivedAt = receivedAt.String
+	return order, err
+}
+
+func (s *PostgresPurchaseOrderStore) CreatePurchaseOrder(ctx context.Context, order *PurchaseOrder) error {
+	orders := []PurchaseOrder{*order}
+	if err := s.CreatePurchaseOrders(ctx, orders); err != nil {
+		return err
+	}
+	*order = orders[0]
+	return nil
+}
+
+func (s *PostgresPurchaseOrderStore) CreatePurchaseOrders(ctx context.Context, orders []PurchaseOrder) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	created := make([]PurchaseOrder, len(orders))
+	for i, order := range orders {
+		if created[i], err = createPurchaseOrderTx(ctx, tx, order); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	copy(orders, created)
+	return nil
+}
+
+// insert a draft with its lines, returns it with the IDs set
+func createPurchaseOrderTx(ctx context.Context, tx *sql.Tx, order PurchaseOrder) (PurchaseOrder, error) {
+	query := "INSERT INTO purchase_orders (supplier_id, status, notes, created_by) VALUES ($1, $2, $3, $4) RETURNING " + purchaseOrderColumns
+	created, err := scanPurchaseOrder(tx.QueryRowContext(ctx, query, order.SupplierID, POStatusDraft, order.Notes, order.CreatedBy))
+	if err != nil {
+		return created, mapError(err)
+	}
+
+	order.Lines = slices.Clone(order.Lines)
+
+	for i := range order.Lines {
+		line := &order.Lines[i]
+
+		// only products of this supplier can be ordered from it
+		var supplierID int64
+		err := tx.QueryRowContext(ctx, "SELECT supplier_id FROM products WHERE id = $1 AND deleted_at IS NULL", line.ProductID).Scan(&supplierID)
+		if err == sql.ErrNoRows {
+			return created, fmt.Errorf("%w: product %d does not exist", ErrInvalidReference, line.ProductID)
+		}
+		if err != nil {
+			return created, err
+		}
+		if supplierID != order.SupplierID {
+			return created, fmt.Errorf("%w: product %d is not supplied by supplier %d", ErrInvalidReference, line.ProductID, order.SupplierID)
+		}
+
+		query := "INSERT INTO purchase_order_lines (purchase_order_id, product_id, quantity_ordered, unit_cost) VALUES ($1, $2, $3, $4) RETURNING id"
+		if err := tx.QueryRowContext(ctx, query, created.ID, line.ProductID, line.QuantityOrdered, line.UnitCost).Scan(&line.ID); err != nil {
+			return created, mapError(err)
+		}
+		line.QuantityReceived = 0
+	}
+
+	created.Lines = order.Lines
+	return created, nil
+}
+
+func getPurchaseOrder(ctx context.Context, q querier, id int64) (PurchaseOrder, error) {
+	query := "SELECT " + purchaseOrderColumns + " FROM purchase_orders WHERE id = $1"
+	order, err := scanPurchaseOrder(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return order, mapError(err)
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT id, product_id, quantity_ordered, quantity_received, unit_cost FROM purchase_order_lines WHERE purchase_order_id = $1 ORDER BY id", id)
+	if err != nil {
+		return order, err
+	}
+	defer rows.Close()
+
+	order.Lines = []PurchaseOrderLine{}
+	for rows.Next() {
+		var line PurchaseOrderLine
+		if err := rows.Scan(&line.ID, &line.ProductID, &line.QuantityOrdered, &line.QuantityReceived, &line.UnitCost); err != nil {
+			return order, err
+		}
+		order.Lines = append(order.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return order, err
+	}
+
+	query = `SELECT r.id, r.purchase_order_line_id, l.product_id, r.quantity, r.unit_cost, r.received_by, r.received_at
+		FROM purchase_order_receipts r JOIN purchase_order_lines l ON l.id = r.purchase_order_line_id
+		WHERE l.purchase_order_id = $1 ORDER BY r.id`
+	receiptRows, err := q.QueryContext(ctx, query, id)
+	if err != nil {
+		return order, err
+	}
+	defer receiptRows.Close()
+
+	for receiptRows.Next() {
+		var (
+			receipt    PurchaseOrderReceipt
+			receivedBy sql.NullInt64
+		)
+		if err := receiptRows.Scan(&receipt.ID, &receipt.LineID, &receipt.ProductID, &receipt.Quantity, &receipt.UnitCost, &receivedBy, &receipt.ReceivedAt); err != nil {
+			return order, err
+		}
+		if receivedBy.Valid {
+			receipt.ReceivedBy = &receivedBy.Int64
+		}
+		order.Receipts = append(order.Receipts, receipt)
+	}
+	return order, receiptRows.Err()
+}
+
+func (s *PostgresPurchaseOrderStore) GetPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error) {
+	return getPurchaseOrder(ctx, s.DB, id)
+}
+
+func (s *PostgresPurchaseOrderStore) ListPurchaseOrders(ctx context.Context, filter PurchaseOrderFilter) ([]PurchaseOrder, error) {
+	conditions := []string{"TRUE"}
+	args := []any{}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.SupplierID != 0 {
+		args = append(args, filter.SupplierID)
+		conditions = append(conditions, fmt.Sprintf("supplier_id = $%d", len(args)))
+	}
+
+	query := "SELECT " + purchaseOrderColumns + " FROM purchase_orders WHERE " + strings.Join(conditions, " AND ") + " ORDER BY id DESC"
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []PurchaseOrder{}
+	for rows.Next() {
+		order, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// move the order to a new status if it is in one of from, set is extra SQL for the SET clause
+func (s *PostgresPurchaseOrderStore) transition(ctx context.Context, id int64, status string, set string, from ...string) (PurchaseOrder, error) {
+	query := "UPDATE purchase_orders SET status = $1, " + set + "updated_at = NOW() WHERE id = $2 AND status = ANY($3)"
+	result, err := s.DB.ExecContext(ctx, query, status, id, pq.Array(from))
+	if err != nil {
+		return PurchaseOrder{}, err
+	}
+	if err := expectOneRow(result); err != nil {
+		// missing, or in the wrong status
+		order, getErr := s.GetPurchaseOrder(ctx, id)
+		if getErr != nil {
+			return order, getErr
+		}
+		return order, fmt.Errorf("%w: purchase order %d is %s", ErrInvalidState, id, order.Status)
+	}
+	return s.GetPurchaseOrder(ctx, id)
+}
+
+func (s *PostgresPurchaseOrderStore) SubmitPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error) {
+	return s.transition(ctx, id, POStatusSubmitted, "submitted_at = NOW(), ", POStatusDraft)
+}
+
+func (s *PostgresPurchaseOrderStore) CancelPurchaseOrder(ctx context.Context, id int64) (PurchaseOrder, error) {
+	return s.transition(ctx, id, POStatusCancelled, "", POStatusDraft, POStatusSubmitted)
+}
+
+func (s *PostgresPurchaseOrderStore) ReceivePurchaseOrder(ctx context.Context, id int64, lines []ReceiptLine, userID int64) (PurchaseOrder, []StockLevel, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return PurchaseOrder{}, nil, err
+	}
+	defer tx.Rollback()
+
+	// lock the order so two receipts cannot book the same goods twice
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM purchase_orders WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err != nil {
+		return PurchaseOrder{}, nil, mapError(err)
+	}
+	if status != POStatusSubmitted && status != POStatusPartiallyReceived {
+		return PurchaseOrder{}, nil, fmt.Errorf("%w: purchase order %d is %s", ErrInvalidState, id, status)
+	}
+
+	order, err := getPurchaseOrder(ctx, tx, id)
+	if err != nil {
+		return order, nil, err
+	}
+
+	receipts, err := planReceipts(order, lines)
+	if err != nil {
+		return order, nil, err
+	}
+
+	change := StockChange{Reason: ReasonReceipt, UserID: userID, Reference: purchaseOrderReference(id)}
+	levels := []StockLevel{}
+	for _, receipt := range receipts {
+		_, err := tx.ExecContext(ctx, "UPDATE purchase_order_lines SET quantity_received = quantity_received + $1 WHERE id = $2", receipt.Quantity, receipt.LineID)
+		if err != nil {
+			return order, nil, err
+		}
+
+		// the movement points at its receipt, valuations take the cost from there
+		query := "INSERT INTO purchase_order_receipts (purchase_order_line_id, quantity, unit_cost, received_by) VALUES ($1, $2, $3, NULLIF($4, 0)) RETURNING id"
+		if err := tx.QueryRowContext(ctx, query, receipt.LineID, receipt.Quantity, receipt.UnitCost, userID).Scan(&change.ReceiptID); err != nil {
+			return order, nil, mapError(err)
+		}
+
+		level, err := adjustStockTx(ctx, tx, receipt.ProductID, receipt.Quantity, change)
+		// order lines keep their product from being purged, so a missing product was deleted
+		if errors.Is(err, ErrNotFound) {
+			return order, nil, fmt.Errorf("%w: product %d is deleted, restore it first", ErrInvalidState, receipt.ProductID)
+		}
+		if err != nil {
+			return order, nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	status, set := POStatusPartiallyReceived, ""
+	if fullyReceived(order, receipts) {
+		status, set = POStatusReceived, "received_at = NOW(), "
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE purchase_orders SET status = $1, "+set+"updated_at = NOW() WHERE id = $2", status, id); err != nil {
+		return order, nil, err
+	}
+
+	order, err = getPurchaseOrder(ctx, tx, id)
+	if err != nil {
+		return order, nil, err
+	}
+
+	return order, levels, tx.Commit()
+}
+
+func (s *PostgresPurchaseOrderStore) ListReorderCandidates(ctx context.Context, since time.Time) ([]ReorderCandidate, error) {
+	// stock reserved for confirmed sales orders is already spoken for
+	query := `SELECT p.id, p.name, p.stock - p.reserved, p.minimum_stock, s.id, s.name, s.lead_time_days,
+			COALESCE((SELECT -SUM(m.delta) FROM stock_movements m
+				WHERE m.product_id = p.id AND m.reason IN ('sale', 'damage') AND m.delta < 0 AND m.created_at >= $1), 0),
+			COALESCE((SELECT SUM(l.quantity_ordered - l.quantity_received) FROM purchase_order_lines l
+				JOIN purchase_orders o ON o.id = l.purchase_order_id
+				WHERE l.product_id = p.id AND o.status IN ('draft', 'submitted', 'partially_received')), 0),
+			COALESCE((SELECT r.unit_cost FROM purchase_order_receipts r
+				JOIN purchase_order_lines l ON l.id = r.purchase_order_line_id
+				WHERE l.product_id = p.id ORDER BY r.received_at DESC, r.id DESC LIMIT 1), p.cost)
+		FROM products p JOIN supplier s ON s.id = p.supplier_id
+		WHERE p.stock - p.reserved <= p.minimum_stock AND p.deleted_at IS NULL AND s.deleted_at IS NULL
+		ORDER BY s.id, p.id`
+	rows, err := s.DB.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []ReorderCandidate{}
+	for rows.Next() {
+		var c ReorderCandidate
+		err := rows.Scan(&c.ProductID, &c.Name, &c.Stock, &c.MinimumStock, &c.SupplierID, &c.SupplierName, &c.LeadTimeDays, &c.Consumed, &c.OnOrder, &c.LastUnitCost)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// reference written on the stock movements of a purchase order
+func purchaseOrderReference(id int64) string {
+	return fmt.Sprintf("PO-%d", id)
+}
+
+// turn what arrived into one receipt per line, checked against what is outstanding.
+// Nil lines receives everything outstanding at the ordered cost.
+func planReceipts(order PurchaseOrder, lines []ReceiptLine) ([]PurchaseOrderReceipt, error) {
+	byProduct := map[int64]PurchaseOrderLine{}
+	for _, line := range order.Lines {
+		byProduct[line.ProductID] = line
+	}
+
+	if lines == nil {
+		for _, line := range order.Lines {
+			if outstanding := line.QuantityOrdered - line.QuantityReceived; outstanding > 0 {
+				lines = append(lines, ReceiptLine{ProductID: line.ProductID, Quantity: outstanding})
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: nothing left to receive on purchase order %d", ErrInvalidState, order.ID)
+	}
+
+	receipts := []PurchaseOrderReceipt{}
+	seen := map[int64]bool{}
+	for _, received := range lines {
+		line, ok := byProduct[received.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: product %d is not on purchase order %d", ErrInvalidReference, received.ProductID, order.ID)
+		}
+		if seen[received.ProductID] {
+			return nil, fmt.Errorf("%w: product %d is listed twice", ErrDuplicate, received.ProductID)
+		}
+		seen[received.ProductID] = true
+
+		if outstanding := line.QuantityOrdered - line.QuantityReceived; received.Quantity > outstanding {
+			return nil, fmt.Errorf("%w: only %d of product %d are outstanding", ErrInvalidState, outstanding, received.ProductID)
+		}
+
+		unitCost := line.UnitCost
+		if received.UnitCost != nil {
+			unitCost = *received.UnitCost
+		}
+		receipts = append(receipts, PurchaseOrderReceipt{LineID: line.ID, ProductID: line.ProductID, Quantity: received.Quantity, UnitCost: unitCost})
+	}
+	return receipts, nil
+}
+
+// whether the receipts complete every line of the order
+func fullyReceived(order PurchaseOrder, receipts []PurchaseOrderReceipt) bool {
+	received := map[int64]int{}
+	for _, receipt := range receipts {
+		received[receipt.LineID] += receipt.Quantity
+	}
+	for _, line := range order.Lines {
+		if line.QuantityReceived+received[line.ID] < line.QuantityOrdered {
+			return false
+		}
+	}
+	return true
+}
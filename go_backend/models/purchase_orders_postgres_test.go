@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var purchaseOrderRowColumns = []string{"id", "supplier_id", "status", "notes", "created_by", "submitted_at", "received_at", "created_at", "updated_at"}
+var purchaseOrderLineRowColumns = []string{"id", "product_id", "quantity_ordered", "quantity_received", "unit_cost"}
+var receiptRowColumns = []string{"id", "purchase_order_line_id", "product_id", "quantity", "unit_cost", "received_by", "received_at"}
+
+func TestPostgresCreatePurchaseOrderWrongSupplier(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO purchase_orders").WithArgs(1, POStatusDraft, "", nil).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderRowColumns).AddRow(1, 1, POStatusDraft, "", nil, nil, nil, "2024-08-20T12:00:00Z", "2024-08-20T12:00:00Z"))
+	mock.ExpectQuery("SELECT supplier_id FROM products WHERE id = \\$1").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"supplier_id"}).AddRow(2))
+	mock.ExpectRollback()
+
+	store := &PostgresPurchaseOrderStore{DB: db}
+	order := PurchaseOrder{SupplierID: 1, Lines: []PurchaseOrderLine{{ProductID: 5, QuantityOrdered: 1}}}
+	err = store.CreatePurchaseOrder(context.Background(), &order)
+	assert.ErrorIs(t, err, ErrInvalidReference)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresSubmitPurchaseOrderWrongStatus(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectExec("UPDATE purchase_orders SET status = \\$1, submitted_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE id = \\$2 AND status = ANY\\(\\$3\\)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("FROM purchase_orders WHERE id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderRowColumns).AddRow(1, 1, POStatusReceived, "", nil, nil, nil, "2024-08-20T12:00:00Z", "2024-08-20T12:00:00Z"))
+	mock.ExpectQuery("FROM purchase_order_lines").WithArgs(1).WillReturnRows(sqlmock.NewRows(purchaseOrderLineRowColumns))
+	mock.ExpectQuery("FROM purchase_order_receipts").WithArgs(1).WillReturnRows(sqlmock.NewRows(receiptRowColumns))
+
+	store := &PostgresPurchaseOrderStore{DB: db}
+	_, err = store.SubmitPurchaseOrder(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresReceivePurchaseOrderPartially(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	created := "2024-08-20T12:00:00Z"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM purchase_orders WHERE id = \\$1 FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(POStatusSubmitted))
+	mock.ExpectQuery("FROM purchase_orders WHERE id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderRowColumns).AddRow(1, 1, POStatusSubmitted, "", nil, created, nil, created, created))
+	mock.ExpectQuery("FROM purchase_order_lines").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderLineRowColumns).AddRow(10, 5, 8, 0, "2.00"))
+	mock.ExpectQuery("FROM purchase_order_receipts").WithArgs(1).WillReturnRows(sqlmock.NewRows(receiptRowColumns))
+
+	// three of eight arrive at the ordered cost
+	mock.ExpectExec("UPDATE purchase_order_lines SET quantity_received = quantity_received \\+ \\$1 WHERE id = \\$2").WithArgs(3, 10).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO purchase_order_receipts (.+) RETURNING id").WithArgs(10, 3, decimal.RequireFromString("2.00"), 4).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1").WithArgs(3, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "minimum_stock", "reserved"}).AddRow(5, "testproduct", 3, 1, 0))
+	mock.ExpectExec("INSERT INTO stock_movements").WithArgs(5, 3, ReasonReceipt, 4, "PO-1", 6).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE purchase_orders SET status = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2").WithArgs(POStatusPartiallyReceived, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery("FROM purchase_orders WHERE id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderRowColumns).AddRow(1, 1, POStatusPartiallyReceived, "", nil, created, nil, created, created))
+	mock.ExpectQuery("FROM purchase_order_lines").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderLineRowColumns).AddRow(10, 5, 8, 3, "2.00"))
+	mock.ExpectQuery("FROM purchase_order_receipts").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(receiptRowColumns).AddRow(1, 10, 5, 3, "2.00", 4, created))
+	mock.ExpectCommit()
+
+	store := &PostgresPurchaseOrderStore{DB: db}
+	order, levels, err := store.ReceivePurchaseOrder(context.Background(), 1, []ReceiptLine{{ProductID: 5, Quantity: 3}}, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, POStatusPartiallyReceived, order.Status)
+	assert.Len(t, order.Receipts, 1)
+	assert.Equal(t, 3, levels[0].Stock)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresReceivePurchaseOrderDeletedProduct(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	created := "2024-08-20T12:00:00Z"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM purchase_orders WHERE id = \\$1 FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(POStatusSubmitted))
+	mock.ExpectQuery("FROM purchase_orders WHERE id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderRowColumns).AddRow(1, 1, POStatusSubmitted, "", nil, created, nil, created, created))
+	mock.ExpectQuery("FROM purchase_order_lines").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(purchaseOrderLineRowColumns).AddRow(10, 5, 8, 0, "2.00"))
+	mock.ExpectQuery("FROM purchase_order_receipts").WithArgs(1).WillReturnRows(sqlmock.NewRows(receiptRowColumns))
+	mock.ExpectExec("UPDATE purchase_order_lines SET quantity_received").WithArgs(8, 10).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("INSERT INTO purchase_order_receipts (.+) RETURNING id").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+
+	// the product was deleted before the goods arrived
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1").WithArgs(8, 5).WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "minimum_stock", "reserved"}))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM products WHERE id = \\$1 AND deleted_at IS NULL\\)").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	store := &PostgresPurchaseOrderStore{DB: db}
+	_, _, err = store.ReceivePurchaseOrder(context.Background(), 1, nil, 4)
+	assert.ErrorIs(t, err, ErrInvalidState)
+	assert.ErrorContains(t, err, "product 5 is deleted")
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresListReorderCandidates(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// reserved stock does not count, products never received are costed at their cost
+	columns := []string{"id", "name", "stock", "minimum_stock", "supplier_id", "supplier_name", "lead_time_days", "consumed", "on_order", "unit_cost"}
+	mock.ExpectQuery("SELECT p.id, p.name, p.stock - p.reserved, (.+) LIMIT 1\\), p.cost\\)\\s+FROM products p JOIN supplier s ON s.id = p.supplier_id\\s+WHERE p.stock - p.reserved <= p.minimum_stock").
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(5, "testproduct", 2, 4, 1, "testsupplier", 7, 0, 0, "3.00"))
+
+	store := &PostgresPurchaseOrderStore{DB: db}
+	candidates, err := store.ListReorderCandidates(context.Background(), time.Now().AddDate(0, 0, -30))
+	assert.NoError(t, err)
+	assert.Len(t, candidates, 1)
+	assert.Equal(t, 2, candidates[0].Stock)
+	assert.True(t, decimal.RequireFromString("3.00").Equal(candidates[0].LastUnitCost))
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+// raw numbers behind a reorder suggestion for one low product
+type ReorderCandidate struct {
+	ProductID    int64
+	Name         string
+	Stock        int // on hand minus reserved for confirmed sales orders
+	MinimumStock int
+	SupplierID   int64
+	SupplierName string
+	LeadTimeDays int
+	Consumed     int             // sold or damaged since the start of the window
+	OnOrder      int             // outstanding on draft, submitted and partially received orders
+	LastUnitCost decimal.Decimal // from the latest receipt, the product's cost if never received
+}
+
+type ReorderSuggestion struct {
+	ProductID    int64           `json:"product_id"`
+	Name         string          `json:"name"`
+	Stock        int             `json:"stock"`
+	MinimumStock int             `json:"minimum_stock"`
+	OnOrder      int             `json:"on_order"`
+	DailyUsage   float64         `json:"daily_usage"`
+	Quantity     int             `json:"quantity"`
+	UnitCost     decimal.Decimal `json:"unit_cost"`
+}
+
+// suggestions grouped per supplier, one draft purchase order each
+type SupplierSuggestions struct {
+	SupplierID   int64               `json:"supplier_id"`
+	SupplierName string              `json:"supplier_name"`
+	LeadTimeDays int                 `json:"lead_time_days"`
+	Lines        []ReorderSuggestion `json:"lines"`
+}
+
+// SuggestReorders orders enough to get back to the minimum stock after the
+// demand expected during the supplier's lead time, minus what is already on order:
+//
+//	quantity = minimum_stock + ceil(daily_usage * lead_time_days) - stock - on_order
+//
+// daily_usage is the consumption over the last windowDays. Products that are
+// already covered are left out. Candidates must be sorted by supplier.
+func SuggestReorders(candidates []ReorderCandidate, windowDays int) []SupplierSuggestions {
+	suggestions := []SupplierSuggestions{}
+	for _, candidate := range candidates {
+		dailyUsage := float64(candidate.Consumed) / float64(windowDays)
+		demand := int(math.Ceil(dailyUsage * float64(candidate.LeadTimeDays)))
+
+		quantity := candidate.MinimumStock + demand - candidate.Stock - candidate.OnOrder
+		if quantity <= 0 {
+			continue
+		}
+
+		if len(suggestions) == 0 || suggestions[len(suggestions)-1].SupplierID != candidate.SupplierID {
+			suggestions = append(suggestions, SupplierSuggestions{
+				SupplierID:   candidate.SupplierID,
+				SupplierName: candidate.SupplierName,
+				LeadTimeDays: candidate.LeadTimeDays,
+				Lines:        []ReorderSuggestion{},
+			})
+		}
+		group := &suggestions[len(suggestions)-1]
+		group.Lines = append(group.Lines, ReorderSuggestion{
+			ProductID:    candidate.ProductID,
+			Name:         candidate.Name,
+			Stock:        candidate.Stock,
+			MinimumStock: candidate.MinimumStock,
+			OnOrder:      candidate.OnOrder,
+			DailyUsage:   math.Round(dailyUsage*100) / 100,
+			Quantity:     quantity,
+			UnitCost:     candidate.LastUnitCost,
+		})
+	}
+	return suggestions
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggestReorders(t *testing.T) {
+	t.Parallel()
+
+	candidates := []ReorderCandidate{
+		// 30 sold in 30 days, 1 a day over a 7 day lead time: 5 + 7 - 2 = 10
+		{ProductID: 1, Name: "fastmover", Stock: 2, MinimumStock: 5, SupplierID: 1, SupplierName: "first", LeadTimeDays: 7, Consumed: 30, LastUnitCost: decimal.NewFromInt(3)},
+		// nothing sold, just back to the minimum
+		{ProductID: 2, Name: "slowmover", Stock: 0, MinimumStock: 4, SupplierID: 1, SupplierName: "first", LeadTimeDays: 7},
+		// already covered by an open order
+		{ProductID: 3, Name: "ordered", Stock: 1, MinimumStock: 3, SupplierID: 2, SupplierName: "second", LeadTimeDays: 14, Consumed: 3, OnOrder: 10},
+		// 45 sold, 1.5 a day over 3 days rounds up to 5: 2 + 5 - 1 - 1 = 5
+		{ProductID: 4, Name: "partly", Stock: 1, MinimumStock: 2, SupplierID: 3, SupplierName: "third", LeadTimeDays: 3, Consumed: 45, OnOrder: 1},
+	}
+
+	suggestions := SuggestReorders(candidates, 30)
+
+	assert.Len(t, suggestions, 2)
+	assert.EqualValues(t, 1, suggestions[0].SupplierID)
+	assert.Len(t, suggestions[0].Lines, 2)
+	assert.Equal(t, 10, suggestions[0].Lines[0].Quantity)
+	assert.Equal(t, 1.0, suggestions[0].Lines[0].DailyUsage)
+	assert.True(t, decimal.NewFromInt(3).Equal(suggestions[0].Lines[0].UnitCost))
+	assert.Equal(t, 4, suggestions[0].Lines[1].Quantity)
+
+	assert.EqualValues(t, 3, suggestions[1].SupplierID)
+	assert.Equal(t, 5, suggestions[1].Lines[0].Quantity)
+	assert.Equal(t, 1.5, suggestions[1].Lines[0].DailyUsage)
+}
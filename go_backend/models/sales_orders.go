@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+)
+
+// sales order lifecycle: draft -> confirmed -> fulfilled, drafts and
+// confirmed orders can be cancelled. Confirming reserves the stock,
+// fulfilling takes it out of stock.
+const (
+	SOStatusDraft     = "draft"
+	SOStatusConfirmed = "confirmed"
+	SOStatusFulfilled = "fulfilled"
+	SOStatusCancelled = "cancelled"
+)
+
+func ValidSOStatus(status string) bool {
+	switch status {
+	case SOStatusDraft, SOStatusConfirmed, SOStatusFulfilled, SOStatusCancelled:
+		return true
+	}
+	return false
+}
+
+type SalesOrder struct {
+	ID          int64            `json:"id"`
+	CustomerID  int64            `json:"customer_id"`
+	Status      string           `json:"status"`
+	Notes       string           `json:"notes"`
+	CreatedBy   *int64           `json:"created_by"`
+	ConfirmedAt string           `json:"confirmed_at"`
+	FulfilledAt string           `json:"fulfilled_at"`
+	CancelledAt string           `json:"cancelled_at"`
+	CreatedAt   string           `json:"created_at"`
+	UpdatedAt   string           `json:"updated_at"`
+	Lines       []SalesOrderLine `json:"lines,omitempty"`
+}
+
+// one product on an order, at most one line per product
+type SalesOrderLine struct {
+	ID        int64           `json:"id"`
+	ProductID int64           `json:"product_id"`
+	Quantity  int             `json:"quantity"`
+	UnitPrice decimal.Decimal `json:"unit_price"` // products.price when the order was created
+}
+
+// what the order is worth at the captured prices
+func (order SalesOrder) Total() decimal.Decimal {
+	total := decimal.Zero
+	for _, line := range order.Lines {
+		total = total.Add(line.UnitPrice.Mul(decimal.NewFromInt(int64(line.Quantity))))
+	}
+	return total
+}
+
+// zero values match everything
+type SalesOrderFilter struct {
+	Status     string
+	CustomerID int64
+}
+
+// SalesOrderStore persists sales orders, every stock change runs in one transaction
+type SalesOrderStore interface {
+	// CreateSalesOrder inserts a draft and sets the IDs. The unit price of
+	// every line is copied from the product.
+	CreateSalesOrder(ctx context.Context, order *SalesOrder) error
+	// GetSalesOrder includes the lines
+	GetSalesOrder(ctx context.Context, id int64) (SalesOrder, error)
+	// ListSalesOrders leaves out the lines, newest first
+	ListSalesOrders(ctx context.Context, filter SalesOrderFilter) ([]SalesOrder, error)
+	// ConfirmSalesOrder reserves the stock of every line, all or nothing.
+	// Returns ErrInsufficientStock when a product does not have enough unreserved stock.
+	ConfirmSalesOrder(ctx context.Context, id int64) (SalesOrder, error)
+	// FulfilSalesOrder takes the reserved stock out with a sale movement per
+	// line and returns the new stock levels
+	FulfilSalesOrder(ctx context.Context, id int64, userID int64) (SalesOrder, []StockLevel, error)
+	// CancelSalesOrder releases the reservation of a confirmed order
+	CancelSalesOrder(ctx context.Context, id int64) (SalesOrder, error)
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// hand out copies so callers never share the lines with the store
+func cloneSalesOrder(order SalesOrder) SalesOrder {
+	order.Lines = slices.Clone(order.Lines)
+	return order
+}
+
+func (m *MemoryStore) CreateSalesOrder(ctx context.Context, order *SalesOrder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.customers[order.CustomerID]; !ok {
+		return fmt.Errorf("%w: Key (customer_id)=(%d) is not present in table \"customers\".", ErrInvalidReference, order.CustomerID)
+	}
+
+	seen := map[int64]bool{}
+	for i, line := range order.Lines {
+		product, ok := m.products[line.ProductID]
+		if !ok || product.DeletedAt != "" {
+			return fmt.Errorf("%w: product %d does not exist", ErrInvalidReference, line.ProductID)
+		}
+		if seen[line.ProductID] {
+			return fmt.Errorf("%w: Key (sales_order_id, product_id) already exists.", ErrDuplicate)
+		}
+		seen[line.ProductID] = true
+		order.Lines[i].UnitPrice = product.Price
+	}
+
+	m.lastSalesOrderID++
+	order.ID = m.lastSalesOrderID
+	order.Status = SOStatusDraft
+	order.ConfirmedAt = ""
+	order.FulfilledAt = ""
+	order.CancelledAt = ""
+	order.CreatedAt = memoryNow()
+	order.UpdatedAt = order.CreatedAt
+	for i := range order.Lines {
+		m.lastSalesOrderLineID++
+		order.Lines[i].ID = m.lastSalesOrderLineID
+	}
+
+	m.salesOrders[order.ID] = cloneSalesOrder(*order)
+	return nil
+}
+
+func (m *MemoryStore) GetSalesOrder(ctx context.Context, id int64) (SalesOrder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, ok := m.salesOrders[id]
+	if !ok {
+		return SalesOrder{}, ErrNotFound
+	}
+	order = cloneSalesOrder(order)
+	if order.Lines == nil {
+		order.Lines = []SalesOrderLine{}
+	}
+	return order, nil
+}
+
+func (m *MemoryStore) ListSalesOrders(ctx context.Context, filter SalesOrderFilter) ([]SalesOrder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := []SalesOrder{}
+	all := sortedByID(m.salesOrders)
+	for i := len(all) - 1; i >= 0; i-- {
+		order := all[i]
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		if filter.CustomerID != 0 && order.CustomerID != filter.CustomerID {
+			continue
+		}
+		order.Lines = nil
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// caller holds the write lock
+func (m *MemoryStore) transitionSalesOrder(id int64, status string, from ...string) (SalesOrder, error) {
+	order, ok := m.salesOrders[id]
+	if !ok {
+		return SalesOrder{}, ErrNotFound
+	}
+	if !slices.Contains(from, order.Status) {
+		return SalesOrder{}, fmt.Errorf("%w: sales order %d is %s", ErrInvalidState, id, order.Status)
+	}
+
+	order.Status = status
+	order.UpdatedAt = memoryNow()
+	switch status {
+	case SOStatusConfirmed:
+		order.ConfirmedAt = order.UpdatedAt
+	case SOStatusFulfilled:
+		order.FulfilledAt = order.UpdatedAt
+	case SOStatusCancelled:
+		order.CancelledAt = order.UpdatedAt
+	}
+	m.salesOrders[id] = order
+	return cloneSalesOrder(order), nil
+}
+
+func (m *MemoryStore) ConfirmSalesOrder(ctx context.Context, id int64) (SalesOrder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.salesOrders[id]
+	if !ok {
+		return SalesOrder{}, ErrNotFound
+	}
+	if order.Status != SOStatusDraft {
+		return SalesOrder{}, fmt.Errorf("%w: sales order %d is %s", ErrInvalidState, id, order.Status)
+	}
+
+	// check every line before reserving anything
+	for _, line := range order.Lines {
+		if m.products[line.ProductID].Stock-m.reserved[line.ProductID] < line.Quantity {
+			return SalesOrder{}, fmt.Errorf("%w: product %d has fewer than %d unreserved", ErrInsufficientStock, line.ProductID, line.Quantity)
+		}
+	}
+	for _, line := range order.Lines {
+		m.reserved[line.ProductID] += line.Quantity
+	}
+
+	return m.transitionSalesOrder(id, SOStatusConfirmed, SOStatusDraft)
+}
+
+func (m *MemoryStore) FulfilSalesOrder(ctx context.Context, id int64, userID int64) (SalesOrder, []StockLevel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.salesOrders[id]
+	if !ok {
+		return SalesOrder{}, nil, ErrNotFound
+	}
+	if order.Status != SOStatusConfirmed {
+		return SalesOrder{}, nil, fmt.Errorf("%w: sales order %d is %s", ErrInvalidState, id, order.Status)
+	}
+
+	change := StockChange{Reason: ReasonSale, UserID: userID, Reference: salesOrderReference(id)}
+	levels := []StockLevel{}
+	for _, line := range order.Lines {
+		m.reserved[line.ProductID] -= line.Quantity
+		levels = append(levels, m.writeStockLocked(m.products[line.ProductID], -line.Quantity, change))
+	}
+
+	order, err := m.transitionSalesOrder(id, SOStatusFulfilled, SOStatusConfirmed)
+	return order, levels, err
+}
+
+func (m *MemoryStore) CancelSalesOrder(ctx context.Context, id int64) (SalesOrder, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, err := m.transitionSalesOrder(id, SOStatusCancelled, SOStatusDraft, SOStatusConfirmed)
+	if err != nil {
+		return order, err
+	}
+	if order.ConfirmedAt != "" {
+		for _, line := range order.Lines {
+			m.reserved[line.ProductID] -= line.Quantity
+		}
+	}
+	return order, nil
+}
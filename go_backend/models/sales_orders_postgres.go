@@ -0,0 +1,264 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// PostgresSalesOrderStore implements SalesOrderStore on the sales_orders and
+// sales_order_lines tables, reservations live in products.reserved
+type PostgresSalesOrderStore struct {
+	DB *sql.DB
+}
+
+const salesOrderColumns = "id, customer_id, status, notes, created_by, confirmed_at, fulfilled_at, cancelled_at, created_at, updated_at"
+
+func scanSalesOrder(row scanner) (SalesOrder, error) {
+	var (
+		order       SalesOrder
+		createdBy   sql.NullInt64
+		confirmedAt sql.NullString
+		fulfilledAt sql.NullString
+		cancelledAt sql.NullString
+	)
+	err := row.Scan(&order.ID, &order.CustomerID, &order.Status, &order.Notes, &createdBy, &confirmedAt, &fulfilledAt, &cancelledAt, &order.CreatedAt, &order.UpdatedAt)
+	if createdBy.Valid {
+		order.CreatedBy = &createdBy.Int64
+	}
+	order.ConfirmedAt = confirmedAt.String
+	order.FulfilledAt = fulfilledAt.String
+	order.CancelledAt = cancelledAt.String
+	return order, err
+}
+
+func (s *PostgresSalesOrderStore) CreateSalesOrder(ctx context.Context, order *SalesOrder) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "INSERT INTO sales_orders (customer_id, status, notes, created_by) VALUES ($1, $2, $3, $4) RETURNING " + salesOrderColumns
+	created, err := scanSalesOrder(tx.QueryRowContext(ctx, query, order.CustomerID, SOStatusDraft, order.Notes, order.CreatedBy))
+	if err != nil {
+		return mapError(err)
+	}
+
+	for i := range order.Lines {
+		line := &order.Lines[i]
+
+		// the price is captured now, later price changes do not touch the order
+		err := tx.QueryRowContext(ctx, "SELECT price FROM products WHERE id = $1 AND deleted_at IS NULL", line.ProductID).Scan(&line.UnitPrice)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: product %d does not exist", ErrInvalidReference, line.ProductID)
+		}
+		if err != nil {
+			return err
+		}
+
+		query := "INSERT INTO sales_order_lines (sales_order_id, product_id, quantity, unit_price) VALUES ($1, $2, $3, $4) RETURNING id"
+		if err := tx.QueryRowContext(ctx, query, created.ID, line.ProductID, line.Quantity, line.UnitPrice).Scan(&line.ID); err != nil {
+			return mapError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	created.Lines = order.Lines
+	*order = created
+	return nil
+}
+
+func getSalesOrder(ctx context.Context, q querier, id int64) (SalesOrder, error) {
+	query := "SELECT " + salesOrderColumns + " FROM sales_orders WHERE id = $1"
+	order, err := scanSalesOrder(q.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return order, mapError(err)
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT id, product_id, quantity, unit_price FROM sales_order_lines WHERE sales_order_id = $1 ORDER BY id", id)
+	if err != nil {
+		return order, err
+	}
+	defer rows.Close()
+
+	order.Lines = []SalesOrderLine{}
+	for rows.Next() {
+		var line SalesOrderLine
+		if err := rows.Scan(&line.ID, &line.ProductID, &line.Quantity, &line.UnitPrice); err != nil {
+			return order, err
+		}
+		order.Lines = append(order.Lines, line)
+	}
+	return order, rows.Err()
+}
+
+func (s *PostgresSalesOrderStore) GetSalesOrder(ctx context.Context, id int64) (SalesOrder, error) {
+	return getSalesOrder(ctx, s.DB, id)
+}
+
+func (s *PostgresSalesOrderStore) ListSalesOrders(ctx context.Context, filter SalesOrderFilter) ([]SalesOrder, error) {
+	conditions := []string{"TRUE"}
+	args := []any{}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.CustomerID != 0 {
+		args = append(args, filter.CustomerID)
+		conditions = append(conditions, fmt.Sprintf("customer_id = $%d", len(args)))
+	}
+
+	query := "SELECT " + salesOrderColumns + " FROM sales_orders WHERE " + strings.Join(conditions, " AND ") + " ORDER BY id DESC"
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []SalesOrder{}
+	for rows.Next() {
+		order, err := scanSalesOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, rows.Err()
+}
+
+// lock the order row and load it, fails with ErrInvalidState unless it is in one of from
+func lockSalesOrder(ctx context.Context, tx *sql.Tx, id int64, from ...string) (SalesOrder, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status FROM sales_orders WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err != nil {
+		return SalesOrder{}, mapError(err)
+	}
+	if !slices.Contains(from, status) {
+		return SalesOrder{}, fmt.Errorf("%w: sales order %d is %s", ErrInvalidState, id, status)
+	}
+	return getSalesOrder(ctx, tx, id)
+}
+
+// lines in product order, so concurrent orders lock products in the same order
+func linesByProduct(order SalesOrder) []SalesOrderLine {
+	lines := slices.Clone(order.Lines)
+	slices.SortFunc(lines, func(a, b SalesOrderLine) int { return cmp.Compare(a.ProductID, b.ProductID) })
+	return lines
+}
+
+// set the new status and the matching timestamp column, then reload the order
+func setSalesOrderStatus(ctx context.Context, tx *sql.Tx, id int64, status string, timestampColumn string) (SalesOrder, error) {
+	query := "UPDATE sales_orders SET status = $1, " + timestampColumn + " = NOW(), updated_at = NOW() WHERE id = $2"
+	if _, err := tx.ExecContext(ctx, query, status, id); err != nil {
+		return SalesOrder{}, err
+	}
+	return getSalesOrder(ctx, tx, id)
+}
+
+func (s *PostgresSalesOrderStore) ConfirmSalesOrder(ctx context.Context, id int64) (SalesOrder, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return SalesOrder{}, err
+	}
+	defer tx.Rollback()
+
+	order, err := lockSalesOrder(ctx, tx, id, SOStatusDraft)
+	if err != nil {
+		return order, err
+	}
+
+	for _, line := range linesByProduct(order) {
+		// the row lock on the product makes check and reserve one step
+		query := "UPDATE products SET reserved = reserved + $1, updated_at = NOW() WHERE id = $2 AND stock - reserved >= $1"
+		result, err := tx.ExecContext(ctx, query, line.Quantity, line.ProductID)
+		if err != nil {
+			return order, err
+		}
+		if expectOneRow(result) != nil {
+			return order, fmt.Errorf("%w: product %d has fewer than %d unreserved", ErrInsufficientStock, line.ProductID, line.Quantity)
+		}
+	}
+
+	order, err = setSalesOrderStatus(ctx, tx, id, SOStatusConfirmed, "confirmed_at")
+	if err != nil {
+		return order, err
+	}
+	return order, tx.Commit()
+}
+
+func (s *PostgresSalesOrderStore) FulfilSalesOrder(ctx context.Context, id int64, userID int64) (SalesOrder, []StockLevel, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return SalesOrder{}, nil, err
+	}
+	defer tx.Rollback()
+
+	order, err := lockSalesOrder(ctx, tx, id, SOStatusConfirmed)
+	if err != nil {
+		return order, nil, err
+	}
+
+	change := StockChange{Reason: ReasonSale, UserID: userID, Reference: salesOrderReference(id)}
+	levels := []StockLevel{}
+	for _, line := range linesByProduct(order) {
+		// the reservation turns into a real decrement, stock never drops below what is reserved
+		query := `UPDATE products SET stock = stock - $1, reserved = reserved - $1, updated_at = NOW()
+			WHERE id = $2
+			RETURNING ` + stockLevelColumns
+		level, err := scanStockLevel(tx.QueryRowContext(ctx, query, line.Quantity, line.ProductID))
+		if err != nil {
+			return order, nil, mapError(err)
+		}
+
+		if err := insertMovement(ctx, tx, line.ProductID, -line.Quantity, change); err != nil {
+			return order, nil, err
+		}
+		levels = append(levels, level)
+	}
+
+	order, err = setSalesOrderStatus(ctx, tx, id, SOStatusFulfilled, "fulfilled_at")
+	if err != nil {
+		return order, nil, err
+	}
+	return order, levels, tx.Commit()
+}
+
+func (s *PostgresSalesOrderStore) CancelSalesOrder(ctx context.Context, id int64) (SalesOrder, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return SalesOrder{}, err
+	}
+	defer tx.Rollback()
+
+	order, err := lockSalesOrder(ctx, tx, id, SOStatusDraft, SOStatusConfirmed)
+	if err != nil {
+		return order, err
+	}
+
+	if order.Status == SOStatusConfirmed {
+		for _, line := range linesByProduct(order) {
+			query := "UPDATE products SET reserved = reserved - $1, updated_at = NOW() WHERE id = $2"
+			if _, err := tx.ExecContext(ctx, query, line.Quantity, line.ProductID); err != nil {
+				return order, err
+			}
+		}
+	}
+
+	order, err = setSalesOrderStatus(ctx, tx, id, SOStatusCancelled, "cancelled_at")
+	if err != nil {
+		return order, err
+	}
+	return order, tx.Commit()
+}
+
+// reference written on the stock movements of a sales order
+func salesOrderReference(id int64) string {
+	return fmt.Sprintf("SO-%d", id)
+}
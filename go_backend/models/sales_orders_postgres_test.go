@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+var salesOrderRowColumns = []string{"id", "customer_id", "status", "notes", "created_by", "confirmed_at", "fulfilled_at", "cancelled_at", "created_at", "updated_at"}
+var salesOrderLineRowColumns = []string{"id", "product_id", "quantity", "unit_price"}
+
+func TestPostgresCreateSalesOrderCapturesPrice(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	created := "2024-08-30T12:00:00Z"
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO sales_orders").WithArgs(1, SOStatusDraft, "", nil).
+		WillReturnRows(sqlmock.NewRows(salesOrderRowColumns).AddRow(1, 1, SOStatusDraft, "", nil, nil, nil, nil, created, created))
+	mock.ExpectQuery("SELECT price FROM products WHERE id = \\$1").WithArgs(5).
+		WillReturnRows(sqlmock.NewRows([]string{"price"}).AddRow("2.50"))
+	mock.ExpectQuery("INSERT INTO sales_order_lines").WithArgs(1, 5, 4, decimal.RequireFromString("2.50")).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	store := &PostgresSalesOrderStore{DB: db}
+	order := SalesOrder{CustomerID: 1, Lines: []SalesOrderLine{{ProductID: 5, Quantity: 4}}}
+	assert.NoError(t, store.CreateSalesOrder(context.Background(), &order))
+	assert.Equal(t, int64(1), order.ID)
+	assert.True(t, decimal.NewFromInt(10).Equal(order.Total()))
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresConfirmSalesOrderInsufficientStock(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	created := "2024-08-30T12:00:00Z"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM sales_orders WHERE id = \\$1 FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(SOStatusDraft))
+	mock.ExpectQuery("FROM sales_orders WHERE id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(salesOrderRowColumns).AddRow(1, 1, SOStatusDraft, "", nil, nil, nil, nil, created, created))
+	mock.ExpectQuery("FROM sales_order_lines").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(salesOrderLineRowColumns).AddRow(2, 7, 1, "1.00").AddRow(1, 5, 4, "2.50"))
+
+	// lines are reserved in product order, the second one runs short
+	mock.ExpectExec("UPDATE products SET reserved = reserved \\+ \\$1, updated_at = NOW\\(\\) WHERE id = \\$2 AND stock - reserved >= \\$1").WithArgs(4, 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE products SET reserved = reserved \\+ \\$1").WithArgs(1, 7).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	store := &PostgresSalesOrderStore{DB: db}
+	_, err = store.ConfirmSalesOrder(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresFulfilSalesOrder(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	created := "2024-08-30T12:00:00Z"
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status FROM sales_orders WHERE id = \\$1 FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(SOStatusConfirmed))
+	mock.ExpectQuery("FROM sales_orders WHERE id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(salesOrderRowColumns).AddRow(1, 1, SOStatusConfirmed, "", nil, created, nil, nil, created, created))
+	mock.ExpectQuery("FROM sales_order_lines").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(salesOrderLineRowColumns).AddRow(1, 5, 4, "2.50"))
+
+	mock.ExpectQuery("UPDATE products SET stock = stock - \\$1, reserved = reserved - \\$1").WithArgs(4, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "minimum_stock", "reserved"}).AddRow(5, "testproduct", 6, 1, 0))
+	mock.ExpectExec("INSERT INTO stock_movements").WithArgs(5, -4, ReasonSale, 3, "SO-1", 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE sales_orders SET status = \\$1, fulfilled_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE id = \\$2").WithArgs(SOStatusFulfilled, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery("FROM sales_orders WHERE id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(salesOrderRowColumns).AddRow(1, 1, SOStatusFulfilled, "", nil, created, created, nil, created, created))
+	mock.ExpectQuery("FROM sales_order_lines").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows(salesOrderLineRowColumns).AddRow(1, 5, 4, "2.50"))
+	mock.ExpectCommit()
+
+	store := &PostgresSalesOrderStore{DB: db}
+	order, levels, err := store.FulfilSalesOrder(context.Background(), 1, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, SOStatusFulfilled, order.Status)
+	assert.Equal(t, 6, levels[0].Stock)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+// current stock of one product
+type StockLevel struct {
+	ProductID    int64  `json:"product_id"`
+	Name         string `json:"name"`
+	Stock        int    `json:"stock"`
+	MinimumStock int    `json:"minimum_stock"`
+	Reserved     int    `json:"reserved"` // held for confirmed sales orders
+}
+
+// a product at or below its minimum stock, with who to reorder from
+type LowStockItem struct {
+	ProductID     int64  `json:"product_id"`
+	Name          string `json:"name"`
+	Stock         int    `json:"stock"`
+	MinimumStock  int    `json:"minimum_stock"`
+	SupplierID    int64  `json:"supplier_id"`
+	SupplierName  string `json:"supplier_name"`
+	SupplierEmail string `json:"supplier_email"`
+	SupplierPhone string `json:"supplier_phone"`
+}
+
+// stock at or below the minimum counts as low
+func (level StockLevel) IsLow() bool {
+	return level.Stock <= level.MinimumStock
+}
+
+// why the stock of a product changed
+const (
+	ReasonReceipt    = "receipt"
+	ReasonSale       = "sale"
+	ReasonAdjustment = "adjustment"
+	ReasonDamage     = "damage"
+	ReasonReturn     = "return"
+)
+
+func ValidMovementReason(reason string) bool {
+	switch reason {
+	case ReasonReceipt, ReasonSale, ReasonAdjustment, ReasonDamage, ReasonReturn:
+		return true
+	}
+	return false
+}
+
+// one row of the stock ledger, the stock of a product is the sum of its deltas
+type StockMovement struct {
+	ID        int64  `json:"id"`
+	ProductID int64  `json:"product_id"`
+	Delta     int    `json:"delta"`
+	Reason    string `json:"reason"`
+	UserID    *int64 `json:"user_id"` // nil for changes without a user, e.g. the opening balance
+	Reference string `json:"reference"`
+	ReceiptID *int64 `json:"receipt_id"` // the purchase order receipt a receipt booked, nil otherwise
+	CreatedAt string `json:"created_at"`
+}
+
+// who changed the stock and why, recorded with the movement
+type StockChange struct {
+	Reason    string
+	UserID    int64 // 0 when no user is behind the change
+	Reference string
+	ReceiptID int64 // the purchase order receipt booked, 0 for other changes
+}
+
+// recorded for the stock a product is created with
+var openingBalance = StockChange{Reason: ReasonAdjustment, Reference: "opening balance"}
+
+// limits the history to From <= created_at < To, zero times are unbounded
+type MovementFilter struct {
+	From time.Time
+	To   time.Time
+}
+
+// StockStore owns every write to products.stock, each write also records a StockMovement
+type StockStore interface {
+	GetStockLevel(ctx context.Context, productID int64) (StockLevel, error)
+	ListStockLevels(ctx context.Context) ([]StockLevel, error)
+	// SetStock overwrites the count, e.g. after a stock take, and records the difference, if there is one.
+	// Returns ErrInsufficientStock instead of counting less than is reserved.
+	SetStock(ctx context.Context, productID int64, stock int, change StockChange) (StockLevel, error)
+	// AdjustStock adds delta (negative to remove) in one atomic step.
+	// Returns ErrInsufficientStock instead of taking reserved stock or going below zero.
+	AdjustStock(ctx context.Context, productID int64, delta int, change StockChange) (StockLevel, error)
+	// ListLowStock returns every product with stock <= minimum_stock
+	ListLowStock(ctx context.Context) ([]LowStockItem, error)
+	// ListMovements returns the ledger of a product, oldest first
+	ListMovements(ctx context.Context, productID int64, filter MovementFilter) ([]StockMovement, error)
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// caller holds a lock
+func (m *MemoryStore) stockLevelOf(product Product) StockLevel {
+	return StockLevel{ProductID: product.ID, Name: product.Name, Stock: product.Stock, MinimumStock: product.MinimumStock, Reserved: m.reserved[product.ID]}
+}
+
+// caller holds the write lock
+func (m *MemoryStore) recordMovement(productID int64, delta int, change StockChange) {
+	m.lastMovementID++
+	movement := StockMovement{
+		ID:        m.lastMovementID,
+		ProductID: productID,
+		Delta:     delta,
+		Reason:    change.Reason,
+		Reference: change.Reference,
+		CreatedAt: memoryNow(),
+	}
+	if change.UserID != 0 {
+		userID := change.UserID
+		movement.UserID = &userID
+	}
+	if change.ReceiptID != 0 {
+		receiptID := change.ReceiptID
+		movement.ReceiptID = &receiptID
+	}
+	m.movements = append(m.movements, movement)
+}
+
+// caller holds the write lock, the memory version of adjustStockTx
+func (m *MemoryStore) adjustStockLocked(productID int64, delta int, change StockChange) (StockLevel, error) {
+	product, ok := m.products[productID]
+	if !ok || product.DeletedAt != "" {
+		return StockLevel{}, ErrNotFound
+	}
+	// reserved stock belongs to confirmed sales orders
+	if product.Stock+delta < m.reserved[productID] {
+		return StockLevel{}, ErrInsufficientStock
+	}
+	return m.writeStockLocked(product, delta, change), nil
+}
+
+// caller holds the write lock and has checked the new stock
+func (m *MemoryStore) writeStockLocked(product Product, delta int, change StockChange) StockLevel {
+	product.Stock += delta
+	product.UpdatedAt = memoryNow()
+	product.Version++
+	m.products[product.ID] = product
+	m.recordMovement(product.ID, delta, change)
+	return m.stockLevelOf(product)
+}
+
+func (m *MemoryStore) GetStockLevel(ctx context.Context, productID int64) (StockLevel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	product, ok := m.products[productID]
+	if !ok || product.DeletedAt != "" {
+		return StockLevel{}, ErrNotFound
+	}
+	return m.stockLevelOf(product), nil
+}
+
+func (m *MemoryStore) ListStockLevels(ctx context.Context) ([]StockLevel, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	levels := make([]StockLevel, 0, len(m.products))
+	for _, product := range sortedByID(m.products) {
+		if product.DeletedAt != "" {
+			continue
+		}
+		levels = append(levels, m.stockLevelOf(product))
+	}
+	return levels, nil
+}
+
+func (m *MemoryStore) SetStock(ctx context.Context, productID int64, stock int, change StockChange) (StockLevel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	product, ok := m.products[productID]
+	if !ok || product.DeletedAt != "" {
+		return StockLevel{}, ErrNotFound
+	}
+	if stock < 0 {
+		return StockLevel{}, ErrInsufficientStock
+	}
+	// reserved stock belongs to confirmed sales orders, they must stay fulfillable
+	if reserved := m.reserved[productID]; stock < reserved {
+		return StockLevel{}, fmt.Errorf("%w: %d are reserved for confirmed sales orders", ErrInsufficientStock, reserved)
+	}
+	// a count that matches changes nothing, not even the ledger
+	if stock == product.Stock {
+		return m.stockLevelOf(product), nil
+	}
+	return m.writeStockLocked(product, stock-product.Stock, change), nil
+}
+
+func (m *MemoryStore) AdjustStock(ctx context.Context, productID int64, delta int, change StockChange) (StockLevel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.adjustStockLocked(productID, delta, change)
+}
+
+func (m *MemoryStore) ListLowStock(ctx context.Context) ([]LowStockItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	items := []LowStockItem{}
+	for _, product := range sortedByID(m.products) {
+		if product.DeletedAt != "" || !m.stockLevelOf(product).IsLow() {
+			continue
+		}
+		supplier := m.suppliers[product.SupplierID]
+		items = append(items, LowStockItem{
+			ProductID:     product.ID,
+			Name:          product.Name,
+			Stock:         product.Stock,
+			MinimumStock:  product.MinimumStock,
+			SupplierID:    supplier.ID,
+			SupplierName:  supplier.Name,
+			SupplierEmail: supplier.ContactEmail,
+			SupplierPhone: supplier.Phone,
+		})
+	}
+	return items, nil
+}
+
+func (m *MemoryStore) ListMovements(ctx context.Context, productID int64, filter MovementFilter) ([]StockMovement, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.products[productID]; !ok {
+		return nil, ErrNotFound
+	}
+
+	movements := []StockMovement{}
+	for _, movement := range m.movements {
+		if movement.ProductID != productID {
+			continue
+		}
+		createdAt, err := time.Parse(time.RFC3339Nano, movement.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if !filter.From.IsZero() && createdAt.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !createdAt.Before(filter.To) {
+			continue
+		}
+		movements = append(movements, movement)
+	}
+	return movements, nil
+}
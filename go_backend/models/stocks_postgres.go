@@ -0,0 +1,230 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// PostgresStockStore implements StockStore on the products and stock_movements tables
+type PostgresStockStore struct {
+	DB *sql.DB
+}
+
+const stockLevelColumns = "id, name, stock, minimum_stock, reserved"
+
+const movementColumns = "id, product_id, delta, reason, user_id, reference, receipt_id, created_at"
+
+func scanStockLevel(row scanner) (StockLevel, error) {
+	var level StockLevel
+	err := row.Scan(&level.ProductID, &level.Name, &level.Stock, &level.MinimumStock, &level.Reserved)
+	return level, err
+}
+
+func scanMovement(row scanner) (StockMovement, error) {
+	var (
+		movement  StockMovement
+		userID    sql.NullInt64
+		receiptID sql.NullInt64
+	)
+	err := row.Scan(&movement.ID, &movement.ProductID, &movement.Delta, &movement.Reason, &userID, &movement.Reference, &receiptID, &movement.CreatedAt)
+	if userID.Valid {
+		movement.UserID = &userID.Int64
+	}
+	if receiptID.Valid {
+		movement.ReceiptID = &receiptID.Int64
+	}
+	return movement, err
+}
+
+// anything that runs queries, *sql.DB or *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func productExists(ctx context.Context, q querier, productID int64) (bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE id = $1)", productID).Scan(&exists)
+	return exists, err
+}
+
+// like productExists, but deleted products do not count
+func activeProductExists(ctx context.Context, q querier, productID int64) (bool, error) {
+	var exists bool
+	err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", productID).Scan(&exists)
+	return exists, err
+}
+
+// write one ledger row, must run in the transaction that changed products.stock
+func insertMovement(ctx context.Context, tx *sql.Tx, productID int64, delta int, change StockChange) error {
+	query := "INSERT INTO stock_movements (product_id, delta, reason, user_id, reference, receipt_id) VALUES ($1, $2, $3, NULLIF($4, 0), $5, NULLIF($6, 0))"
+	_, err := tx.ExecContext(ctx, query, productID, delta, change.Reason, change.UserID, change.Reference, change.ReceiptID)
+	return mapError(err)
+}
+
+// relative update plus ledger row inside tx, shared by every flow that moves stock
+func adjustStockTx(ctx context.Context, tx *sql.Tx, productID int64, delta int, change StockChange) (StockLevel, error) {
+	// relative update in a single statement so concurrent adjustments never overwrite each other,
+	// reserved stock belongs to confirmed sales orders and is never adjusted away
+	query := `UPDATE products SET stock = stock + $1, updated_at = NOW()
+		WHERE id = $2 AND deleted_at IS NULL AND stock + $1 >= reserved
+		RETURNING ` + stockLevelColumns
+	level, err := scanStockLevel(tx.QueryRowContext(ctx, query, delta, productID))
+	if err == sql.ErrNoRows {
+		// either the product is missing or deleted, or the stock would drop below what is reserved
+		exists, err := activeProductExists(ctx, tx, productID)
+		if err != nil {
+			return level, err
+		}
+		if exists {
+			return level, ErrInsufficientStock
+		}
+		return level, ErrNotFound
+	}
+	if err != nil {
+		return level, mapError(err)
+	}
+
+	return level, insertMovement(ctx, tx, productID, delta, change)
+}
+
+func (s *PostgresStockStore) GetStockLevel(ctx context.Context, productID int64) (StockLevel, error) {
+	query := "SELECT " + stockLevelColumns + " FROM products WHERE id = $1 AND deleted_at IS NULL"
+	level, err := scanStockLevel(s.DB.QueryRowContext(ctx, query, productID))
+	return level, mapError(err)
+}
+
+func (s *PostgresStockStore) ListStockLevels(ctx context.Context) ([]StockLevel, error) {
+	rows, err := s.DB.QueryContext(ctx, "SELECT "+stockLevelColumns+" FROM products WHERE deleted_at IS NULL ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := []StockLevel{}
+	for rows.Next() {
+		level, err := scanStockLevel(rows)
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, rows.Err()
+}
+
+func (s *PostgresStockStore) SetStock(ctx context.Context, productID int64, stock int, change StockChange) (StockLevel, error) {
+	var level StockLevel
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return level, err
+	}
+	defer tx.Rollback()
+
+	// lock the row so the recorded delta matches what was overwritten
+	var current, reserved int
+	err = tx.QueryRowContext(ctx, "SELECT stock, reserved FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", productID).Scan(&current, &reserved)
+	if err != nil {
+		return level, mapError(err)
+	}
+	// reserved stock belongs to confirmed sales orders, they must stay fulfillable
+	if stock < reserved {
+		return level, fmt.Errorf("%w: %d are reserved for confirmed sales orders", ErrInsufficientStock, reserved)
+	}
+	// a count that matches changes nothing, not even the ledger
+	if stock == current {
+		level, err = scanStockLevel(tx.QueryRowContext(ctx, "SELECT "+stockLevelColumns+" FROM products WHERE id = $1", productID))
+		return level, mapError(err)
+	}
+
+	query := "UPDATE products SET stock = $1, updated_at = NOW() WHERE id = $2 RETURNING " + stockLevelColumns
+	level, err = scanStockLevel(tx.QueryRowContext(ctx, query, stock, productID))
+	if err != nil {
+		return level, mapError(err)
+	}
+
+	if err := insertMovement(ctx, tx, productID, stock-current, change); err != nil {
+		return level, err
+	}
+
+	return level, tx.Commit()
+}
+
+func (s *PostgresStockStore) AdjustStock(ctx context.Context, productID int64, delta int, change StockChange) (StockLevel, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return StockLevel{}, err
+	}
+	defer tx.Rollback()
+
+	level, err := adjustStockTx(ctx, tx, productID, delta, change)
+	if err != nil {
+		return level, err
+	}
+
+	return level, tx.Commit()
+}
+
+func (s *PostgresStockStore) ListLowStock(ctx context.Context) ([]LowStockItem, error) {
+	query := `SELECT p.id, p.name, p.stock, p.minimum_stock, s.id, s.name, COALESCE(s.contact_email, ''), COALESCE(s.phone, '')
+		FROM products p JOIN supplier s ON s.id = p.supplier_id
+		WHERE p.stock <= p.minimum_stock AND p.deleted_at IS NULL
+		ORDER BY p.id`
+	rows, err := s.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []LowStockItem{}
+	for rows.Next() {
+		var item LowStockItem
+		err := rows.Scan(&item.ProductID, &item.Name, &item.Stock, &item.MinimumStock, &item.SupplierID, &item.SupplierName, &item.SupplierEmail, &item.SupplierPhone)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *PostgresStockStore) ListMovements(ctx context.Context, productID int64, filter MovementFilter) ([]StockMovement, error) {
+	exists, err := productExists(ctx, s.DB, productID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	conditions := []string{"product_id = $1"}
+	args := []any{productID}
+	if !filter.From.IsZero() {
+		args = append(args, filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := "SELECT " + movementColumns + " FROM stock_movements WHERE " + strings.Join(conditions, " AND ") + " ORDER BY created_at, id"
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []StockMovement{}
+	for rows.Next() {
+		movement, err := scanMovement(rows)
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, movement)
+	}
+	return movements, rows.Err()
+}
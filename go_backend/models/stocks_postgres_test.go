@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+var movementRowColumns = []string{"id", "product_id", "delta", "reason", "user_id", "reference", "receipt_id", "created_at"}
+
+func TestPostgresAdjustStock(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	query := "UPDATE products SET stock = stock \\+ \\$1, updated_at = NOW\\(\\) WHERE id = \\$2 AND deleted_at IS NULL AND stock \\+ \\$1 >= reserved"
+	mock.ExpectBegin()
+	mock.ExpectQuery(query).WithArgs(-2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "minimum_stock", "reserved"}).AddRow(1, "testproduct", 3, 1, 0))
+	mock.ExpectExec("INSERT INTO stock_movements \\(product_id, delta, reason, user_id, reference, receipt_id\\)").
+		WithArgs(1, -2, ReasonSale, 4, "invoice 12", 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	store := &PostgresStockStore{DB: db}
+	level, err := store.AdjustStock(context.Background(), 1, -2, StockChange{Reason: ReasonSale, UserID: 4, Reference: "invoice 12"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, level.Stock)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresAdjustStockMissingProduct(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE products SET stock = stock \\+ \\$1").WithArgs(5, 9).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "minimum_stock", "reserved"}))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM products WHERE id = \\$1 AND deleted_at IS NULL\\)").WithArgs(9).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	store := &PostgresStockStore{DB: db}
+	_, err = store.AdjustStock(context.Background(), 9, 5, StockChange{Reason: ReasonReceipt})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresSetStockRecordsDifference(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT stock, reserved FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "reserved"}).AddRow(10, 2))
+	mock.ExpectQuery("UPDATE products SET stock = \\$1").WithArgs(7, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "minimum_stock", "reserved"}).AddRow(1, "testproduct", 7, 1, 0))
+	mock.ExpectExec("INSERT INTO stock_movements").WithArgs(1, -3, ReasonAdjustment, 0, "", 0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	// counting what is already there writes nothing
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT stock, reserved FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "reserved"}).AddRow(7, 2))
+	mock.ExpectQuery("SELECT (.+) FROM products WHERE id = \\$1").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock", "minimum_stock", "reserved"}).AddRow(1, "testproduct", 7, 1, 2))
+	mock.ExpectRollback()
+
+	store := &PostgresStockStore{DB: db}
+	_, err = store.SetStock(context.Background(), 1, 7, StockChange{Reason: ReasonAdjustment})
+	assert.NoError(t, err)
+	level, err := store.SetStock(context.Background(), 1, 7, StockChange{Reason: ReasonAdjustment})
+	assert.NoError(t, err)
+	assert.Equal(t, 7, level.Stock)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresSetStockBelowReserved(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// 4 are reserved for confirmed sales orders, counting 3 would leave one unfulfillable
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT stock, reserved FROM products WHERE id = \\$1 AND deleted_at IS NULL FOR UPDATE").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"stock", "reserved"}).AddRow(10, 4))
+	mock.ExpectRollback()
+
+	store := &PostgresStockStore{DB: db}
+	_, err = store.SetStock(context.Background(), 1, 3, StockChange{Reason: ReasonAdjustment})
+	assert.ErrorIs(t, err, ErrInsufficientStock)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresListMovements(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	from := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	rows := sqlmock.NewRows(movementRowColumns).
+		AddRow(1, 1, 5, ReasonReceipt, 2, "PO-1", 6, "2024-08-02T10:00:00Z").
+		AddRow(2, 1, -1, ReasonSale, nil, "", nil, "2024-08-03T10:00:00Z")
+	mock.ExpectQuery("FROM stock_movements WHERE product_id = \\$1 AND created_at >= \\$2 AND created_at < \\$3 ORDER BY created_at, id").
+		WithArgs(1, from, to).WillReturnRows(rows)
+
+	store := &PostgresStockStore{DB: db}
+	movements, err := store.ListMovements(context.Background(), 1, MovementFilter{From: from, To: to})
+	assert.NoError(t, err)
+	assert.Len(t, movements, 2)
+	assert.EqualValues(t, 2, *movements[0].UserID)
+	assert.Nil(t, movements[1].UserID)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
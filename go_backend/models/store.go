@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Pinger reports whether the backing storage is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Stores bundles every store the handlers use
+type Stores struct {
+	Products       ProductStore
+	Suppliers      SupplierStore
+	Users          UserStore
+	Stocks         StockStore
+	PurchaseOrders PurchaseOrderStore
+	Customers      CustomerStore
+	SalesOrders    SalesOrderStore
+	Analytics      AnalyticsStore
+	Prices         PriceStore
+	Health         Pinger
+}
+
+// NewPostgresStores backs every store with the shared pool
+func NewPostgresStores(db *sql.DB) Stores {
+	return Stores{
+		Products:       &PostgresProductStore{DB: db},
+		Suppliers:      &PostgresSupplierStore{DB: db},
+		Users:          &PostgresUserStore{DB: db},
+		Stocks:         &PostgresStockStore{DB: db},
+		PurchaseOrders: &PostgresPurchaseOrderStore{DB: db},
+		Customers:      &PostgresCustomerStore{DB: db},
+		SalesOrders:    &PostgresSalesOrderStore{DB: db},
+		Analytics:      &PostgresAnalyticsStore{DB: db},
+		Prices:         &PostgresPriceStore{DB: db},
+		Health:         postgresPinger{db: db},
+	}
+}
+
+type postgresPinger struct {
+	db *sql.DB
+}
+
+func (p postgresPinger) Ping(ctx context.Context) error {
+	return PingDatabase(ctx, p.db)
+}
@@ -0,0 +1,68 @@
+package models
+
+import "context"
+
+type Supplier struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	ContactEmail string `json:"contact_email"`
+	Phone        string `json:"phone"`
+	LeadTimeDays int    `json:"lead_time_days"` // days between ordering and delivery
+	CreatedAt    string `json:"created_at"`
+	UpdatedAt    string `json:"updated_at"`
+	Version      int64  `json:"version"`    // bumped by every change, the ETag of the API
+	DeletedAt    string `json:"deleted_at"` // empty unless deleted, see RestoreSupplier and PurgeSupplier
+}
+
+// lead time of suppliers created without one
+const DefaultLeadTimeDays = 7
+
+// SupplierPatch changes the fields that are not nil, an empty ContactEmail or Phone removes it
+type SupplierPatch struct {
+	Name         *string
+	ContactEmail *string
+	Phone        *string
+	LeadTimeDays *int
+}
+
+// SupplierFilter narrows, sorts and pages ListSuppliers, zero values do not filter
+type SupplierFilter struct {
+	Name           string // part of the name, case insensitive
+	IncludeDeleted bool
+	Sort           Sort
+	Page           Page
+}
+
+// SupplierDeletion says what DeleteSupplier does with the products of the supplier,
+// the zero value keeps the supplier while it has products that are not deleted
+type SupplierDeletion struct {
+	ReassignTo int64 // moves every product, deleted or not, to this supplier
+	Cascade    bool  // deletes the products along with the supplier
+}
+
+// SupplierStore persists suppliers
+type SupplierStore interface {
+	// CreateSupplier inserts the supplier and sets its ID
+	CreateSupplier(ctx context.Context, supplier *Supplier) error
+	// GetSupplier also returns deleted suppliers, check DeletedAt
+	GetSupplier(ctx context.Context, id int64) (Supplier, error)
+	// ListSuppliers returns one page of the matching suppliers and how many match in total
+	ListSuppliers(ctx context.Context, filter SupplierFilter) ([]Supplier, int, error)
+	// The changes below return ErrVersionMismatch when the supplier is no longer at version, unless it is AnyVersion,
+	// and ErrNotFound for deleted suppliers.
+	// PatchSupplier applies the patch and returns the updated supplier
+	PatchSupplier(ctx context.Context, id int64, version int64, patch SupplierPatch) (Supplier, error)
+	UpdateSupplierEmail(ctx context.Context, id int64, version int64, email string) error
+	UpdateSupplierPhone(ctx context.Context, id int64, version int64, phone string) error
+	UpdateSupplierLeadTime(ctx context.Context, id int64, version int64, days int) error
+	// DeleteSupplier hides the supplier, ErrInUse while it still has products that are not deleted
+	// and deletion neither reassigns nor cascades, or when a cascade would delete a product that open orders wait for.
+	// ErrInvalidReference if ReassignTo is missing or deleted.
+	DeleteSupplier(ctx context.Context, id int64, version int64, deletion SupplierDeletion) error
+	// RestoreSupplier undoes DeleteSupplier including the products it cascaded to, ErrInvalidState if the supplier is not deleted
+	RestoreSupplier(ctx context.Context, id int64) (Supplier, error)
+	// PurgeSupplier removes a deleted supplier for good, ErrInvalidState if it is not deleted and ErrInUse while products or orders refer to it
+	PurgeSupplier(ctx context.Context, id int64, version int64) error
+	// ImportSuppliers creates or patches the supplier of every row, all or nothing like ImportProducts
+	ImportSuppliers(ctx context.Context, rows []SupplierImport, dryRun bool) (ImportResult, error)
+}
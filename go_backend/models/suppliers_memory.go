@@ -0,0 +1,257 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// caller holds the lock
+func (m *MemoryStore) checkSupplier(supplier Supplier) error {
+	for _, existing := range m.suppliers {
+		if existing.ID == supplier.ID {
+			continue
+		}
+		if existing.Name == supplier.Name {
+			return fmt.Errorf("%w: Key (name)=(%s) already exists.", ErrDuplicate, supplier.Name)
+		}
+		// empty emails are stored as NULL, which never collide
+		if supplier.ContactEmail != "" && existing.ContactEmail == supplier.ContactEmail {
+			return fmt.Errorf("%w: Key (contact_email)=(%s) already exists.", ErrDuplicate, supplier.ContactEmail)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) CreateSupplier(ctx context.Context, supplier *Supplier) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createSupplierLocked(supplier)
+}
+
+// caller holds the write lock
+func (m *MemoryStore) createSupplierLocked(supplier *Supplier) error {
+	if err := m.checkSupplier(*supplier); err != nil {
+		return err
+	}
+
+	m.lastSupplierID++
+	supplier.ID = m.lastSupplierID
+	supplier.CreatedAt = memoryNow()
+	supplier.UpdatedAt = supplier.CreatedAt
+	supplier.Version = 1
+	m.suppliers[supplier.ID] = *supplier
+	return nil
+}
+
+func (m *MemoryStore) GetSupplier(ctx context.Context, id int64) (Supplier, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	supplier, ok := m.suppliers[id]
+	if !ok {
+		return Supplier{}, ErrNotFound
+	}
+	return supplier, nil
+}
+
+func (m *MemoryStore) ListSuppliers(ctx context.Context, filter SupplierFilter) ([]Supplier, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	suppliers := []Supplier{}
+	for _, supplier := range sortedByID(m.suppliers) {
+		if supplier.DeletedAt != "" && !filter.IncludeDeleted {
+			continue
+		}
+		if filter.Name != "" && !strings.Contains(strings.ToLower(supplier.Name), strings.ToLower(filter.Name)) {
+			continue
+		}
+		suppliers = append(suppliers, supplier)
+	}
+
+	sortItems(suppliers, filter.Sort, func(a, b Supplier) int {
+		switch filter.Sort.Key {
+		case "name":
+			return cmp.Compare(a.Name, b.Name)
+		case "lead_time_days":
+			return cmp.Compare(a.LeadTimeDays, b.LeadTimeDays)
+		case "created_at":
+			return cmp.Compare(a.CreatedAt, b.CreatedAt)
+		}
+		return 0
+	})
+	return paginate(suppliers, filter.Page), len(suppliers), nil
+}
+
+// apply change to the supplier at version under the write lock, re-checking uniqueness
+func (m *MemoryStore) updateSupplier(id int64, version int64, change func(supplier *Supplier)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.updateSupplierLocked(id, version, change)
+}
+
+// caller holds the write lock
+func (m *MemoryStore) updateSupplierLocked(id int64, version int64, change func(supplier *Supplier)) error {
+	supplier, ok := m.suppliers[id]
+	if !ok || supplier.DeletedAt != "" {
+		return ErrNotFound
+	}
+	if err := checkVersion("supplier", id, supplier.Version, version); err != nil {
+		return err
+	}
+	change(&supplier)
+	if err := m.checkSupplier(supplier); err != nil {
+		return err
+	}
+	supplier.UpdatedAt = memoryNow()
+	supplier.Version++
+	m.suppliers[id] = supplier
+	return nil
+}
+
+func (m *MemoryStore) PatchSupplier(ctx context.Context, id int64, version int64, patch SupplierPatch) (Supplier, error) {
+	err := m.updateSupplier(id, version, patchSupplierChange(patch))
+	if err != nil {
+		return Supplier{}, err
+	}
+	return m.GetSupplier(ctx, id)
+}
+
+// the change of updateSupplier that applies patch
+func patchSupplierChange(patch SupplierPatch) func(supplier *Supplier) {
+	return func(supplier *Supplier) {
+		if patch.Name != nil {
+			supplier.Name = *patch.Name
+		}
+		if patch.ContactEmail != nil {
+			supplier.ContactEmail = *patch.ContactEmail
+		}
+		if patch.Phone != nil {
+			supplier.Phone = *patch.Phone
+		}
+		if patch.LeadTimeDays != nil {
+			supplier.LeadTimeDays = *patch.LeadTimeDays
+		}
+	}
+}
+
+func (m *MemoryStore) UpdateSupplierEmail(ctx context.Context, id int64, version int64, email string) error {
+	return m.updateSupplier(id, version, func(supplier *Supplier) { supplier.ContactEmail = email })
+}
+
+func (m *MemoryStore) UpdateSupplierPhone(ctx context.Context, id int64, version int64, phone string) error {
+	return m.updateSupplier(id, version, func(supplier *Supplier) { supplier.Phone = phone })
+}
+
+func (m *MemoryStore) UpdateSupplierLeadTime(ctx context.Context, id int64, version int64, days int) error {
+	return m.updateSupplier(id, version, func(supplier *Supplier) { supplier.LeadTimeDays = days })
+}
+
+func (m *MemoryStore) DeleteSupplier(ctx context.Context, id int64, version int64, deletion SupplierDeletion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	supplier, ok := m.suppliers[id]
+	if !ok || supplier.DeletedAt != "" {
+		return ErrNotFound
+	}
+	if err := checkVersion("supplier", id, supplier.Version, version); err != nil {
+		return err
+	}
+	if deletion.ReassignTo != 0 {
+		if target, ok := m.suppliers[deletion.ReassignTo]; !ok || target.DeletedAt != "" || target.ID == id {
+			return fmt.Errorf("%w: supplier %d does not exist or is deleted", ErrInvalidReference, deletion.ReassignTo)
+		}
+	}
+
+	// nothing is written until every product is checked
+	now := memoryNow()
+	changed := []Product{}
+	for _, product := range sortedByID(m.products) {
+		if product.SupplierID != id {
+			continue
+		}
+		switch {
+		case deletion.ReassignTo != 0:
+			product.SupplierID = deletion.ReassignTo
+		case product.DeletedAt != "":
+			continue
+		case deletion.Cascade && m.onOpenOrdersLocked(product.ID):
+			return fmt.Errorf("%w: product %d has stock reserved or on open purchase orders", ErrInUse, product.ID)
+		case deletion.Cascade:
+			product.DeletedAt = now
+		default:
+			return fmt.Errorf("%w: supplier %d still has products", ErrInUse, id)
+		}
+		product.UpdatedAt = now
+		product.Version++
+		changed = append(changed, product)
+	}
+	for _, product := range changed {
+		m.products[product.ID] = product
+	}
+
+	supplier.DeletedAt = now
+	supplier.UpdatedAt = now
+	supplier.Version++
+	m.suppliers[id] = supplier
+	return nil
+}
+
+func (m *MemoryStore) RestoreSupplier(ctx context.Context, id int64) (Supplier, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	supplier, ok := m.suppliers[id]
+	if !ok {
+		return Supplier{}, ErrNotFound
+	}
+	if err := checkDeleted("supplier", id, supplier.DeletedAt, supplier.Version, AnyVersion); err != nil {
+		return Supplier{}, err
+	}
+	// products deleted along with the supplier come back with it
+	now := memoryNow()
+	for _, product := range m.products {
+		if product.SupplierID == id && product.DeletedAt == supplier.DeletedAt {
+			product.DeletedAt = ""
+			product.UpdatedAt = now
+			product.Version++
+			m.products[product.ID] = product
+		}
+	}
+	supplier.DeletedAt = ""
+	supplier.UpdatedAt = now
+	supplier.Version++
+	m.suppliers[id] = supplier
+	return supplier, nil
+}
+
+func (m *MemoryStore) PurgeSupplier(ctx context.Context, id int64, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	supplier, ok := m.suppliers[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := checkDeleted("supplier", id, supplier.DeletedAt, supplier.Version, version); err != nil {
+		return err
+	}
+	// fk_supplier on products, deleted ones too
+	for _, product := range m.products {
+		if product.SupplierID == id {
+			return fmt.Errorf("%w: Key (id)=(%d) is still referenced from table \"products\".", ErrInUse, id)
+		}
+	}
+	for _, order := range m.purchaseOrders {
+		if order.SupplierID == id {
+			return fmt.Errorf("%w: Key (id)=(%d) is still referenced from table \"purchase_orders\".", ErrInUse, id)
+		}
+	}
+	delete(m.suppliers, id)
+	return nil
+}
@@ -0,0 +1,252 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PostgresSupplierStore implements SupplierStore on the supplier table
+type PostgresSupplierStore struct {
+	DB *sql.DB
+}
+
+const supplierColumns = "id, name, COALESCE(contact_email, ''), COALESCE(phone, ''), lead_time_days, created_at, updated_at, version, deleted_at"
+
+func scanSupplier(row scanner) (Supplier, error) {
+	var (
+		supplier  Supplier
+		deletedAt sql.NullString
+	)
+	err := row.Scan(&supplier.ID, &supplier.Name, &supplier.ContactEmail, &supplier.Phone, &supplier.LeadTimeDays, &supplier.CreatedAt, &supplier.UpdatedAt, &supplier.Version, &deletedAt)
+	supplier.DeletedAt = deletedAt.String
+	return supplier, err
+}
+
+func (s *PostgresSupplierStore) CreateSupplier(ctx context.Context, supplier *Supplier) error {
+	return createSupplier(ctx, s.DB, supplier)
+}
+
+func createSupplier(ctx context.Context, q querier, supplier *Supplier) error {
+	// empty email/phone stored as NULL so the unique contact_email only applies to real addresses
+	query := "INSERT INTO supplier (name, contact_email, phone, lead_time_days) VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4) RETURNING id"
+	err := q.QueryRowContext(ctx, query, supplier.Name, supplier.ContactEmail, supplier.Phone, supplier.LeadTimeDays).Scan(&supplier.ID)
+	return mapError(err)
+}
+
+func (s *PostgresSupplierStore) GetSupplier(ctx context.Context, id int64) (Supplier, error) {
+	query := "SELECT " + supplierColumns + " FROM supplier WHERE id = $1"
+	supplier, err := scanSupplier(s.DB.QueryRowContext(ctx, query, id))
+	return supplier, mapError(err)
+}
+
+func (s *PostgresSupplierStore) ListSuppliers(ctx context.Context, filter SupplierFilter) ([]Supplier, int, error) {
+	conditions := []string{"deleted_at IS NULL"}
+	if filter.IncludeDeleted {
+		conditions = []string{"TRUE"}
+	}
+	args := []any{}
+	if filter.Name != "" {
+		args = append(args, "%"+likeEscaper.Replace(filter.Name)+"%")
+		conditions = append(conditions, "name ILIKE $1")
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	if err := s.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM supplier"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	page, args := filter.Page.limitOffset(args)
+	query := "SELECT " + supplierColumns + " FROM supplier" + where + " ORDER BY " + filter.Sort.orderBy(SupplierSortKeys) + page
+	rows, err := s.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	suppliers := []Supplier{}
+	for rows.Next() {
+		supplier, err := scanSupplier(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		suppliers = append(suppliers, supplier)
+	}
+	return suppliers, total, rows.Err()
+}
+
+func (s *PostgresSupplierStore) PatchSupplier(ctx context.Context, id int64, version int64, patch SupplierPatch) (Supplier, error) {
+	return patchSupplier(ctx, s.DB, id, version, patch)
+}
+
+func patchSupplier(ctx context.Context, q querier, id int64, version int64, patch SupplierPatch) (Supplier, error) {
+	var set setClause
+	if patch.Name != nil {
+		set.set("name", *patch.Name)
+	}
+	if patch.ContactEmail != nil {
+		set.setOptional("contact_email", *patch.ContactEmail)
+	}
+	if patch.Phone != nil {
+		set.setOptional("phone", *patch.Phone)
+	}
+	if patch.LeadTimeDays != nil {
+		set.set("lead_time_days", *patch.LeadTimeDays)
+	}
+
+	query, args := set.update("supplier", id, version, supplierColumns)
+	supplier, err := scanSupplier(q.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Supplier{}, versionError(ctx, q, "supplier", id, version)
+	}
+	return supplier, mapError(err)
+}
+
+// an empty email or phone is stored as NULL like in createSupplier, so cleared emails never collide
+func (s *PostgresSupplierStore) UpdateSupplierEmail(ctx context.Context, id int64, version int64, email string) error {
+	return execVersioned(ctx, s.DB, "supplier", id, version, "UPDATE supplier SET contact_email = NULLIF($1, ''), updated_at = NOW() WHERE id = $2", email)
+}
+
+func (s *PostgresSupplierStore) UpdateSupplierPhone(ctx context.Context, id int64, version int64, phone string) error {
+	return execVersioned(ctx, s.DB, "supplier", id, version, "UPDATE supplier SET phone = NULLIF($1, ''), updated_at = NOW() WHERE id = $2", phone)
+}
+
+func (s *PostgresSupplierStore) UpdateSupplierLeadTime(ctx context.Context, id int64, version int64, days int) error {
+	return execVersioned(ctx, s.DB, "supplier", id, version, "UPDATE supplier SET lead_time_days = $1, updated_at = NOW() WHERE id = $2", days)
+}
+
+func (s *PostgresSupplierStore) DeleteSupplier(ctx context.Context, id int64, version int64, deletion SupplierDeletion) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// the row lock taken by the update keeps new products away until commit
+	if err := execVersioned(ctx, tx, "supplier", id, version, "UPDATE supplier SET deleted_at = NOW(), updated_at = NOW() WHERE id = $1"); err != nil {
+		return err
+	}
+
+	switch {
+	case deletion.ReassignTo != 0:
+		// the supplier itself is deleted by now, so it cannot take its own products
+		if err := lockActiveSupplier(ctx, tx, deletion.ReassignTo); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE products SET supplier_id = $1, updated_at = NOW() WHERE supplier_id = $2", deletion.ReassignTo, id); err != nil {
+			return mapError(err)
+		}
+	case deletion.Cascade:
+		if err := checkNoOpenOrders(ctx, tx, "p.supplier_id = $1", id); err != nil {
+			return err
+		}
+		// the products share the supplier's deleted_at, RestoreSupplier brings back the ones with it
+		if _, err := tx.ExecContext(ctx, "UPDATE products SET deleted_at = NOW(), updated_at = NOW() WHERE supplier_id = $1 AND deleted_at IS NULL", id); err != nil {
+			return err
+		}
+	default:
+		var inUse bool
+		if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM products WHERE supplier_id = $1 AND deleted_at IS NULL)", id).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return fmt.Errorf("%w: supplier %d still has products", ErrInUse, id)
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *PostgresSupplierStore) RestoreSupplier(ctx context.Context, id int64) (Supplier, error) {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return Supplier{}, err
+	}
+	defer tx.Rollback()
+
+	if err := lockDeleted(ctx, tx, "supplier", id, AnyVersion); err != nil {
+		return Supplier{}, err
+	}
+	// products deleted along with the supplier come back with it, ones deleted on their own stay deleted
+	query := "UPDATE products SET deleted_at = NULL, updated_at = NOW() WHERE supplier_id = $1 AND deleted_at = (SELECT deleted_at FROM supplier WHERE id = $1)"
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return Supplier{}, mapError(err)
+	}
+	query = "UPDATE supplier SET deleted_at = NULL, updated_at = NOW() WHERE id = $1 RETURNING " + supplierColumns
+	supplier, err := scanSupplier(tx.QueryRowContext(ctx, query, id))
+	if err != nil {
+		return Supplier{}, mapError(err)
+	}
+	return supplier, tx.Commit()
+}
+
+func (s *PostgresSupplierStore) PurgeSupplier(ctx context.Context, id int64, version int64) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockDeleted(ctx, tx, "supplier", id, version); err != nil {
+		return err
+	}
+	// still fails while products, deleted or not, or purchase orders refer to the supplier
+	if _, err := tx.ExecContext(ctx, "DELETE FROM supplier WHERE id = $1", id); err != nil {
+		return mapDeleteError(err)
+	}
+	return tx.Commit()
+}
+
+// savepoints per row like ImportProducts
+func (s *PostgresSupplierStore) ImportSuppliers(ctx context.Context, rows []SupplierImport, dryRun bool) (ImportResult, error) {
+	result := newImportResult(dryRun)
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	for _, row := range rows {
+		if _, err := tx.ExecContext(ctx, "SAVEPOINT import_row"); err != nil {
+			return result, err
+		}
+		created, err := importSupplierTx(ctx, tx, row)
+		if err := result.add(row.Row, created, err); err != nil {
+			return result, err
+		}
+		statement := "RELEASE SAVEPOINT import_row"
+		if err != nil {
+			statement = "ROLLBACK TO SAVEPOINT import_row"
+		}
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return result, err
+		}
+	}
+
+	if !result.commit() {
+		return result, nil
+	}
+	return result, tx.Commit()
+}
+
+// create or patch the supplier of one row, reports whether it was created
+func importSupplierTx(ctx context.Context, tx *sql.Tx, row SupplierImport) (bool, error) {
+	var id int64
+	var deleted bool
+	err := tx.QueryRowContext(ctx, "SELECT id, deleted_at IS NOT NULL FROM supplier WHERE name = $1 FOR UPDATE", *row.Patch.Name).Scan(&id, &deleted)
+	if errors.Is(err, sql.ErrNoRows) {
+		supplier := row.supplier()
+		return true, createSupplier(ctx, tx, &supplier)
+	}
+	if err != nil {
+		return false, err
+	}
+	// deleted suppliers keep their name, the row cannot create another
+	if deleted {
+		return false, fmt.Errorf("%w: supplier %d is deleted, restore it first", ErrInvalidState, id)
+	}
+	_, err = patchSupplier(ctx, tx, id, AnyVersion, row.Patch)
+	return false, err
+}
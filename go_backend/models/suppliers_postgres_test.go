@@ -0,0 +1,364 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresCreateSupplier(t *testing.T) {
+	t.Parallel()
+
+	// Create a new mock database connection and sqlmock instance
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// Define the query we expect to be executed
+	query := "INSERT INTO supplier \\(name, contact_email, phone, lead_time_days\\) VALUES \\(\\$1, NULLIF\\(\\$2, ''\\), NULLIF\\(\\$3, ''\\), \\$4\\) RETURNING id"
+	mock.ExpectQuery(query).WithArgs("testsupplier", "testsupplier@gmail.com", "0123456789", 5).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1)) //new row inserted with id 1
+
+	store := &PostgresSupplierStore{DB: db}
+	supplier := Supplier{Name: "testsupplier", ContactEmail: "testsupplier@gmail.com", Phone: "0123456789", LeadTimeDays: 5}
+	err = store.CreateSupplier(context.Background(), &supplier)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, supplier.ID)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresGetSupplier(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock query
+	query := "SELECT id, name, COALESCE\\(contact_email, ''\\), COALESCE\\(phone, ''\\), lead_time_days, created_at, updated_at, version, deleted_at FROM supplier WHERE id = \\$1"
+	rows := sqlmock.NewRows([]string{"id", "name", "contact_email", "phone", "lead_time_days", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "testsupplier", "supplier@gmail.com", "012345678", 7, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery(query).WithArgs(1).WillReturnRows(rows)
+
+	store := &PostgresSupplierStore{DB: db}
+	supplier, err := store.GetSupplier(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, supplier.ID)
+	assert.Equal(t, "supplier@gmail.com", supplier.ContactEmail)
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresListSuppliers(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, % and _ in the name are matched literally
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM supplier WHERE deleted_at IS NULL AND name ILIKE \\$1").WithArgs("%50\\%%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	rows := sqlmock.NewRows([]string{"id", "name", "contact_email", "phone", "lead_time_days", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "50% off", "", "", 7, "2024-08-01", "2024-08-01", 1, nil)
+	mock.ExpectQuery("SELECT (.+) FROM supplier WHERE deleted_at IS NULL AND name ILIKE \\$1 ORDER BY lead_time_days, id LIMIT \\$2").WithArgs("%50\\%%", 10).WillReturnRows(rows)
+
+	store := &PostgresSupplierStore{DB: db}
+	suppliers, total, err := store.ListSuppliers(context.Background(), SupplierFilter{Name: "50%", Sort: Sort{Key: "lead_time_days"}, Page: Page{Limit: 10}})
+	assert.NoError(t, err)
+	assert.Len(t, suppliers, 1)
+	assert.Equal(t, 1, total)
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresDeleteSupplier(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// mock queries, the row stays behind
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE supplier SET deleted_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE id = \\$1 AND deleted_at IS NULL").WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM products WHERE supplier_id = \\$1 AND deleted_at IS NULL\\)").WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectCommit()
+
+	store := &PostgresSupplierStore{DB: db}
+	err = store.DeleteSupplier(context.Background(), 1, AnyVersion, SupplierDeletion{})
+	assert.NoError(t, err)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresDeleteSupplierReferenced(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, a product that is not deleted keeps the supplier
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE supplier SET deleted_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT EXISTS").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	store := &PostgresSupplierStore{DB: db}
+	err = store.DeleteSupplier(context.Background(), 1, AnyVersion, SupplierDeletion{})
+	assert.ErrorIs(t, err, ErrInUse)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresDeleteSupplierWithProducts(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, the products move to supplier 2 in the same transaction
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE supplier SET deleted_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier WHERE id = \\$1 FOR SHARE").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(false))
+	mock.ExpectExec("UPDATE products SET supplier_id = \\$1, updated_at = NOW\\(\\) WHERE supplier_id = \\$2").WithArgs(2, 1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	// a deleted supplier cannot take them
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE supplier SET deleted_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT deleted_at IS NOT NULL FROM supplier").WithArgs(3).
+		WillReturnRows(sqlmock.NewRows([]string{"deleted"}).AddRow(true))
+	mock.ExpectRollback()
+
+	// or they are deleted along with the supplier, unless open orders still wait for one of them
+	openOrders := "SELECT p.id FROM products p\\s+WHERE p.supplier_id = \\$1 AND p.deleted_at IS NULL AND \\(p.reserved > 0 OR EXISTS"
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE supplier SET deleted_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(openOrders).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE supplier SET deleted_at").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(openOrders).WithArgs(1).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("UPDATE products SET deleted_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE supplier_id = \\$1 AND deleted_at IS NULL").WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	store := &PostgresSupplierStore{DB: db}
+	assert.NoError(t, store.DeleteSupplier(context.Background(), 1, AnyVersion, SupplierDeletion{ReassignTo: 2}))
+	assert.ErrorIs(t, store.DeleteSupplier(context.Background(), 1, AnyVersion, SupplierDeletion{ReassignTo: 3}), ErrInvalidReference)
+	err = store.DeleteSupplier(context.Background(), 1, AnyVersion, SupplierDeletion{Cascade: true})
+	assert.ErrorIs(t, err, ErrInUse)
+	assert.ErrorContains(t, err, "product 4")
+	assert.NoError(t, store.DeleteSupplier(context.Background(), 1, AnyVersion, SupplierDeletion{Cascade: true}))
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRestoreAndPurgeSupplier(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, only a deleted supplier comes back or goes for good, with the products deleted along with it
+	lock := "SELECT deleted_at IS NOT NULL, version FROM supplier WHERE id = \\$1 FOR UPDATE"
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 2))
+	mock.ExpectExec("UPDATE products SET deleted_at = NULL, updated_at = NOW\\(\\) WHERE supplier_id = \\$1 AND deleted_at = \\(SELECT deleted_at FROM supplier WHERE id = \\$1\\)").WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	rows := sqlmock.NewRows([]string{"id", "name", "contact_email", "phone", "lead_time_days", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "testsupplier", "", "", 7, "2024-08-01", "2024-09-01", 3, nil)
+	mock.ExpectQuery("UPDATE supplier SET deleted_at = NULL, updated_at = NOW\\(\\) WHERE id = \\$1 RETURNING").WithArgs(1).WillReturnRows(rows)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(false, 1))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 4))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery(lock).WithArgs(3).WillReturnRows(sqlmock.NewRows([]string{"deleted", "version"}).AddRow(true, 4))
+	mock.ExpectExec("DELETE FROM supplier WHERE id = \\$1").WithArgs(3).
+		WillReturnError(&pq.Error{Code: "23503", Detail: "Key (id)=(3) is still referenced from table \"purchase_orders\"."})
+	mock.ExpectRollback()
+
+	store := &PostgresSupplierStore{DB: db}
+	supplier, err := store.RestoreSupplier(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Empty(t, supplier.DeletedAt)
+	assert.EqualValues(t, 3, supplier.Version)
+	_, err = store.RestoreSupplier(context.Background(), 2)
+	assert.ErrorIs(t, err, ErrInvalidState)
+	assert.ErrorIs(t, store.PurgeSupplier(context.Background(), 3, 3), ErrVersionMismatch)
+	assert.ErrorIs(t, store.PurgeSupplier(context.Background(), 3, 4), ErrInUse)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresUpdateSupplierEmail(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock queries, a cleared email is stored as NULL
+	query := "UPDATE supplier SET contact_email = NULLIF\\(\\$1, ''\\), updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs("supplier2@gmail.com", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs("", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := &PostgresSupplierStore{DB: db}
+	err = store.UpdateSupplierEmail(context.Background(), 1, AnyVersion, "supplier2@gmail.com")
+	assert.NoError(t, err)
+	err = store.UpdateSupplierEmail(context.Background(), 2, AnyVersion, "")
+	assert.NoError(t, err)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresUpdateSupplierPhone(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock query
+	query := "UPDATE supplier SET phone = NULLIF\\(\\$1, ''\\), updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs("0123456789", 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := &PostgresSupplierStore{DB: db}
+	err = store.UpdateSupplierPhone(context.Background(), 1, AnyVersion, "0123456789")
+	assert.NoError(t, err)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresImportSuppliersDryRun(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// a dry run writes every row and then rolls it all back
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM supplier WHERE name = \\$1 FOR UPDATE").WithArgs("newsupplier").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("INSERT INTO supplier").WithArgs("newsupplier", "", "", DefaultLeadTimeDays).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM supplier WHERE name = \\$1").WithArgs("testsupplier").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}).AddRow(1, false))
+	mock.ExpectQuery("UPDATE supplier SET name = \\$1, lead_time_days = \\$2, updated_at = NOW\\(\\) WHERE id = \\$3 AND deleted_at IS NULL").WithArgs("testsupplier", 3, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "contact_email", "phone", "lead_time_days", "created_at", "updated_at", "version", "deleted_at"}).
+			AddRow(1, "testsupplier", "", "", 3, "2024-08-01", "2024-09-01", 2, nil))
+	mock.ExpectExec("RELEASE SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	store := &PostgresSupplierStore{DB: db}
+	name, existing, days := "newsupplier", "testsupplier", 3
+	rows := []SupplierImport{
+		{Row: 2, Patch: SupplierPatch{Name: &name}},
+		{Row: 3, Patch: SupplierPatch{Name: &existing, LeadTimeDays: &days}},
+	}
+	result, err := store.ImportSuppliers(context.Background(), rows, true)
+	assert.NoError(t, err)
+	assert.Equal(t, ImportResult{Created: 1, Updated: 1, Errors: []ImportError{}, DryRun: true}, result)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresImportSuppliersValueTooLong(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// a value the column cannot hold fails its row, not the whole import
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT id, deleted_at IS NOT NULL FROM supplier WHERE name = \\$1").WithArgs("newsupplier").WillReturnRows(sqlmock.NewRows([]string{"id", "deleted"}))
+	mock.ExpectQuery("INSERT INTO supplier").
+		WillReturnError(&pq.Error{Code: "22001", Message: "value too long for type character varying(20)"})
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT import_row").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	store := &PostgresSupplierStore{DB: db}
+	name, phone := "newsupplier", "+44 (0) 20 7946 0000 ext. 12"
+	rows := []SupplierImport{{Row: 2, Patch: SupplierPatch{Name: &name, Phone: &phone}}}
+	result, err := store.ImportSuppliers(context.Background(), rows, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []ImportError{{Row: 2, Message: "value does not fit the column: value too long for type character varying(20)"}}, result.Errors)
+	assert.False(t, result.Committed)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"context"
+	"time"
+)
+
+type User struct {
+	ID        int64  `json:"id"`
+	Email     string `json:"email"`
+	Password  string `json:"-"` // never send the hash back to clients
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+// user roles, see migrations/20240805120000_add_role_to_users.sql
+const (
+	RoleAdmin    = "admin"
+	RoleManager  = "manager"
+	RoleClerk    = "clerk"
+	RoleReadOnly = "read_only"
+)
+
+func ValidRole(role string) bool {
+	switch role {
+	case RoleAdmin, RoleManager, RoleClerk, RoleReadOnly:
+		return true
+	}
+	return false
+}
+
+// UserStore persists users and their login sessions.
+// A session is a family of refresh tokens, only hashes are stored.
+type UserStore interface {
+	// CreateUser inserts the user and sets its ID
+	CreateUser(ctx context.Context, user *User) error
+	// GetUserByEmail includes the password hash
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	// GetSessionUser returns the user only while the session is live
+	GetSessionUser(ctx context.Context, userID int64, sessionID string) (User, error)
+	UpdateUserRole(ctx context.Context, id int64, role string) error
+
+	CreateSession(ctx context.Context, userID int64, sessionID string, tokenHash string, expiresAt time.Time) error
+	// RotateRefreshToken swaps oldHash for newHash in the same session.
+	// Returns ErrRefreshTokenReused (and revokes the session) when oldHash was already rotated.
+	RotateRefreshToken(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (User, string, error)
+	// RevokeSession revokes every token in the session tokenHash belongs to
+	RevokeSession(ctx context.Context, tokenHash string) error
+}
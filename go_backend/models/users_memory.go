@@ -0,0 +1,129 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type memoryRefreshToken struct {
+	userID    int64
+	sessionID string
+	expiresAt time.Time
+	revoked   bool
+}
+
+func (m *MemoryStore) CreateUser(ctx context.Context, user *User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.users {
+		if existing.Email == user.Email {
+			return fmt.Errorf("%w: Key (email)=(%s) already exists.", ErrDuplicate, user.Email)
+		}
+	}
+
+	m.lastUserID++
+	user.ID = m.lastUserID
+	if user.Role == "" {
+		user.Role = RoleReadOnly
+	}
+	user.CreatedAt = memoryNow()
+	m.users[user.ID] = *user
+	return nil
+}
+
+func (m *MemoryStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (m *MemoryStore) GetSessionUser(ctx context.Context, userID int64, sessionID string) (User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	for _, token := range m.refreshTokens {
+		if token.userID == userID && token.sessionID == sessionID && !token.revoked && time.Now().Before(token.expiresAt) {
+			user.Password = ""
+			return user, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (m *MemoryStore) UpdateUserRole(ctx context.Context, id int64, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Role = role
+	m.users[id] = user
+	return nil
+}
+
+func (m *MemoryStore) CreateSession(ctx context.Context, userID int64, sessionID string, tokenHash string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return ErrInvalidReference
+	}
+	m.refreshTokens[tokenHash] = &memoryRefreshToken{userID: userID, sessionID: sessionID, expiresAt: expiresAt}
+	return nil
+}
+
+// caller holds the lock
+func (m *MemoryStore) revokeSessionLocked(sessionID string) {
+	for _, token := range m.refreshTokens {
+		if token.sessionID == sessionID {
+			token.revoked = true
+		}
+	}
+}
+
+func (m *MemoryStore) RotateRefreshToken(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (User, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.refreshTokens[oldHash]
+	if !ok || !time.Now().Before(token.expiresAt) {
+		return User{}, "", ErrRefreshTokenInvalid
+	}
+
+	user := m.users[token.userID]
+	user.Password = ""
+
+	if token.revoked {
+		// token reuse, someone else may hold this session
+		m.revokeSessionLocked(token.sessionID)
+		return user, "", ErrRefreshTokenReused
+	}
+
+	token.revoked = true
+	m.refreshTokens[newHash] = &memoryRefreshToken{userID: token.userID, sessionID: token.sessionID, expiresAt: expiresAt}
+	return user, token.sessionID, nil
+}
+
+func (m *MemoryStore) RevokeSession(ctx context.Context, tokenHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if token, ok := m.refreshTokens[tokenHash]; ok {
+		m.revokeSessionLocked(token.sessionID)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PostgresUserStore implements UserStore on the users and refresh_tokens tables
+type PostgresUserStore struct {
+	DB *sql.DB
+}
+
+func (s *PostgresUserStore) CreateUser(ctx context.Context, user *User) error {
+	err := s.DB.QueryRowContext(ctx, "INSERT INTO users (email, password) VALUES ($1, $2) RETURNING id, role", user.Email, user.Password).Scan(&user.ID, &user.Role)
+	return mapError(err)
+}
+
+func (s *PostgresUserStore) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	var user User
+	row := s.DB.QueryRowContext(ctx, "SELECT id, email, password, role, created_at FROM users WHERE email = $1", email)
+	err := row.Scan(&user.ID, &user.Email, &user.Password, &user.Role, &user.CreatedAt)
+	return user, mapError(err)
+}
+
+func (s *PostgresUserStore) GetSessionUser(ctx context.Context, userID int64, sessionID string) (User, error) {
+	var user User
+	// the session must still have a live refresh token, logout revokes it
+	query := `SELECT id, email, role, created_at FROM users WHERE id = $1 AND EXISTS (
+		SELECT 1 FROM refresh_tokens WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL AND expires_at > NOW())`
+	err := s.DB.QueryRowContext(ctx, query, userID, sessionID).Scan(&user.ID, &user.Email, &user.Role, &user.CreatedAt)
+	return user, mapError(err)
+}
+
+func (s *PostgresUserStore) UpdateUserRole(ctx context.Context, id int64, role string) error {
+	result, err := s.DB.ExecContext(ctx, "UPDATE users SET role = $1, updated_at = NOW() WHERE id = $2", role, id)
+	if err != nil {
+		return mapError(err)
+	}
+	return expectOneRow(result)
+}
+
+func (s *PostgresUserStore) CreateSession(ctx context.Context, userID int64, sessionID string, tokenHash string, expiresAt time.Time) error {
+	query := "INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at) VALUES ($1, $2, $3, $4)"
+	_, err := s.DB.ExecContext(ctx, query, userID, tokenHash, sessionID, expiresAt)
+	return mapError(err)
+}
+
+func (s *PostgresUserStore) RotateRefreshToken(ctx context.Context, oldHash string, newHash string, expiresAt time.Time) (User, string, error) {
+	var user User
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return user, "", err
+	}
+	defer tx.Rollback()
+
+	var (
+		tokenID   int64
+		sessionID string
+		revokedAt sql.NullTime
+	)
+
+	// expiry is checked against the database clock like GetSessionUser does, an expired token is simply unknown
+	query := `SELECT rt.id, rt.family_id, rt.revoked_at, u.id, u.email, u.role
+		FROM refresh_tokens rt JOIN users u ON u.id = rt.user_id
+		WHERE rt.token_hash = $1 AND rt.expires_at > NOW() FOR UPDATE OF rt`
+	err = tx.QueryRowContext(ctx, query, oldHash).Scan(&tokenID, &sessionID, &revokedAt, &user.ID, &user.Email, &user.Role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return user, "", ErrRefreshTokenInvalid
+		}
+		return user, "", err
+	}
+
+	if revokedAt.Valid {
+		// token reuse, someone else may hold this session
+		if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", sessionID); err != nil {
+			return user, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return user, "", err
+		}
+		return user, "", ErrRefreshTokenReused
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET revoked_at = NOW() WHERE id = $1", tokenID); err != nil {
+		return user, "", err
+	}
+
+	query = "INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at) VALUES ($1, $2, $3, $4)"
+	if _, err := tx.ExecContext(ctx, query, user.ID, newHash, sessionID, expiresAt); err != nil {
+		return user, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return user, "", err
+	}
+
+	return user, sessionID, nil
+}
+
+func (s *PostgresUserStore) RevokeSession(ctx context.Context, tokenHash string) error {
+	query := `UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE revoked_at IS NULL AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1)`
+	_, err := s.DB.ExecContext(ctx, query, tokenHash)
+	return err
+}
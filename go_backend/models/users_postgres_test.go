@@ -0,0 +1,181 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresCreateUser(t *testing.T) {
+	t.Parallel()
+
+	// Create a new mock database connection and sqlmock instance
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// Define the query we expect to be executed
+	query := "INSERT INTO users \\(email, password\\) VALUES \\(\\$1, \\$2\\) RETURNING id, role"
+	mock.ExpectQuery(query).WithArgs("test@example.com", "hashedpassword").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role"}).AddRow(1, RoleReadOnly)) //new row inserted with id 1
+
+	store := &PostgresUserStore{DB: db}
+	user := User{Email: "test@example.com", Password: "hashedpassword"}
+	err = store.CreateUser(context.Background(), &user)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, user.ID)
+	assert.Equal(t, RoleReadOnly, user.Role)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresGetUserByEmail(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock query
+	query := "SELECT id, email, password, role, created_at FROM users WHERE email = \\$1"
+	rows := sqlmock.NewRows([]string{"id", "email", "password", "role", "created_at"}).
+		AddRow(1, "test@example.com", "hashedpassword", RoleClerk, "2024-08-01")
+	mock.ExpectQuery(query).WithArgs("test@example.com").WillReturnRows(rows)
+
+	store := &PostgresUserStore{DB: db}
+	user, err := store.GetUserByEmail(context.Background(), "test@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "hashedpassword", user.Password)
+	assert.Equal(t, RoleClerk, user.Role)
+
+	// Ensure that all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresUpdateUserRole(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	//mock query
+	query := "UPDATE users SET role = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2"
+	mock.ExpectExec(query).WithArgs(RoleManager, 1).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	store := &PostgresUserStore{DB: db}
+	err = store.UpdateUserRole(context.Background(), 1, RoleManager)
+	assert.NoError(t, err)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRotateRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+
+	rows := sqlmock.NewRows([]string{"id", "family_id", "revoked_at", "id", "email", "role"}).
+		AddRow(1, "session1", nil, 7, "test@example.com", RoleClerk)
+	mock.ExpectQuery("SELECT rt.id, rt.family_id, rt.revoked_at, u.id, u.email, u.role\\s+FROM refresh_tokens (.+) WHERE rt.token_hash = \\$1 AND rt.expires_at > NOW\\(\\)").
+		WithArgs("oldhash").WillReturnRows(rows)
+
+	// old token revoked, new one issued in the same session
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE id = \\$1").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens \\(user_id, token_hash, family_id, expires_at\\)").
+		WithArgs(7, "newhash", "session1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+
+	mock.ExpectCommit()
+
+	store := &PostgresUserStore{DB: db}
+	user, sessionID, err := store.RotateRefreshToken(context.Background(), "oldhash", "newhash", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, user.ID)
+	assert.Equal(t, RoleClerk, user.Role)
+	assert.Equal(t, "session1", sessionID)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRotateRefreshTokenReuse(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	mock.ExpectBegin()
+
+	rows := sqlmock.NewRows([]string{"id", "family_id", "revoked_at", "id", "email", "role"}).
+		AddRow(1, "session1", time.Now().Add(-time.Minute), 7, "test@example.com", RoleClerk)
+	mock.ExpectQuery("SELECT rt.id, rt.family_id, rt.revoked_at, u.id, u.email, u.role\\s+FROM refresh_tokens (.+) WHERE rt.token_hash = \\$1 AND rt.expires_at > NOW\\(\\)").
+		WithArgs("rotatedhash").WillReturnRows(rows)
+
+	// a rotated token was presented again, whole session is revoked
+	mock.ExpectExec("UPDATE refresh_tokens SET revoked_at = NOW\\(\\) WHERE family_id = \\$1 AND revoked_at IS NULL").
+		WithArgs("session1").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectCommit()
+
+	store := &PostgresUserStore{DB: db}
+	_, _, err = store.RotateRefreshToken(context.Background(), "rotatedhash", "newhash", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresRotateRefreshTokenExpired(t *testing.T) {
+	t.Parallel()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// the database clock decides, an expired token finds no row
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens (.+) WHERE rt.token_hash = \\$1 AND rt.expires_at > NOW\\(\\)").
+		WithArgs("expiredhash").WillReturnRows(sqlmock.NewRows([]string{"id", "family_id", "revoked_at", "id", "email", "role"}))
+	mock.ExpectRollback()
+
+	store := &PostgresUserStore{DB: db}
+	_, _, err = store.RotateRefreshToken(context.Background(), "expiredhash", "newhash", time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, ErrRefreshTokenInvalid)
+
+	// Ensure all expectations were met
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// how the stock is valued at cost
+const (
+	CostingStandard = "standard" // products.cost at the valuation date
+	CostingFIFO     = "fifo"     // what the oldest units still in stock were bought for
+	CostingAverage  = "average"  // running weighted average of everything bought
+)
+
+func ValidCostingMethod(method string) bool {
+	switch method {
+	case CostingStandard, CostingFIFO, CostingAverage:
+		return true
+	}
+	return false
+}
+
+// one stock movement with the cost of what came in
+type CostedMovement struct {
+	Delta    int
+	UnitCost *decimal.Decimal // the receipt cost for purchase order receipts, nil otherwise
+}
+
+// a product with its ledger up to the valuation date
+type ValuationInput struct {
+	ProductID    int64
+	Name         string
+	SupplierID   int64
+	SupplierName string
+	Cost         decimal.Decimal  // products.cost at the valuation date
+	Price        decimal.Decimal  // products.price at the valuation date
+	Movements    []CostedMovement // oldest first
+}
+
+type ProductValuation struct {
+	ProductID   int64           `json:"product_id"`
+	Name        string          `json:"name"`
+	Stock       int             `json:"stock"`
+	UnitCost    decimal.Decimal `json:"unit_cost"`
+	CostValue   decimal.Decimal `json:"cost_value"`
+	RetailValue decimal.Decimal `json:"retail_value"`
+}
+
+type SupplierValuation struct {
+	SupplierID   int64              `json:"supplier_id"`
+	SupplierName string             `json:"supplier_name"`
+	Stock        int                `json:"stock"`
+	CostValue    decimal.Decimal    `json:"cost_value"`
+	RetailValue  decimal.Decimal    `json:"retail_value"`
+	Products     []ProductValuation `json:"products"`
+}
+
+type Valuation struct {
+	Method      string              `json:"method"`
+	CostValue   decimal.Decimal     `json:"cost_value"`
+	RetailValue decimal.Decimal     `json:"retail_value"`
+	Suppliers   []SupplierValuation `json:"suppliers"`
+}
+
+// replay the ledger and return the stock and what it cost. Incoming stock
+// without a receipt cost (returns, adjustments) comes in at the product cost.
+func costStock(input ValuationInput, method string) (int, decimal.Decimal) {
+	type layer struct {
+		quantity int
+		cost     decimal.Decimal
+	}
+	var (
+		stock   int
+		layers  []layer
+		average = input.Cost
+	)
+
+	for _, movement := range input.Movements {
+		cost := input.Cost
+		if movement.UnitCost != nil {
+			cost = *movement.UnitCost
+		}
+
+		if movement.Delta > 0 {
+			layers = append(layers, layer{movement.Delta, cost})
+			if stock > 0 {
+				held := average.Mul(decimal.NewFromInt(int64(stock)))
+				added := cost.Mul(decimal.NewFromInt(int64(movement.Delta)))
+				average = held.Add(added).Div(decimal.NewFromInt(int64(stock + movement.Delta)))
+			} else {
+				average = cost
+			}
+		} else {
+			// the oldest units go first
+			out := -movement.Delta
+			for out > 0 && len(layers) > 0 {
+				taken := min(out, layers[0].quantity)
+				layers[0].quantity -= taken
+				out -= taken
+				if layers[0].quantity == 0 {
+					layers = layers[1:]
+				}
+			}
+		}
+		stock += movement.Delta
+	}
+
+	switch method {
+	case CostingFIFO:
+		value := decimal.Zero
+		for _, l := range layers {
+			value = value.Add(l.cost.Mul(decimal.NewFromInt(int64(l.quantity))))
+		}
+		return stock, value
+	case CostingAverage:
+		return stock, average.Mul(decimal.NewFromInt(int64(stock)))
+	default:
+		return stock, input.Cost.Mul(decimal.NewFromInt(int64(stock)))
+	}
+}
+
+// ValueStock values every product at cost with method and at retail with
+// the price at the valuation date, grouped per supplier. inputs must be sorted by supplier.
+func ValueStock(inputs []ValuationInput, method string) Valuation {
+	valuation := Valuation{Method: method, CostValue: decimal.Zero, RetailValue: decimal.Zero, Suppliers: []SupplierValuation{}}
+	for _, input := range inputs {
+		stock, costValue := costStock(input, method)
+		product := ProductValuation{
+			ProductID:   input.ProductID,
+			Name:        input.Name,
+			Stock:       stock,
+			UnitCost:    input.Cost,
+			CostValue:   costValue.Round(2),
+			RetailValue: input.Price.Mul(decimal.NewFromInt(int64(stock))).Round(2),
+		}
+		if stock > 0 {
+			product.UnitCost = costValue.Div(decimal.NewFromInt(int64(stock))).Round(2)
+		}
+
+		if n := len(valuation.Suppliers); n == 0 || valuation.Suppliers[n-1].SupplierID != input.SupplierID {
+			valuation.Suppliers = append(valuation.Suppliers, SupplierValuation{
+				SupplierID:   input.SupplierID,
+				SupplierName: input.SupplierName,
+				CostValue:    decimal.Zero,
+				RetailValue:  decimal.Zero,
+				Products:     []ProductValuation{},
+			})
+		}
+		supplier := &valuation.Suppliers[len(valuation.Suppliers)-1]
+		supplier.Stock += stock
+		supplier.CostValue = supplier.CostValue.Add(product.CostValue)
+		supplier.RetailValue = supplier.RetailValue.Add(product.RetailValue)
+		supplier.Products = append(supplier.Products, product)
+
+		valuation.CostValue = valuation.CostValue.Add(product.CostValue)
+		valuation.RetailValue = valuation.RetailValue.Add(product.RetailValue)
+	}
+	return valuation
+}
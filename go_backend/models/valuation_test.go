@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueStock(t *testing.T) {
+	t.Parallel()
+
+	cost := func(value string) *decimal.Decimal {
+		d := decimal.RequireFromString(value)
+		return &d
+	}
+	inputs := []ValuationInput{
+		// 10 in at 2.00, 10 in at 4.00, 15 out: 5 left
+		{ProductID: 1, Name: "layered", SupplierID: 1, SupplierName: "first", Cost: decimal.NewFromInt(5), Price: decimal.NewFromInt(10), Movements: []CostedMovement{
+			{Delta: 10, UnitCost: cost("2.00")},
+			{Delta: 10, UnitCost: cost("4.00")},
+			{Delta: -15},
+		}},
+		// opening balance without a receipt comes in at the product cost
+		{ProductID: 2, Name: "opening", SupplierID: 2, SupplierName: "second", Cost: decimal.NewFromInt(1), Price: decimal.NewFromInt(3), Movements: []CostedMovement{
+			{Delta: 4},
+		}},
+	}
+
+	// fifo keeps the newest layer: 5 * 4.00
+	fifo := ValueStock(inputs, CostingFIFO)
+	assert.Len(t, fifo.Suppliers, 2)
+	assert.Equal(t, 5, fifo.Suppliers[0].Stock)
+	assert.True(t, decimal.NewFromInt(20).Equal(fifo.Suppliers[0].CostValue))
+	assert.True(t, decimal.NewFromInt(50).Equal(fifo.Suppliers[0].RetailValue))
+	assert.True(t, decimal.NewFromInt(4).Equal(fifo.Suppliers[0].Products[0].UnitCost))
+	assert.True(t, decimal.NewFromInt(24).Equal(fifo.CostValue))
+	assert.True(t, decimal.NewFromInt(62).Equal(fifo.RetailValue))
+
+	// average of everything bought is 3.00
+	average := ValueStock(inputs, CostingAverage)
+	assert.True(t, decimal.NewFromInt(15).Equal(average.Suppliers[0].CostValue))
+
+	// standard uses today's cost
+	standard := ValueStock(inputs, CostingStandard)
+	assert.True(t, decimal.NewFromInt(25).Equal(standard.Suppliers[0].CostValue))
+	assert.True(t, decimal.NewFromInt(4).Equal(standard.Suppliers[1].CostValue))
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// AnyVersion skips the version check of an update or delete
+const AnyVersion int64 = 0
+
+// condition narrowing a statement on one row to the version it is expected at, deleted rows are never changed
+func versionCondition(version int64, args []any) (string, []any) {
+	if version == AnyVersion {
+		return " AND deleted_at IS NULL", args
+	}
+	args = append(args, version)
+	return fmt.Sprintf(" AND version = $%d AND deleted_at IS NULL", len(args)), args
+}
+
+// a versioned statement found no row, tell a missing or deleted row from one that changed
+func versionError(ctx context.Context, q querier, table string, id int64, version int64) error {
+	if version == AnyVersion {
+		return ErrNotFound
+	}
+	var exists bool
+	if err := q.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1 AND deleted_at IS NULL)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return fmt.Errorf("%w: %s %d is no longer at version %d", ErrVersionMismatch, table, id, version)
+}
+
+// run statement, ending in "WHERE id = $n" with id as its last parameter, on the row while it is at version
+func execVersioned(ctx context.Context, q querier, table string, id int64, version int64, statement string, args ...any) error {
+	condition, args := versionCondition(version, append(args, id))
+	result, err := q.ExecContext(ctx, statement+condition, args...)
+	if err != nil {
+		return mapError(err)
+	}
+	if err := expectOneRow(result); !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return versionError(ctx, q, table, id, version)
+}
+
+// lock the row for the rest of tx, failing when it is no longer at version
+func lockVersion(ctx context.Context, tx *sql.Tx, table string, id int64, version int64) error {
+	var current int64
+	if err := tx.QueryRowContext(ctx, "SELECT version FROM "+table+" WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&current); err != nil {
+		return mapError(err)
+	}
+	return checkVersion(table, id, current, version)
+}
+
+// lock a deleted row to restore or purge it, ErrInvalidState while it is not deleted
+func lockDeleted(ctx context.Context, tx *sql.Tx, table string, id int64, version int64) error {
+	var (
+		deleted bool
+		current int64
+	)
+	if err := tx.QueryRowContext(ctx, "SELECT deleted_at IS NOT NULL, version FROM "+table+" WHERE id = $1 FOR UPDATE", id).Scan(&deleted, &current); err != nil {
+		return mapError(err)
+	}
+	if !deleted {
+		return fmt.Errorf("%w: %s %d is not deleted", ErrInvalidState, table, id)
+	}
+	return checkVersion(table, id, current, version)
+}
+
+// the memory version of lockVersion, current is the version the row is at
+func checkVersion(table string, id int64, current int64, version int64) error {
+	if version != AnyVersion && current != version {
+		return fmt.Errorf("%w: %s %d is at version %d, not %d", ErrVersionMismatch, table, id, current, version)
+	}
+	return nil
+}
+
+// the memory version of lockDeleted
+func checkDeleted(table string, id int64, deletedAt string, current int64, version int64) error {
+	if deletedAt == "" {
+		return fmt.Errorf("%w: %s %d is not deleted", ErrInvalidState, table, id)
+	}
+	return checkVersion(table, id, current, version)
+}
@@ -0,0 +1,64 @@
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"small_business/models"
+	"time"
+)
+
+const defaultApplyInterval = time.Minute
+
+// Scheduler applies scheduled prices and ends promotions once they are due
+type Scheduler struct {
+	prices   models.PriceStore
+	interval time.Duration
+	now      func() time.Time
+}
+
+func NewScheduler(prices models.PriceStore, interval time.Duration) *Scheduler {
+	return &Scheduler{prices: prices, interval: interval, now: time.Now}
+}
+
+// NewSchedulerFromEnv uses PRICE_SCHEDULE_INTERVAL (default 1m)
+func NewSchedulerFromEnv(prices models.PriceStore) (*Scheduler, error) {
+	interval := defaultApplyInterval
+	if value := os.Getenv("PRICE_SCHEDULE_INTERVAL"); value != "" {
+		var err error
+		interval, err = time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("PRICE_SCHEDULE_INTERVAL must be a positive duration like 1m, got %q", value)
+		}
+	}
+	return NewScheduler(prices, interval), nil
+}
+
+// Run applies due prices right away and then every interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context) {
+	s.apply(ctx)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.apply(ctx)
+		}
+	}
+}
+
+func (s *Scheduler) apply(ctx context.Context) {
+	// the changes that went through are returned even when some schedules failed
+	changes, err := s.prices.ApplyDuePrices(ctx, s.now().UTC())
+	if err != nil {
+		log.Printf("Error applying scheduled prices: %v", err)
+	}
+	for _, change := range changes {
+		log.Printf("Price of product %d changed from %s to %s (%s)", change.ProductID, change.OldPrice, change.NewPrice, change.Reason)
+	}
+}
@@ -0,0 +1,62 @@
+package pricing
+
+import (
+	"context"
+	"small_business/models"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedulerAppliesPromotion(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := models.NewMemoryStore()
+
+	supplier := models.Supplier{Name: "testsupplier"}
+	assert.NoError(t, store.CreateSupplier(ctx, &supplier))
+	product := models.Product{Name: "testproduct", SupplierID: supplier.ID, Price: decimal.NewFromInt(10)}
+	assert.NoError(t, store.CreateProduct(ctx, &product))
+
+	startsAt := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	endsAt := startsAt.Add(24 * time.Hour)
+	promotion := models.ScheduledPrice{ProductID: product.ID, Price: decimal.NewFromInt(8), StartsAt: startsAt, EndsAt: &endsAt}
+	assert.NoError(t, store.SchedulePrice(ctx, &promotion))
+
+	now := startsAt.Add(-time.Minute)
+	scheduler := NewScheduler(store, time.Hour)
+	scheduler.now = func() time.Time { return now }
+	price := func() decimal.Decimal {
+		got, err := store.GetProduct(ctx, product.ID)
+		assert.NoError(t, err)
+		return got.Price
+	}
+
+	scheduler.apply(ctx) // not started yet
+	assert.True(t, decimal.NewFromInt(10).Equal(price()))
+
+	now = startsAt
+	scheduler.apply(ctx)
+	assert.True(t, decimal.NewFromInt(8).Equal(price()))
+
+	now = endsAt
+	scheduler.apply(ctx)
+	assert.True(t, decimal.NewFromInt(10).Equal(price()))
+
+	history, err := store.ListPriceHistory(ctx, product.ID)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestNewSchedulerFromEnv(t *testing.T) {
+	t.Setenv("PRICE_SCHEDULE_INTERVAL", "30s")
+	scheduler, err := NewSchedulerFromEnv(models.NewMemoryStore())
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, scheduler.interval)
+
+	t.Setenv("PRICE_SCHEDULE_INTERVAL", "soon")
+	_, err = NewSchedulerFromEnv(models.NewMemoryStore())
+	assert.Error(t, err)
+}